@@ -0,0 +1,99 @@
+package jresponse
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Family is an address family, named as Junos names them.
+type Family string
+
+const (
+	FamilyInet  Family = "inet"
+	FamilyInet6 Family = "inet6"
+)
+
+// AddrFamily returns the family of addr, or "" if addr is the zero Addr.
+// IPv4-mapped IPv6 addresses belong to FamilyInet6.
+func AddrFamily(addr netip.Addr) Family {
+	switch {
+	case addr.Is4():
+		return FamilyInet
+	case addr.Is6():
+		return FamilyInet6
+	default:
+		return ""
+	}
+}
+
+// ParseAddr parses an IPv4 or IPv6 address as found in a response,
+// including scoped link-local addresses such as "fe80::1%ge-0/0/0.0".
+func ParseAddr(s string) (netip.Addr, error) {
+	return netip.ParseAddr(strings.TrimSpace(s))
+}
+
+// ParsePrefix parses an IPv4 or IPv6 prefix as found in a response, such as
+// an rt-destination. Host bits are kept as Junos printed them.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	return netip.ParsePrefix(strings.TrimSpace(s))
+}
+
+// ComparePrefixes orders prefixes by family, then address, then length, so
+// that a covering prefix sorts before the more specific ones it covers.
+func ComparePrefixes(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
+
+// AddrError reports a malformed address or prefix in a response.
+type AddrError struct {
+	// Path is the path of the element holding the value, e.g.
+	// "ping-results/probe-result[2]/ip-address".
+	Path  string
+	Value string
+	Err   error
+}
+
+func (e *AddrError) Error() string {
+	return fmt.Sprintf("jresponse: %s: invalid address %q", e.Path, e.Value)
+}
+
+func (e *AddrError) Unwrap() error {
+	return e.Err
+}
+
+// AddrValidator collects the AddrErrors found while checking the addresses
+// of a response. Empty values are not checked, since Junos omits addresses
+// it doesn't have, such as those of probes that timed out.
+type AddrValidator struct {
+	errs []error
+}
+
+// Addr checks that value, found at path, is an address.
+func (v *AddrValidator) Addr(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	if _, err := ParseAddr(value); err != nil {
+		v.errs = append(v.errs, &AddrError{Path: path, Value: value, Err: err})
+	}
+}
+
+// Prefix checks that value, found at path, is a prefix.
+func (v *AddrValidator) Prefix(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	if _, err := ParsePrefix(value); err != nil {
+		v.errs = append(v.errs, &AddrError{Path: path, Value: value, Err: err})
+	}
+}
+
+// Err returns the AddrErrors found, joined, or nil if there were none.
+func (v *AddrValidator) Err() error {
+	return errors.Join(v.errs...)
+}
@@ -0,0 +1,87 @@
+package jresponse_test
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+func TestParseAddr(t *testing.T) {
+	addrs := map[string]jresponse.Family{
+		"8.8.8.8":            jresponse.FamilyInet,
+		" 206.126.236.21 ":   jresponse.FamilyInet,
+		"2001:4860::8888":    jresponse.FamilyInet6,
+		"fe80::1%ge-0/0/0.0": jresponse.FamilyInet6,
+		"::ffff:192.0.2.1":   jresponse.FamilyInet6,
+	}
+
+	for s, family := range addrs {
+		if addr, err := jresponse.ParseAddr(s); err != nil {
+			t.Error(err)
+		} else if f := jresponse.AddrFamily(addr); f != family {
+			t.Errorf("AddrFamily(%q) = %q, should be %q", s, f, family)
+		}
+	}
+
+	if addr, err := jresponse.ParseAddr("fe80::1%ge-0/0/0.0"); err != nil || addr.Zone() != "ge-0/0/0.0" {
+		t.Errorf("unexpected zone %q, %v", addr.Zone(), err)
+	}
+
+	if f := jresponse.AddrFamily(netip.Addr{}); f != "" {
+		t.Errorf("AddrFamily of the zero Addr = %q", f)
+	}
+}
+
+func TestComparePrefixes(t *testing.T) {
+	var prefixes []netip.Prefix
+	for _, s := range []string{"2001:db8::/32", "10.0.0.0/24", "10.0.0.0/8", "8.8.8.0/24", "::/0"} {
+		prefix, err := jresponse.ParsePrefix(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	slices.SortFunc(prefixes, jresponse.ComparePrefixes)
+
+	var sorted []string
+	for _, prefix := range prefixes {
+		sorted = append(sorted, prefix.String())
+	}
+
+	expected := []string{"8.8.8.0/24", "10.0.0.0/8", "10.0.0.0/24", "::/0", "2001:db8::/32"}
+	if !slices.Equal(sorted, expected) {
+		t.Errorf("sorted prefixes %v, should be %v", sorted, expected)
+	}
+}
+
+func TestAddrValidator(t *testing.T) {
+	v := jresponse.AddrValidator{}
+	v.Addr("a/ip-address", "10.0.0.1")
+	v.Addr("a/empty", "")
+	v.Prefix("a/rt-destination", "10.0.0.0/24")
+
+	if err := v.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	v.Addr("a/probe[2]/ip-address", "10.0.0.256")
+	v.Prefix("a/rt[1]/rt-destination", "10.0.0.0")
+
+	err := v.Err()
+	var addrErr *jresponse.AddrError
+	if !errors.As(err, &addrErr) {
+		t.Fatalf("expected an AddrError, got %v", err)
+	} else if addrErr.Path != "a/probe[2]/ip-address" || addrErr.Value != "10.0.0.256" {
+		t.Errorf("unexpected AddrError %+v", addrErr)
+	}
+
+	expected := `jresponse: a/probe[2]/ip-address: invalid address "10.0.0.256"` + "\n" +
+		`jresponse: a/rt[1]/rt-destination: invalid address "10.0.0.0"`
+	if err.Error() != expected {
+		t.Errorf("unexpected error %q", err)
+	}
+}
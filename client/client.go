@@ -0,0 +1,259 @@
+// Package client runs RPCs on Junos devices over NETCONF and decodes the
+// replies into jresponse's response types.
+//
+//	c, err := client.Dial(ctx, "192.168.1.1", netconf.SSHConfigPassword("username", "password"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer c.Close()
+//
+//	tr, err := c.Traceroute(ctx, client.TracerouteRequest{Host: "8.8.8.8"})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/command/ping"
+	"github.com/JReyLBC/jresponse/command/traceroute"
+	bgproute "github.com/JReyLBC/jresponse/show/route/protocol/bgp"
+	"github.com/Juniper/go-netconf/netconf"
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultPort is the port of the NETCONF over SSH service.
+const DefaultPort = "830"
+
+// ErrClosed is returned for RPCs on a closed Client, including one whose
+// session was closed because the context of an RPC was done.
+var ErrClosed = errors.New("client: session closed")
+
+// Session is the part of a NETCONF session a Client uses. It is
+// implemented by *netconf.Session, and can be faked in tests.
+type Session interface {
+	Exec(methods ...netconf.RPCMethod) (*netconf.RPCReply, error)
+	Close() error
+}
+
+// Client runs RPCs on one device. It is safe for concurrent use, though
+// RPCs are sent one at a time, as the session only has one reply pending.
+type Client struct {
+	mu      sync.Mutex
+	session Session
+	host    string
+	ip      string
+	closed  bool
+}
+
+// New returns a Client running RPCs on session, an established session to
+// host. Responses get host as their OriginHost, and as their OriginIP when
+// it's an IP address.
+func New(session Session, host string) *Client {
+	c := &Client{session: session, host: host}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		c.ip = addr.String()
+	}
+	return c
+}
+
+// Dial connects to the NETCONF over SSH service of target, a host with an
+// optional port, which defaults to DefaultPort. Responses get the host as
+// their OriginHost and the address connected to as their OriginIP.
+func Dial(ctx context.Context, target string, config *ssh.ClientConfig) (*Client, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = target, DefaultPort
+	}
+
+	conn, err := new(net.Dialer).DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	// the SSH handshake and hello exchange know nothing of contexts
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	session, err := netconf.NewSSHSession(conn, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	c := New(session, host)
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		c.ip = addr.IP.String()
+	}
+	return c, nil
+}
+
+// Close closes the session.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.session.Close()
+}
+
+// Host returns the host responses get as their OriginHost.
+func (c *Client) Host() string {
+	return c.host
+}
+
+// SetHost sets the host responses get as their OriginHost, such as the
+// name of the device in an inventory when it was dialed by address. It
+// must be called before running RPCs.
+func (c *Client) SetHost(host string) {
+	c.host = host
+}
+
+// Traceroute runs a traceroute from the device.
+func (c *Client) Traceroute(ctx context.Context, req TracerouteRequest) (*traceroute.TraceRoute, error) {
+	tr := new(traceroute.TraceRoute)
+	return tr, c.Exec(ctx, req, tr)
+}
+
+// Ping pings a host from the device.
+func (c *Client) Ping(ctx context.Context, req PingRequest) (*ping.Ping, error) {
+	p := &ping.Ping{Rapid: bool(req.Rapid)}
+	return p, c.Exec(ctx, req, p)
+}
+
+// ShowRouteProtocolBGP returns the routes the device learned from BGP.
+func (c *Client) ShowRouteProtocolBGP(ctx context.Context, req ShowRouteProtocolBGPRequest) (*bgproute.BGPRoute, error) {
+	b := new(bgproute.BGPRoute)
+	return b, c.Exec(ctx, req, b)
+}
+
+// Exec runs the RPC rpc and decodes the reply into resp. The request sent
+// is rpc's XML encoding, such as that of one of the request types of this
+// package, unless rpc is a netconf.RPCMethod like netconf.RawMethod. A
+// request with a Validate method is only sent if it returns nil.
+//
+// The OriginHost and OriginIP fields of resp are set to the device's. When
+// the device fails the RPC, the returned error is a jresponse.RPCErrors;
+// warnings are kept in the response's Errors.
+//
+// If ctx is done before the reply arrives the session is closed, since
+// NETCONF can't abandon an RPC, and the Client can't be used anymore.
+func (c *Client) Exec(ctx context.Context, rpc interface{}, resp jresponse.ResponseReaderWriter) error {
+	if v, ok := rpc.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+
+	method, ok := rpc.(netconf.RPCMethod)
+	if !ok {
+		request, err := xml.Marshal(rpc)
+		if err != nil {
+			return err
+		}
+		method = netconf.RawMethod(request)
+	}
+
+	reply, err := c.exec(ctx, method)
+	if err != nil {
+		var rpcErr *netconf.RPCError
+		if errors.As(err, &rpcErr) {
+			return jresponse.RPCErrors{convertError(rpcErr)}
+		}
+		return err
+	}
+
+	setOrigin(resp, c.host, c.ip)
+
+	if _, err := resp.ReadXMLFrom(bytes.NewBufferString(replyXML(reply))); err != nil {
+		return fmt.Errorf("%s: %w", c.host, err)
+	}
+	return nil
+}
+
+func (c *Client) exec(ctx context.Context, method netconf.RPCMethod) (*netconf.RPCReply, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrClosed
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		reply *netconf.RPCReply
+		err   error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		reply, err := c.session.Exec(method)
+		done <- result{reply, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.reply, r.err
+	case <-ctx.Done():
+		// the reply would otherwise be read as the reply to the next RPC
+		c.closed = true
+		c.session.Close()
+		<-done
+		return nil, ctx.Err()
+	}
+}
+
+// replyXML returns the rpc-reply as the device sent it. Older go-netconf
+// releases don't keep the raw reply, only the elements inside it.
+func replyXML(reply *netconf.RPCReply) string {
+	if reply.RawReply != "" {
+		return reply.RawReply
+	}
+	return "<rpc-reply>" + reply.Data + "</rpc-reply>"
+}
+
+// convertError returns the rpc-error go-netconf failed an RPC with as a
+// jresponse.RPCError, decoding the error-info go-netconf leaves as XML.
+func convertError(rpcErr *netconf.RPCError) jresponse.RPCError {
+	var e jresponse.RPCError
+	if err := jresponse.Unmarshal([]byte("<rpc-error>"+rpcErr.Info+"</rpc-error>"), &e); err == nil && e.Message != "" {
+		return e
+	}
+
+	return jresponse.RPCError{
+		Type:     rpcErr.Type,
+		Tag:      rpcErr.Tag,
+		Severity: rpcErr.Severity,
+		Path:     rpcErr.Path,
+		Message:  rpcErr.Message,
+	}
+}
+
+// setOrigin sets the OriginHost and OriginIP fields every response type
+// has.
+func setOrigin(resp jresponse.ResponseReaderWriter, host, ip string) {
+	v := reflect.ValueOf(resp)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	for name, value := range map[string]string{"OriginHost": host, "OriginIP": ip} {
+		if f := v.Elem().FieldByName(name); f.IsValid() && f.CanSet() && f.Kind() == reflect.String {
+			f.SetString(value)
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/command/ping"
+	"github.com/JReyLBC/jresponse/command/traceroute"
+	"github.com/JReyLBC/jresponse/netconftest"
+	"github.com/Juniper/go-netconf/netconf"
+)
+
+const (
+	TRACE_ROUTE_XML_FILE = "../command/traceroute/traceroute_8.8.8.8.xml"
+	PING_XML_FILE        = "../command/ping/ping_8.8.8.8.xml"
+	BGP_ROUTE_XML_FILE   = "../show/route/protocol/bgp/show_route_protocol_bgp.xml"
+)
+
+const rpcErrorXML = `<rpc-error>
+    <error-type>protocol</error-type>
+    <error-tag>operation-failed</error-tag>
+    <error-severity>error</error-severity>
+    <error-message>syntax error, expecting &lt;host&gt;</error-message>
+    <error-info>
+        <bad-element>traceroute</bad-element>
+    </error-info>
+</rpc-error>`
+
+const rpcWarningXML = `<rpc-error>
+    <error-type>protocol</error-type>
+    <error-tag>operation-failed</error-tag>
+    <error-severity>warning</error-severity>
+    <error-message>statement has no effect</error-message>
+</rpc-error>`
+
+// fakeDevice is a netconf.Transport answering each rpc with the reply set
+// for its method, the way a Junos device would over SSH.
+type fakeDevice struct {
+	mu       sync.Mutex
+	replies  map[string]string
+	requests []string
+	closed   chan struct{}
+
+	// waiting, when set, is closed as Receive starts waiting for the device
+	// to be closed instead of replying
+	waiting chan struct{}
+}
+
+func newFakeDevice(replies map[string]string) *fakeDevice {
+	return &fakeDevice{replies: replies, closed: make(chan struct{})}
+}
+
+func (d *fakeDevice) ReceiveHello() (*netconf.HelloMessage, error) {
+	return &netconf.HelloMessage{Capabilities: netconf.DefaultCapabilities, SessionID: 1}, nil
+}
+
+func (d *fakeDevice) SendHello(*netconf.HelloMessage) error {
+	return nil
+}
+
+func (d *fakeDevice) Send(p []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = append(d.requests, string(p))
+	return nil
+}
+
+// Receive replies to the last rpc sent.
+func (d *fakeDevice) Receive() ([]byte, error) {
+	d.mu.Lock()
+	waiting, request := d.waiting, d.requests[len(d.requests)-1]
+	d.mu.Unlock()
+
+	if waiting != nil {
+		close(waiting)
+		<-d.closed
+		return nil, errors.New("connection closed")
+	}
+
+	var rpc struct {
+		MessageID string `xml:"message-id,attr"`
+		Method    struct {
+			XMLName xml.Name
+		} `xml:",any"`
+	}
+	if err := xml.Unmarshal([]byte(request), &rpc); err != nil {
+		return nil, err
+	}
+
+	return []byte(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"` +
+		` xmlns:junos="http://xml.juniper.net/junos/12.3R7/junos"` +
+		` message-id="` + rpc.MessageID + `">` + "\n" +
+		d.replies[rpc.Method.XMLName.Local] + "\n" +
+		`</rpc-reply>`), nil
+}
+
+func (d *fakeDevice) Close() error {
+	close(d.closed)
+	return nil
+}
+
+// method returns the method of the last rpc sent.
+func (d *fakeDevice) method() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	request := d.requests[len(d.requests)-1]
+	start := strings.Index(request, "<rpc ")
+	start += strings.Index(request[start:], ">") + 1
+	return request[start : len(request)-len("</rpc>")]
+}
+
+func readFixture(t *testing.T, name string) string {
+	p, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(p)
+}
+
+func newTestClient(t *testing.T, replies map[string]string) (*Client, *fakeDevice) {
+	device := newFakeDevice(replies)
+	return New(netconf.NewSession(device), "192.0.2.1"), device
+}
+
+func TestTraceroute(t *testing.T) {
+
+	fixture := readFixture(t, TRACE_ROUTE_XML_FILE)
+	c, device := newTestClient(t, map[string]string{"traceroute": fixture})
+
+	tr, err := c.Traceroute(context.Background(), TracerouteRequest{Host: "8.8.8.8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if method := device.method(); method != "<traceroute><host>8.8.8.8</host></traceroute>" {
+		t.Errorf("sent %s", method)
+	}
+
+	want := new(traceroute.TraceRoute)
+	if _, err := want.ReadXMLFrom(strings.NewReader(fixture)); err != nil {
+		t.Fatal(err)
+	}
+	want.OriginHost, want.OriginIP = "192.0.2.1", "192.0.2.1"
+
+	if !reflect.DeepEqual(tr, want) {
+		t.Errorf("got %+v\nwant %+v", tr, want)
+	}
+}
+
+func TestPing(t *testing.T) {
+
+	c, device := newTestClient(t, map[string]string{"ping": readFixture(t, PING_XML_FILE)})
+
+	p, err := c.Ping(context.Background(), PingRequest{Host: "8.8.8.8", Count: 5, Rapid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if method := device.method(); method != "<ping><host>8.8.8.8</host><count>5</count><rapid></rapid></ping>" {
+		t.Errorf("sent %s", method)
+	}
+
+	if p.TargetHost != "8.8.8.8" || !p.Rapid {
+		t.Errorf("got target %q, rapid %v", p.TargetHost, p.Rapid)
+	}
+	if p.OriginHost != "192.0.2.1" || p.OriginIP != "192.0.2.1" {
+		t.Errorf("got origin %q (%q)", p.OriginHost, p.OriginIP)
+	}
+}
+
+func TestShowRouteProtocolBGP(t *testing.T) {
+
+	c, device := newTestClient(t, map[string]string{"get-route-information": readFixture(t, BGP_ROUTE_XML_FILE)})
+
+	req := ShowRouteProtocolBGPRequest{Destination: "1.0.4.0/24", Table: "inet.0"}
+	b, err := c.ShowRouteProtocolBGP(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "<get-route-information><destination>1.0.4.0/24</destination><table>inet.0</table><protocol>bgp</protocol></get-route-information>"
+	if method := device.method(); method != want {
+		t.Errorf("sent %s\nwant %s", method, want)
+	}
+
+	if len(b.RouteTables) == 0 || b.RouteTables[0].TableName != "inet.0" {
+		t.Errorf("got route tables %+v", b.RouteTables)
+	}
+	if b.OriginHost != "192.0.2.1" {
+		t.Errorf("got origin host %q", b.OriginHost)
+	}
+}
+
+func TestOrigin(t *testing.T) {
+
+	device := newFakeDevice(map[string]string{"traceroute": readFixture(t, TRACE_ROUTE_XML_FILE)})
+	c := New(netconf.NewSession(device), "router1")
+
+	tr, err := c.Traceroute(context.Background(), TracerouteRequest{Host: "8.8.8.8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.OriginHost != "router1" || tr.OriginIP != "" {
+		t.Errorf("got origin %q (%q)", tr.OriginHost, tr.OriginIP)
+	}
+}
+
+func TestRPCError(t *testing.T) {
+
+	c, _ := newTestClient(t, map[string]string{"traceroute": rpcErrorXML})
+
+	_, err := c.Traceroute(context.Background(), TracerouteRequest{Host: "8.8.8.8"})
+
+	var rpcErr *jresponse.RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("got %v, want an *jresponse.RPCError", err)
+	}
+
+	if rpcErr.Message != "syntax error, expecting <host>" || rpcErr.Tag != "operation-failed" {
+		t.Errorf("got %+v", rpcErr)
+	}
+	if rpcErr.Info == nil || rpcErr.Info.BadElement != "traceroute" {
+		t.Errorf("got error-info %+v", rpcErr.Info)
+	}
+
+	// the session is still usable after the device fails an rpc
+	c.session.(*netconf.Session).Transport.(*fakeDevice).replies["traceroute"] = readFixture(t, TRACE_ROUTE_XML_FILE)
+	if _, err := c.Traceroute(context.Background(), TracerouteRequest{Host: "8.8.8.8"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRPCWarning(t *testing.T) {
+
+	c, _ := newTestClient(t, map[string]string{"traceroute": rpcWarningXML + readFixture(t, TRACE_ROUTE_XML_FILE)})
+
+	tr, err := c.Traceroute(context.Background(), TracerouteRequest{Host: "8.8.8.8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.Errors) != 1 || !tr.Errors[0].IsWarning() || tr.Errors[0].Message != "statement has no effect" {
+		t.Errorf("got errors %+v", tr.Errors)
+	}
+}
+
+func TestContextDone(t *testing.T) {
+
+	c, device := newTestClient(t, nil)
+	device.waiting = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := c.Traceroute(ctx, TracerouteRequest{Host: "8.8.8.8"})
+		done <- err
+	}()
+	<-device.waiting
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+
+	select {
+	case <-device.closed:
+	default:
+		t.Error("session not closed")
+	}
+
+	if _, err := c.Traceroute(context.Background(), TracerouteRequest{Host: "8.8.8.8"}); err != ErrClosed {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExec(t *testing.T) {
+
+	c, device := newTestClient(t, map[string]string{"traceroute": readFixture(t, TRACE_ROUTE_XML_FILE)})
+
+	req := struct {
+		XMLName xml.Name `xml:"traceroute"`
+		Host    string   `xml:"host"`
+		Wait    int      `xml:"wait"`
+	}{Host: "8.8.8.8", Wait: 2}
+
+	for _, rpc := range []interface{}{
+		req,
+		netconf.RawMethod("<traceroute><host>8.8.8.8</host><wait>2</wait></traceroute>"),
+	} {
+		tr := new(traceroute.TraceRoute)
+		if err := c.Exec(context.Background(), rpc, tr); err != nil {
+			t.Fatal(err)
+		}
+
+		if method := device.method(); method != "<traceroute><host>8.8.8.8</host><wait>2</wait></traceroute>" {
+			t.Errorf("sent %s", method)
+		}
+		if tr.TargetHost != "8.8.8.8" || tr.OriginHost != "192.0.2.1" {
+			t.Errorf("got target %q from %q", tr.TargetHost, tr.OriginHost)
+		}
+	}
+
+	if err := c.Exec(context.Background(), req, new(ping.Ping)); err == nil {
+		t.Error("expected an error decoding a traceroute reply as a ping")
+	}
+}
+
+func TestRequestXML(t *testing.T) {
+
+	tests := []struct {
+		req  interface{}
+		want string
+	}{
+		{
+			PingRequest{Host: "8.8.8.8"},
+			"<ping><host>8.8.8.8</host></ping>",
+		},
+		{
+			PingRequest{
+				Host: "2001:db8::1", Count: 10, Rapid: true, Size: Int(1200), TTL: 64, Wait: 2, Interval: 0.5, TOS: Int(184),
+				Source: "2001:db8::2", Interface: "ge-0/0/0.0", RoutingInstance: "CUST-A", Inet6: true, NoResolve: true,
+			},
+			"<ping><host>2001:db8::1</host><count>10</count><rapid></rapid><size>1200</size><ttl>64</ttl>" +
+				"<wait>2</wait><interval>0.5</interval><tos>184</tos><source>2001:db8::2</source>" +
+				"<interface>ge-0/0/0.0</interface><routing-instance>CUST-A</routing-instance>" +
+				"<inet6></inet6><no-resolve></no-resolve></ping>",
+		},
+		{
+			PingRequest{Host: "10.0.0.1", Inet: true, DoNotFragment: true, BypassRouting: true, Interface: "ge-0/0/0.0"},
+			"<ping><host>10.0.0.1</host><interface>ge-0/0/0.0</interface>" +
+				"<do-not-fragment></do-not-fragment><inet></inet><bypass-routing></bypass-routing></ping>",
+		},
+		{
+			PingRequest{Host: "8.8.8.8", Size: Int(0), TOS: Int(0)},
+			"<ping><host>8.8.8.8</host><size>0</size><tos>0</tos></ping>",
+		},
+		{
+			TracerouteRequest{Host: "www.juniper.net", TTL: 20, Wait: 3, TOS: Int(8), Source: "10.0.0.2", RoutingInstance: "CUST-A", Inet: true, NoResolve: true},
+			"<traceroute><host>www.juniper.net</host><ttl>20</ttl><wait>3</wait><tos>8</tos><source>10.0.0.2</source>" +
+				"<routing-instance>CUST-A</routing-instance><inet></inet><no-resolve></no-resolve></traceroute>",
+		},
+		{
+			PingMPLSLDPRequest{FEC: "10.255.0.9/32", MPLSPingOptions: MPLSPingOptions{Count: 3, EXP: Int(0), Detail: true}},
+			"<request-ping-ldp-lsp><fec>10.255.0.9/32</fec><count>3</count><exp>0</exp><detail></detail></request-ping-ldp-lsp>",
+		},
+		{
+			PingMPLSRSVPRequest{LSPName: "to-pe2", MPLSPingOptions: MPLSPingOptions{Size: Int(1400), Source: "10.255.0.1"}},
+			"<request-ping-rsvp-lsp><lsp-name>to-pe2</lsp-name><size>1400</size><source>10.255.0.1</source></request-ping-rsvp-lsp>",
+		},
+		{
+			TracerouteMPLSLDPRequest{FEC: "10.255.0.9/32", MPLSTracerouteOptions: MPLSTracerouteOptions{TTL: 10, NoResolve: true}},
+			"<traceroute-mpls-ldp><fec>10.255.0.9/32</fec><ttl>10</ttl><no-resolve></no-resolve></traceroute-mpls-ldp>",
+		},
+		{
+			TracerouteMPLSRSVPRequest{LSPName: "to-pe2", MPLSTracerouteOptions: MPLSTracerouteOptions{Wait: 5, EXP: Int(7)}},
+			"<traceroute-mpls-rsvp><lsp-name>to-pe2</lsp-name><wait>5</wait><exp>7</exp></traceroute-mpls-rsvp>",
+		},
+	}
+
+	for _, test := range tests {
+		if err := test.req.(interface{ Validate() error }).Validate(); err != nil {
+			t.Errorf("%T: %v", test.req, err)
+		}
+
+		p, err := xml.Marshal(test.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(p) != test.want {
+			t.Errorf("got %s\nwant %s", p, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		req     interface{ Validate() error }
+		options []string
+	}{
+		{PingRequest{}, []string{"host"}},
+		{PingRequest{Host: "8.8.8.8", Count: -1, Size: Int(MaxSize + 1), TTL: 256, Wait: -5}, []string{"count", "size", "ttl", "wait"}},
+		{PingRequest{Host: "8.8.8.8", Interval: 0.01, TOS: Int(300)}, []string{"interval", "tos"}},
+		{PingRequest{Host: "8.8.8.8", Inet: true, Inet6: true}, []string{"inet/inet6", "host"}},
+		{PingRequest{Host: "8.8.8.8", Inet6: true}, []string{"host"}},
+		{PingRequest{Host: "2001:db8::1", Source: "10.0.0.1"}, []string{"source"}},
+		{PingRequest{Host: "8.8.8.8", Source: "10.0.0"}, []string{"source"}},
+		{PingRequest{Host: "2001:db8::1", DoNotFragment: true}, []string{"do-not-fragment/inet6"}},
+		{PingRequest{Host: "ipv6.google.com", Inet6: true, DoNotFragment: true}, []string{"do-not-fragment/inet6"}},
+		{PingRequest{Host: "8.8.8.8", BypassRouting: true, RoutingInstance: "CUST-A"}, []string{"bypass-routing/routing-instance"}},
+		{TracerouteRequest{Host: " "}, []string{"host"}},
+		{TracerouteRequest{Host: "8.8.8.8", TTL: 256, Wait: MaxWait + 1, TOS: Int(-1)}, []string{"ttl", "wait", "tos"}},
+		{TracerouteRequest{Host: "8.8.8.8", Source: "2001:db8::1"}, []string{"source"}},
+		{TracerouteRequest{Host: "8.8.8.8", BypassRouting: true, RoutingInstance: "CUST-A"}, []string{"bypass-routing/routing-instance"}},
+		{PingMPLSLDPRequest{FEC: "10.255.0.9"}, []string{"fec"}},
+		{PingMPLSRSVPRequest{MPLSPingOptions: MPLSPingOptions{EXP: Int(8), Source: "pe1"}}, []string{"lsp-name", "exp", "source"}},
+		{TracerouteMPLSLDPRequest{}, []string{"fec"}},
+		{TracerouteMPLSRSVPRequest{LSPName: "to-pe2", MPLSTracerouteOptions: MPLSTracerouteOptions{TTL: 300}}, []string{"ttl"}},
+	}
+
+	for _, test := range tests {
+		err := test.req.Validate()
+
+		var options []string
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var optErr *OptionError
+			if !errors.As(e, &optErr) {
+				t.Fatalf("%T: got %v, want an *OptionError", test.req, e)
+			}
+			options = append(options, optErr.Option)
+		}
+
+		if !reflect.DeepEqual(options, test.options) {
+			t.Errorf("%+v: got errors for %q, want %q (%v)", test.req, options, test.options, err)
+		}
+	}
+}
+
+func TestExecInvalid(t *testing.T) {
+
+	c, device := newTestClient(t, map[string]string{"ping": readFixture(t, PING_XML_FILE)})
+
+	_, err := c.Ping(context.Background(), PingRequest{Host: "8.8.8.8", Count: -1})
+
+	var optErr *OptionError
+	if !errors.As(err, &optErr) || optErr.Option != "count" {
+		t.Errorf("got %v, want an *OptionError for count", err)
+	}
+	if len(device.requests) != 0 {
+		t.Errorf("sent %d invalid requests", len(device.requests))
+	}
+}
+
+func TestDial(t *testing.T) {
+
+	s := netconftest.NewServer()
+	defer s.Close()
+
+	c, err := Dial(context.Background(), s.Addr, s.ClientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tr, err := c.Traceroute(context.Background(), TracerouteRequest{Host: "8.8.8.8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.TargetHost != "8.8.8.8" || tr.OriginHost != "127.0.0.1" || tr.OriginIP != "127.0.0.1" {
+		t.Errorf("got target %q from %q (%q)", tr.TargetHost, tr.OriginHost, tr.OriginIP)
+	}
+
+	s.SetReply("traceroute", netconftest.Reply{Data: "<traceroute-results/>", Delay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Traceroute(ctx, TracerouteRequest{Host: "8.8.8.8"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
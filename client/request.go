@@ -0,0 +1,378 @@
+package client
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+// Flag is an option of an RPC without a value, such as the rapid of a
+// ping, encoded as an empty element when set.
+type Flag bool
+
+// MarshalXML encodes a set f as an empty element. Fields of this type are
+// tagged omitempty so an unset f is left out.
+func (f Flag) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if !f {
+		return nil
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// The ranges of the options of the ping and traceroute commands, as the
+// Junos CLI accepts them. Options left at zero, or nil for those that may
+// be zero, are not sent, so the device uses its default.
+const (
+	MaxCount    = 2000000000
+	MaxSize     = 65468
+	MaxTTL      = 255
+	MaxWait     = 86400
+	MinInterval = 0.1
+	MaxInterval = 10000
+	MaxTOS      = 255
+	MaxEXP      = 7
+)
+
+// Int returns a pointer to v, to set the options that may be zero, such
+// as the Size and TOS of a PingRequest.
+func Int(v int) *int {
+	return &v
+}
+
+// OptionError reports an option of a request the device would reject.
+type OptionError struct {
+	// RPC is the command of the request, e.g. "ping".
+	RPC string
+	// Option is the option at fault, e.g. "count", or the options that
+	// can't be combined, e.g. "inet/inet6".
+	Option string
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("client: %s %s: %s", e.RPC, e.Option, e.Reason)
+}
+
+// optionValidator collects the OptionErrors found while checking the
+// options of a request, like jresponse.AddrValidator does for responses.
+type optionValidator struct {
+	rpc  string
+	errs []error
+}
+
+func (v *optionValidator) fail(option, format string, args ...interface{}) {
+	v.errs = append(v.errs, &OptionError{RPC: v.rpc, Option: option, Reason: fmt.Sprintf(format, args...)})
+}
+
+// required checks that option was given a value.
+func (v *optionValidator) required(option, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(option, "required")
+	}
+}
+
+// intRange checks that value is unset or within [min, max].
+func (v *optionValidator) intRange(option string, value, min, max int) {
+	if value != 0 && (value < min || value > max) {
+		v.fail(option, "%d out of range %d-%d", value, min, max)
+	}
+}
+
+// optionalRange checks that value is unset or within [min, max], for the
+// options that may be zero.
+func (v *optionValidator) optionalRange(option string, value *int, min, max int) {
+	if value != nil && (*value < min || *value > max) {
+		v.fail(option, "%d out of range %d-%d", *value, min, max)
+	}
+}
+
+// floatRange checks that value is unset or within [min, max].
+func (v *optionValidator) floatRange(option string, value, min, max float64) {
+	if value != 0 && (value < min || value > max) {
+		v.fail(option, "%g out of range %g-%g", value, min, max)
+	}
+}
+
+// conflict checks that options a and b aren't both set.
+func (v *optionValidator) conflict(a string, aSet bool, b string, bSet bool) {
+	if aSet && bSet {
+		v.fail(a+"/"+b, "can't be combined")
+	}
+}
+
+// addr checks that value is unset or an address of the family the inet
+// and inet6 flags ask for, if any, and returns its family.
+func (v *optionValidator) addr(option, value string, inet, inet6 Flag) jresponse.Family {
+	if value == "" {
+		return ""
+	}
+
+	addr, err := jresponse.ParseAddr(value)
+	if err != nil {
+		v.fail(option, "invalid address %q", value)
+		return ""
+	}
+
+	family := jresponse.AddrFamily(addr)
+	if inet && family != jresponse.FamilyInet {
+		v.fail(option, "%s address %s with inet", family, value)
+	} else if inet6 && family != jresponse.FamilyInet6 {
+		v.fail(option, "%s address %s with inet6", family, value)
+	}
+	return family
+}
+
+// host checks the host of a request, which may be a name as well as an
+// address, and returns its family if it's an address.
+func (v *optionValidator) host(value string, inet, inet6 Flag) jresponse.Family {
+	v.required("host", value)
+	if _, err := jresponse.ParseAddr(value); err != nil {
+		return ""
+	}
+	return v.addr("host", value, inet, inet6)
+}
+
+// source checks the source address of a request, which must be of the
+// family of its host.
+func (v *optionValidator) source(value string, host jresponse.Family, inet, inet6 Flag) {
+	if family := v.addr("source", value, inet, inet6); family != "" && host != "" && family != host {
+		v.fail("source", "%s address %s for an %s host", family, value, host)
+	}
+}
+
+// prefix checks that value is a prefix.
+func (v *optionValidator) prefix(option, value string) {
+	v.required(option, value)
+	if value == "" {
+		return
+	}
+	if _, err := jresponse.ParsePrefix(value); err != nil {
+		v.fail(option, "invalid prefix %q", value)
+	}
+}
+
+func (v *optionValidator) err() error {
+	return errors.Join(v.errs...)
+}
+
+// TracerouteRequest is the traceroute RPC.
+type TracerouteRequest struct {
+	XMLName xml.Name `xml:"traceroute"`
+	Host    string   `xml:"host"`
+
+	// TTL is the maximum time-to-live, 1 to MaxTTL.
+	TTL int `xml:"ttl,omitempty"`
+	// Wait is how long to wait for each response, in seconds, 1 to MaxWait.
+	Wait int `xml:"wait,omitempty"`
+	// TOS is the type-of-service of the probes, 0 to MaxTOS.
+	TOS *int `xml:"tos,omitempty"`
+
+	Source          string `xml:"source,omitempty"`
+	Interface       string `xml:"interface,omitempty"`
+	RoutingInstance string `xml:"routing-instance,omitempty"`
+
+	Inet          Flag `xml:"inet,omitempty"`
+	Inet6         Flag `xml:"inet6,omitempty"`
+	NoResolve     Flag `xml:"no-resolve,omitempty"`
+	BypassRouting Flag `xml:"bypass-routing,omitempty"`
+}
+
+// Validate checks the options of req as the device would, reporting each
+// option at fault as an *OptionError.
+func (req TracerouteRequest) Validate() error {
+	v := optionValidator{rpc: "traceroute"}
+	v.conflict("inet", bool(req.Inet), "inet6", bool(req.Inet6))
+	v.source(req.Source, v.host(req.Host, req.Inet, req.Inet6), req.Inet, req.Inet6)
+	v.intRange("ttl", req.TTL, 1, MaxTTL)
+	v.intRange("wait", req.Wait, 1, MaxWait)
+	v.optionalRange("tos", req.TOS, 0, MaxTOS)
+	v.conflict("bypass-routing", bool(req.BypassRouting), "routing-instance", req.RoutingInstance != "")
+	return v.err()
+}
+
+// PingRequest is the ping RPC.
+type PingRequest struct {
+	XMLName xml.Name `xml:"ping"`
+	Host    string   `xml:"host"`
+
+	// Count is the number of requests to send, 1 to MaxCount.
+	Count int  `xml:"count,omitempty"`
+	Rapid Flag `xml:"rapid,omitempty"`
+	// Size is the size of the request payload, in bytes, 0 to MaxSize.
+	Size *int `xml:"size,omitempty"`
+	// TTL is the time-to-live, or hop limit, of the requests, 1 to MaxTTL.
+	TTL int `xml:"ttl,omitempty"`
+	// Wait is how long to wait after the last request, in seconds, 1 to
+	// MaxWait.
+	Wait int `xml:"wait,omitempty"`
+	// Interval is the time between requests, in seconds, MinInterval to
+	// MaxInterval.
+	Interval float64 `xml:"interval,omitempty"`
+	// TOS is the type-of-service of the requests, 0 to MaxTOS.
+	TOS *int `xml:"tos,omitempty"`
+
+	Source          string `xml:"source,omitempty"`
+	Interface       string `xml:"interface,omitempty"`
+	RoutingInstance string `xml:"routing-instance,omitempty"`
+
+	// DoNotFragment sets the DF bit, which only IPv4 has.
+	DoNotFragment Flag `xml:"do-not-fragment,omitempty"`
+	Inet          Flag `xml:"inet,omitempty"`
+	Inet6         Flag `xml:"inet6,omitempty"`
+	NoResolve     Flag `xml:"no-resolve,omitempty"`
+	BypassRouting Flag `xml:"bypass-routing,omitempty"`
+}
+
+// Validate checks the options of req as the device would, reporting each
+// option at fault as an *OptionError.
+func (req PingRequest) Validate() error {
+	v := optionValidator{rpc: "ping"}
+	v.conflict("inet", bool(req.Inet), "inet6", bool(req.Inet6))
+	host := v.host(req.Host, req.Inet, req.Inet6)
+	v.source(req.Source, host, req.Inet, req.Inet6)
+	v.intRange("count", req.Count, 1, MaxCount)
+	v.optionalRange("size", req.Size, 0, MaxSize)
+	v.intRange("ttl", req.TTL, 1, MaxTTL)
+	v.intRange("wait", req.Wait, 1, MaxWait)
+	v.floatRange("interval", req.Interval, MinInterval, MaxInterval)
+	v.optionalRange("tos", req.TOS, 0, MaxTOS)
+	v.conflict("do-not-fragment", bool(req.DoNotFragment), "inet6", bool(req.Inet6) || host == jresponse.FamilyInet6)
+	v.conflict("bypass-routing", bool(req.BypassRouting), "routing-instance", req.RoutingInstance != "")
+	return v.err()
+}
+
+// MPLSPingOptions are the options ping mpls has for every kind of LSP.
+type MPLSPingOptions struct {
+	// Count is the number of echo requests to send, 1 to MaxCount.
+	Count int `xml:"count,omitempty"`
+	// Size is the size of the echo requests, in bytes, 0 to MaxSize.
+	Size *int `xml:"size,omitempty"`
+	// EXP is the class of service of the echo requests, 0 to MaxEXP.
+	EXP    *int   `xml:"exp,omitempty"`
+	Source string `xml:"source,omitempty"`
+	Detail Flag   `xml:"detail,omitempty"`
+}
+
+func (opts MPLSPingOptions) validate(v *optionValidator) {
+	v.intRange("count", opts.Count, 1, MaxCount)
+	v.optionalRange("size", opts.Size, 0, MaxSize)
+	v.optionalRange("exp", opts.EXP, 0, MaxEXP)
+	v.addr("source", opts.Source, false, false)
+}
+
+// PingMPLSLDPRequest is the request-ping-ldp-lsp RPC of the ping mpls ldp
+// command, which checks the LSP LDP signals for the prefix FEC. Its reply
+// isn't one of jresponse's responses.
+type PingMPLSLDPRequest struct {
+	XMLName xml.Name `xml:"request-ping-ldp-lsp"`
+	FEC     string   `xml:"fec"`
+	MPLSPingOptions
+}
+
+// Validate checks the options of req as the device would, reporting each
+// option at fault as an *OptionError.
+func (req PingMPLSLDPRequest) Validate() error {
+	v := optionValidator{rpc: "ping mpls ldp"}
+	v.prefix("fec", req.FEC)
+	req.validate(&v)
+	return v.err()
+}
+
+// PingMPLSRSVPRequest is the request-ping-rsvp-lsp RPC of the ping mpls
+// rsvp command, which checks the RSVP LSP LSPName. Its reply isn't one of
+// jresponse's responses.
+type PingMPLSRSVPRequest struct {
+	XMLName xml.Name `xml:"request-ping-rsvp-lsp"`
+	LSPName string   `xml:"lsp-name"`
+	MPLSPingOptions
+}
+
+// Validate checks the options of req as the device would, reporting each
+// option at fault as an *OptionError.
+func (req PingMPLSRSVPRequest) Validate() error {
+	v := optionValidator{rpc: "ping mpls rsvp"}
+	v.required("lsp-name", req.LSPName)
+	req.validate(&v)
+	return v.err()
+}
+
+// MPLSTracerouteOptions are the options traceroute mpls has for every
+// kind of LSP.
+type MPLSTracerouteOptions struct {
+	// TTL is the maximum time-to-live, 1 to MaxTTL.
+	TTL int `xml:"ttl,omitempty"`
+	// Wait is how long to wait for each response, in seconds, 1 to MaxWait.
+	Wait int `xml:"wait,omitempty"`
+	// EXP is the class of service of the echo requests, 0 to MaxEXP.
+	EXP       *int   `xml:"exp,omitempty"`
+	Source    string `xml:"source,omitempty"`
+	NoResolve Flag   `xml:"no-resolve,omitempty"`
+}
+
+func (opts MPLSTracerouteOptions) validate(v *optionValidator) {
+	v.intRange("ttl", opts.TTL, 1, MaxTTL)
+	v.intRange("wait", opts.Wait, 1, MaxWait)
+	v.optionalRange("exp", opts.EXP, 0, MaxEXP)
+	v.addr("source", opts.Source, false, false)
+}
+
+// TracerouteMPLSLDPRequest is the traceroute-mpls-ldp RPC of the
+// traceroute mpls ldp command, which traces the LSP LDP signals for the
+// prefix FEC. Its reply isn't one of jresponse's responses.
+type TracerouteMPLSLDPRequest struct {
+	XMLName xml.Name `xml:"traceroute-mpls-ldp"`
+	FEC     string   `xml:"fec"`
+	MPLSTracerouteOptions
+}
+
+// Validate checks the options of req as the device would, reporting each
+// option at fault as an *OptionError.
+func (req TracerouteMPLSLDPRequest) Validate() error {
+	v := optionValidator{rpc: "traceroute mpls ldp"}
+	v.prefix("fec", req.FEC)
+	req.validate(&v)
+	return v.err()
+}
+
+// TracerouteMPLSRSVPRequest is the traceroute-mpls-rsvp RPC of the
+// traceroute mpls rsvp command, which traces the RSVP LSP LSPName. Its
+// reply isn't one of jresponse's responses.
+type TracerouteMPLSRSVPRequest struct {
+	XMLName xml.Name `xml:"traceroute-mpls-rsvp"`
+	LSPName string   `xml:"lsp-name"`
+	MPLSTracerouteOptions
+}
+
+// Validate checks the options of req as the device would, reporting each
+// option at fault as an *OptionError.
+func (req TracerouteMPLSRSVPRequest) Validate() error {
+	v := optionValidator{rpc: "traceroute mpls rsvp"}
+	v.required("lsp-name", req.LSPName)
+	req.validate(&v)
+	return v.err()
+}
+
+// ShowRouteProtocolBGPRequest is the get-route-information RPC of the show
+// route protocol bgp command.
+type ShowRouteProtocolBGPRequest struct {
+	XMLName     xml.Name `xml:"get-route-information"`
+	Destination string   `xml:"destination,omitempty"`
+	Table       string   `xml:"table,omitempty"`
+	Detail      Flag     `xml:"detail,omitempty"`
+	Extensive   Flag     `xml:"extensive,omitempty"`
+}
+
+// MarshalXML encodes req with protocol bgp.
+func (req ShowRouteProtocolBGPRequest) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type request ShowRouteProtocolBGPRequest
+	return e.Encode(struct {
+		request
+		Protocol string `xml:"protocol"`
+	}{request(req), "bgp"})
+}
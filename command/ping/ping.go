@@ -3,19 +3,44 @@
 package ping
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"math"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
 	tmpl "text/template"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
 	log "github.com/Sirupsen/logrus"
 )
 
+// pingTemplateString renders the output of the Junos ping command. Rapid
+// pings print a "!" for each reply and a "." for each probe that failed,
+// otherwise a probe that timed out prints nothing. The IP header dump
+// printed after an ICMP error isn't part of the XML reply, so it isn't
+// rendered.
 const pingTemplateString = "PING {{ .TargetHost }} ({{ .TargetIP }}): {{ .PacketSize }} data bytes\n" +
-	"{{range $i, $element := .ProbeResult }}" +
+	"{{if .Rapid}}{{range .ProbeResult}}{{if .ProbeSuccess}}!{{else}}.{{end}}{{end}}\n" +
+	"{{else}}{{range $i, $element := .ProbeResult }}" +
+	"{{if .ProbeSuccess}}" +
 	"{{.ResponseSize}} bytes from {{ .IPAddress }}: icmp_seq={{ .SequenceNumber }} ttl={{ .TimeToLive }} time=" +
-	"{{ formatAsMs .RTT }} ms\n{{end}}"
+	"{{ formatAsMs .RTT }} ms\n" +
+	"{{else if .ICMPType}}{{.ResponseSize}} bytes from {{ .IPAddress }}: {{ .ICMPMessage }}\n{{end}}" +
+	"{{end}}\n{{end}}" +
+
+	"{{with .ProbeResultsSummary}}{{if .ProbesSent}}" +
+	"--- {{ $.TargetHost }} ping statistics ---\n" +
+	"{{.ProbesSent}} packets transmitted, {{.ResponsesReceived}} packets received, {{.PacketLoss}}% packet loss\n" +
+	"{{if .ResponsesReceived}}round-trip min/avg/max/stddev = {{ formatAsMs .RTTMinimum }}/" +
+	"{{ formatAsMs .RTTAverage }}/{{ formatAsMs .RTTMaximum }}/{{ formatAsMs .RTTStdDev }} ms\n{{end}}" +
+	"{{end}}{{end}}"
 
 func formatAsMs(ms uint) string {
 	return fmt.Sprintf("%.3f",float32(ms)/1000)
@@ -38,9 +63,20 @@ func init() {
 		Parse(pingTemplateString); err != nil {
 		log.Fatalln(err)
 	}
+
+	jresponse.Register(xml.Name{Space: jresponse.JunosNamespace("junos-probe-tests"), Local: "ping-results"},
+		func() jresponse.ResponseReaderWriter { return new(Ping) })
 }
 
 
+type ICMPType struct {
+	IntegerTypeValue uint `xml:"integer-type-value,attr" json:"integer-type-value"`
+}
+
+type ICMPCode struct {
+	IntegerCodeValue uint `xml:"integer-code-value,attr" json:"integer-code-value"`
+}
+
 type ProbeResult struct {
 	DateDetermined uint    `xml:"date-determined,attr,omitempty" json:"date-determined,omitempty"`
 	ProbeIndex     uint    `xml:"probe-index,omitempty"          json:"probe-index,omitempty"`
@@ -52,6 +88,8 @@ type ProbeResult struct {
     ResponseSize   uint    `xml:"response-size,omitempty"        json:"response-size,omitempty"`	
 	ProbeReached   string  `xml:"probe-reached,omitempty"        json:"probe-reached,omitempty"`
 	RTT            uint    `xml:"rtt,omitempty"                  json:"rtt,omitempty"`
+	ICMPType       *ICMPType `xml:"icmp-type,omitempty"          json:"icmp-type,omitempty"`
+	ICMPCode       *ICMPCode `xml:"icmp-code,omitempty"          json:"icmp-code,omitempty"`
 }
 
 type ProbeResultsSummary struct {
@@ -64,6 +102,104 @@ type ProbeResultsSummary struct {
 	RTTStdDev      uint        `xml:"rtt-stddev,omitempty"      json:"rtt-stddev,omitempty"`		
 }
 
+// Time returns when the probe's result was determined.
+func (probe *ProbeResult) Time() time.Time {
+	return time.Unix(int64(probe.DateDetermined), 0)
+}
+
+// RTTDuration returns the probe's round trip time, which Junos reports in
+// microseconds.
+func (probe *ProbeResult) RTTDuration() time.Duration {
+	return time.Duration(probe.RTT) * time.Microsecond
+}
+
+func (summary *ProbeResultsSummary) MinimumRTT() time.Duration {
+	return time.Duration(summary.RTTMinimum) * time.Microsecond
+}
+
+func (summary *ProbeResultsSummary) MaximumRTT() time.Duration {
+	return time.Duration(summary.RTTMaximum) * time.Microsecond
+}
+
+func (summary *ProbeResultsSummary) AverageRTT() time.Duration {
+	return time.Duration(summary.RTTAverage) * time.Microsecond
+}
+
+func (summary *ProbeResultsSummary) StdDevRTT() time.Duration {
+	return time.Duration(summary.RTTStdDev) * time.Microsecond
+}
+
+const (
+	icmpUnreach  = 3
+	icmpTimxceed = 11
+)
+
+// icmpMessages holds the messages ping prints for ICMP errors, by type and
+// code.
+var icmpMessages = map[[2]uint]string{
+	{icmpUnreach, 0}:  "Destination Net Unreachable",
+	{icmpUnreach, 1}:  "Destination Host Unreachable",
+	{icmpUnreach, 2}:  "Destination Protocol Unreachable",
+	{icmpUnreach, 3}:  "Destination Port Unreachable",
+	{icmpUnreach, 4}:  "frag needed and DF set",
+	{icmpUnreach, 5}:  "Source Route Failed",
+	{icmpUnreach, 6}:  "Destination Net Unknown",
+	{icmpUnreach, 7}:  "Destination Host Unknown",
+	{icmpUnreach, 8}:  "Source Host Isolated",
+	{icmpUnreach, 9}:  "Destination Net Prohibited",
+	{icmpUnreach, 10}: "Destination Host Prohibited",
+	{icmpUnreach, 11}: "Destination Net Unreachable for TOS",
+	{icmpUnreach, 12}: "Destination Host Unreachable for TOS",
+	{icmpUnreach, 13}: "Communication prohibited by filter",
+	{icmpUnreach, 14}: "Host Precedence Violation",
+	{icmpUnreach, 15}: "Precedence Cutoff",
+	{icmpTimxceed, 0}: "Time to live exceeded",
+	{icmpTimxceed, 1}: "Frag reassembly time exceeded",
+}
+
+// ICMPMessage returns the message ping prints for the ICMP error the probe
+// received, or "" if it didn't receive one.
+func (probe *ProbeResult) ICMPMessage() string {
+	if probe.ICMPType == nil {
+		return ""
+	}
+
+	var code uint
+	if probe.ICMPCode != nil {
+		code = probe.ICMPCode.IntegerCodeValue
+	}
+
+	if msg, ok := icmpMessages[[2]uint{probe.ICMPType.IntegerTypeValue, code}]; ok {
+		return msg
+	} else if probe.ICMPType.IntegerTypeValue == icmpUnreach {
+		return fmt.Sprintf("Dest Unreachable, Bad Code: %d", code)
+	}
+	return fmt.Sprintf("Bad ICMP type: %d", probe.ICMPType.IntegerTypeValue)
+}
+
+// parseICMPMessage sets the probe's ICMP type and code from the message
+// ping printed for it.
+func (probe *ProbeResult) parseICMPMessage(msg string) error {
+	for typeCode, m := range icmpMessages {
+		if m == msg {
+			probe.ICMPType = &ICMPType{IntegerTypeValue: typeCode[0]}
+			probe.ICMPCode = &ICMPCode{IntegerCodeValue: typeCode[1]}
+			return nil
+		}
+	}
+
+	var t, code uint
+	if _, err := fmt.Sscanf(msg, "Dest Unreachable, Bad Code: %d", &code); err == nil {
+		t = icmpUnreach
+	} else if _, err := fmt.Sscanf(msg, "Bad ICMP type: %d", &t); err != nil {
+		return fmt.Errorf("unknown ICMP error %q", msg)
+	}
+
+	probe.ICMPType = &ICMPType{IntegerTypeValue: t}
+	probe.ICMPCode = &ICMPCode{IntegerCodeValue: code}
+	return nil
+}
+
 // Represents the trace route XML structure, and is used to convert it
 // from XML to JSON.
 type Ping struct {
@@ -74,25 +210,40 @@ type Ping struct {
 	ProbeResult  []ProbeResult   `xml:"probe-result,omitempty"       json:"probe-result,omitempty"`	
 	ProbeResultsSummary ProbeResultsSummary `xml:"probe-results-summary,omitempty" json:"probe-results-summary,omitempty"`	
 	Errors            []RPCError `xml:"rpc-error,omitempty"          json:"rpc-error,omitempty"`	
-	OriginHost        string `json:"originhost,omitempty"` 
-	OriginIP          string `json:"originip,omitempty"`	
+	OriginHost        string `xml:"-" json:"originhost,omitempty"` 
+	OriginIP          string `xml:"-" json:"originip,omitempty"`	
+
+	// Rapid is set when the ping was requested with the rapid option,
+	// which Junos doesn't report in the reply. It selects the rapid
+	// output format in WriteCLITo.
+	Rapid bool `xml:"-" json:"rapid,omitempty"`
 }
 
-type RPCError struct {
-	Type     string `xml:"error-type"     json:"error-type"`
-	Tag      string `xml:"error-tag"      json:"error-tag"`
-	Severity string `xml:"error-severity" json:"error-severity"`
-	Path     string `xml:"error-path"     json:"error-path"`
-	Message  string `xml:"error-message"  json:"error-message"`
-	Info     string `xml:",innerxml"      json:",string`
+type RPCError = jresponse.RPCError
+
+// TargetAddr returns the address that was pinged.
+func (ping *Ping) TargetAddr() (netip.Addr, error) {
+	return jresponse.ParseAddr(ping.TargetIP)
 }
 
-func (ping *Ping) WriteXMLTo(w io.Writer) (n int64, err error) {
-	if s, err := xml.Marshal(ping); err != nil {
-		return 0, err
-	} else {
-		return bytes.NewBuffer(s).WriteTo(w)
+// Addr returns the address the probe's reply came from.
+func (probe *ProbeResult) Addr() (netip.Addr, error) {
+	return jresponse.ParseAddr(probe.IPAddress)
+}
+
+// Validate checks the addresses in the response, returning a
+// *jresponse.AddrError for each one that is malformed.
+func (ping *Ping) Validate() error {
+	v := jresponse.AddrValidator{}
+	v.Addr("ping-results/target-ip", ping.TargetIP)
+	for i, probe := range ping.ProbeResult {
+		v.Addr(fmt.Sprintf("ping-results/probe-result[%d]/ip-address", i+1), probe.IPAddress)
 	}
+	return v.Err()
+}
+
+func (ping *Ping) WriteXMLTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteXML(w, ping, ping.XMLName)
 }
 
 func (ping *Ping) WriteJSONTo(w io.Writer) (n int64, err error) {
@@ -103,6 +254,29 @@ func (ping *Ping) WriteJSONTo(w io.Writer) (n int64, err error) {
 	}
 }
 
+var pingJSONTimeFields = jresponse.JSONTimeFields{
+	Timestamps: []string{"date-determined"},
+	Durations: map[string]time.Duration{
+		"rtt":         time.Microsecond,
+		"rtt-minimum": time.Microsecond,
+		"rtt-maximum": time.Microsecond,
+		"rtt-average": time.Microsecond,
+		"rtt-stddev":  time.Microsecond,
+	},
+}
+
+// WriteJSONToWithOptions writes the same JSON as WriteJSONTo, with its
+// timestamps and round trip times encoded as selected by opts.
+func (ping *Ping) WriteJSONToWithOptions(w io.Writer, opts jresponse.JSONOptions) (n int64, err error) {
+	return jresponse.WriteJSONWithOptions(w, ping, pingJSONTimeFields, opts)
+}
+
+// WriteJunosJSONTo writes the ping results in the JSON Junos encodes them as
+// with "| display json".
+func (ping *Ping) WriteJunosJSONTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteJunosJSON(w, ping)
+}
+
 func (ping *Ping) WriteCLITo(w io.Writer) error {
 	return pingTemplate.Execute(w, ping)
 }
@@ -115,13 +289,15 @@ func (ping *Ping) ReadXMLFrom(r io.Reader) (n int64, err error) {
 		return n, err
 	}
 
-	pNoNewlines := bytes.Replace(buf.Bytes(), []byte("\n"), []byte(""), -1)
-
-	if err := xml.Unmarshal(pNoNewlines, ping); err != nil {
+	if err := jresponse.UnmarshalReply(buf.Bytes(), ping, &ping.Errors); err != nil {
 		return 0, err
-	} else {
-		return n, nil
 	}
+
+	if err := jresponse.CheckErrors(ping.Errors); err != nil {
+		return n, err
+	}
+
+	return n, ping.Validate()
 }
 
 func (ping *Ping) ReadJSONFrom(r io.Reader) (n int64, err error) {
@@ -133,6 +309,147 @@ func (ping *Ping) ReadJSONFrom(r io.Reader) (n int64, err error) {
 	} else if err = json.Unmarshal(buf.Bytes(), ping); err != nil {
 		return n, err
 	} else {
-		return n, nil
+		return n, ping.Validate()
+	}
+}
+
+// ReadJunosJSONFrom reads the ping results from the JSON Junos encodes them
+// as, captured with "| display json" or from its REST API.
+func (ping *Ping) ReadJunosJSONFrom(r io.Reader) (n int64, err error) {
+	return jresponse.ReadJunosJSONFrom(r, ping)
+}
+
+var (
+	pingHeaderRegexp = regexp.MustCompile(`^PING (\S+) \((\S+)\): (\d+) data bytes$`)
+	pingReplyRegexp  = regexp.MustCompile(`^(\d+) bytes from (\S+): icmp_seq=(\d+) ttl=(\d+) time=([\d.]+) ms$`)
+	pingErrorRegexp  = regexp.MustCompile(`^(\d+) bytes from (\S+): (.+)$`)
+	pingRapidRegexp  = regexp.MustCompile(`^[!.]+$`)
+	pingSentRegexp   = regexp.MustCompile(`^(\d+) packets transmitted, (\d+) packets received, ` +
+		`(?:\+\d+ duplicates, )?(\d+)(?:\.\d+)?% packet loss$`)
+	pingRTTRegexp = regexp.MustCompile(`^round-trip min/avg/max/stddev = ` +
+		`([\d.]+)/([\d.]+)/([\d.]+)/([\d.]+) ms$`)
+)
+
+// parseMs converts a millisecond value as printed by the CLI, e.g. "0.690",
+// into the microseconds used by the XML reply.
+func parseMs(ms string) (uint, error) {
+	f, err := strconv.ParseFloat(ms, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(math.Floor(f*1000 + 0.5)), nil
+}
+
+// parseUints parses each of strs into the corresponding element of dst.
+func parseUints(strs []string, dst ...*uint) error {
+	for i, str := range strs {
+		v, err := strconv.ParseUint(str, 10, 0)
+		if err != nil {
+			return err
+		}
+		*dst[i] = uint(v)
+	}
+	return nil
+}
+
+// isIPHeaderDump reports whether line belongs to the IP header dump
+// printed after an ICMP error response.
+func isIPHeaderDump(line string) bool {
+	return strings.HasPrefix(line, "Vr HL TOS") || strings.HasPrefix(line, " 4  ") ||
+		strings.HasPrefix(line, " 6  ")
+}
+
+// ReadCLIFrom parses the text output of the Junos ping command, as
+// produced by WriteCLITo, into ping. Probes that timed out print nothing
+// unless the ping was rapid, so they're only counted in the summary.
+func (ping *Ping) ReadCLIFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
 	}
+
+	var headerSeen bool
+	scanner := bufio.NewScanner(&buf)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		probe := ProbeResult{ProbeIndex: uint(len(ping.ProbeResult) + 1)}
+
+		if m := pingHeaderRegexp.FindStringSubmatch(line); m != nil {
+			headerSeen = true
+			ping.TargetHost, ping.TargetIP = m[1], m[2]
+			err = parseUints(m[3:], &ping.PacketSize)
+
+		} else if line == "" || isIPHeaderDump(line) || strings.HasPrefix(line, "--- ") {
+			continue
+
+		} else if !headerSeen {
+			return n, fmt.Errorf("line %d: ping output before PING header", lineNum)
+
+		} else if m := pingReplyRegexp.FindStringSubmatch(line); m != nil {
+			probe.ProbeSuccess = new(string)
+			probe.IPAddress = m[2]
+			if err = parseUints([]string{m[1], m[3], m[4]},
+				&probe.ResponseSize, &probe.SequenceNumber, &probe.TimeToLive); err == nil {
+				probe.RTT, err = parseMs(m[5])
+			}
+			ping.ProbeResult = append(ping.ProbeResult, probe)
+
+		} else if pingRapidRegexp.MatchString(line) {
+			ping.Rapid = true
+			for _, c := range line {
+				probe := ProbeResult{
+					ProbeIndex:     uint(len(ping.ProbeResult) + 1),
+					SequenceNumber: uint(len(ping.ProbeResult)),
+				}
+				if c == '!' {
+					probe.ProbeSuccess = new(string)
+				} else {
+					probe.ProbeFailure = new(string)
+				}
+				ping.ProbeResult = append(ping.ProbeResult, probe)
+			}
+
+		} else if m := pingSentRegexp.FindStringSubmatch(line); m != nil {
+			summary := &ping.ProbeResultsSummary
+			err = parseUints(m[1:], &summary.ProbesSent, &summary.ResponsesReceived, &summary.PacketLoss)
+
+		} else if m := pingRTTRegexp.FindStringSubmatch(line); m != nil {
+			summary := &ping.ProbeResultsSummary
+			for i, dst := range []*uint{&summary.RTTMinimum, &summary.RTTAverage,
+				&summary.RTTMaximum, &summary.RTTStdDev} {
+				if *dst, err = parseMs(m[i+1]); err != nil {
+					break
+				}
+			}
+
+		} else if m := pingErrorRegexp.FindStringSubmatch(line); m != nil {
+			// an ICMP error, e.g. "92 bytes from 10.0.0.1: Destination Host Unreachable"
+			probe.ProbeFailure = new(string)
+			probe.IPAddress = m[2]
+			if err = parseUints(m[1:2], &probe.ResponseSize); err == nil {
+				err = probe.parseICMPMessage(m[3])
+			}
+			ping.ProbeResult = append(ping.ProbeResult, probe)
+
+		} else {
+			return n, fmt.Errorf("line %d: unrecognized ping output %q", lineNum, line)
+		}
+
+		if err != nil {
+			return n, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return n, err
+	}
+
+	if !headerSeen {
+		return n, fmt.Errorf("no PING header found")
+	}
+
+	return n, ping.Validate()
 }
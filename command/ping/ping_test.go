@@ -3,9 +3,15 @@ package ping
 import (
 	"bytes"
 	"encoding/xml"
+	"errors"
+	"net/netip"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
 )
 
 var (
@@ -16,6 +22,13 @@ const (
 	PING_XML_FILE  = "ping_8.8.8.8.xml"
 	PING_JSON_FILE = "ping_8.8.8.8.json"
 	PING_CLI_FILE = "ping_8.8.8.8.cli"
+
+	PING_FAILURES_XML_FILE = "ping_failures.xml"
+	PING_FAILURES_CLI_FILE = "ping_failures.cli"
+	PING_RAPID_XML_FILE    = "ping_rapid.xml"
+	PING_RAPID_CLI_FILE    = "ping_rapid.cli"
+
+	PING_JUNOS_JSON_FILE = "ping_8.8.8.8_junos_synthetic.json"
 )
 
 func initPingModel() {
@@ -194,6 +207,88 @@ func TestReadJSONFrom(t *testing.T) {
 	}
 }
 
+func TestReadCLIFrom(t *testing.T) {
+
+	ping := new(Ping)
+
+	// the CLI fixture has no namespace or probe timestamps
+	cliModel := *pingXMLModel
+	cliModel.XMLName = xml.Name{}
+	cliModel.ProbeResult = make([]ProbeResult, len(pingXMLModel.ProbeResult))
+	for i, probe := range pingXMLModel.ProbeResult {
+		probe.DateDetermined = 0
+		cliModel.ProbeResult[i] = probe
+	}
+
+	if file, err := os.Open(PING_CLI_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := ping.ReadCLIFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(ping, &cliModel) {
+		t.Log(&cliModel)
+		t.Log(ping)
+		t.Error("parsed CLI does not match the test ping model")
+	}
+}
+
+func TestReadCLIFromFailures(t *testing.T) {
+	cli := "PING 10.0.0.1 (10.0.0.1): 56 data bytes\n" +
+		"64 bytes from 10.0.0.1: icmp_seq=0 ttl=64 time=1.234 ms\n" +
+		"92 bytes from 10.0.0.2: Destination Host Unreachable\n" +
+		"Vr HL TOS  Len   ID Flg  off TTL Pro  cks      Src      Dst\n" +
+		" 4  5  00 0054 1c2f   0 0000  40  01 4a5c 10.0.0.3  10.0.0.1\n" +
+		"\n" +
+		"--- 10.0.0.1 ping statistics ---\n" +
+		"3 packets transmitted, 1 packets received, 66% packet loss\n" +
+		"round-trip min/avg/max/stddev = 1.234/1.234/1.234/0.000 ms\n"
+
+	model := &Ping{
+		TargetHost: "10.0.0.1",
+		TargetIP:   "10.0.0.1",
+		PacketSize: 56,
+		ProbeResult: []ProbeResult{
+			{
+				ProbeIndex:     1,
+				ProbeSuccess:   new(string),
+				SequenceNumber: 0,
+				IPAddress:      "10.0.0.1",
+				TimeToLive:     64,
+				ResponseSize:   64,
+				RTT:            1234,
+			},
+			{
+				ProbeIndex:   2,
+				ProbeFailure: new(string),
+				IPAddress:    "10.0.0.2",
+				ResponseSize: 92,
+				ICMPType:     &ICMPType{3},
+				ICMPCode:     &ICMPCode{1},
+			},
+		},
+		ProbeResultsSummary: ProbeResultsSummary{
+			ProbesSent:        3,
+			ResponsesReceived: 1,
+			PacketLoss:        66,
+			RTTMinimum:        1234,
+			RTTMaximum:        1234,
+			RTTAverage:        1234,
+		},
+	}
+
+	ping := new(Ping)
+	if _, err := ping.ReadCLIFrom(bytes.NewBufferString(cli)); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(ping, model) {
+		t.Log(model)
+		t.Log(ping)
+		t.Error("parsed CLI does not match the test ping model")
+	}
+
+	if _, err := new(Ping).ReadCLIFrom(bytes.NewBufferString("64 bytes from 10.0.0.1\n")); err == nil {
+		t.Error("expected an error for output without a PING header")
+	}
+}
+
 func TestWriteCLITo(t *testing.T) {
 
 	modelBuf := bytes.Buffer{}
@@ -226,6 +321,95 @@ func TestWriteCLITo(t *testing.T) {
 	}
 }
 
+func TestWriteCLIToFailures(t *testing.T) {
+	fixtures := []struct {
+		xml, cli string
+		rapid    bool
+
+		// the IP header dumps ping printed after ICMP errors, which
+		// WriteCLITo can't render since the XML reply doesn't carry them
+		ipHeaderDumps []string
+	}{
+		{PING_FAILURES_XML_FILE, PING_FAILURES_CLI_FILE, false, []string{
+			"Vr HL TOS  Len   ID Flg  off TTL Pro  cks      Src      Dst\n" +
+				" 4  5  00 0054 1c2f   0 0000  40  01 4a5c 10.0.0.9  10.0.0.1 \n\n",
+			"Vr HL TOS  Len   ID Flg  off TTL Pro  cks      Src      Dst\n" +
+				" 4  5  00 0054 1c31   0 0000  3f  01 4b5a 10.0.0.9  10.0.0.1 \n\n",
+		}},
+		{PING_RAPID_XML_FILE, PING_RAPID_CLI_FILE, true, nil},
+	}
+
+	for _, fixture := range fixtures {
+		ping := new(Ping)
+		if file, err := os.Open(fixture.xml); err != nil {
+			t.Fatal(err)
+		} else if _, err := ping.ReadXMLFrom(file); err != nil {
+			t.Fatal(err)
+		}
+		ping.Rapid = fixture.rapid
+
+		cli, err := os.ReadFile(fixture.cli)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the dumps are the only difference from what the device printed
+		expected := string(cli)
+		for _, dump := range fixture.ipHeaderDumps {
+			if strings.Count(expected, dump) != 1 {
+				t.Fatalf("%s: IP header dump %q not found once", fixture.cli, dump)
+			}
+			expected = strings.Replace(expected, dump, "", 1)
+		}
+
+		buf := bytes.Buffer{}
+		if err := ping.WriteCLITo(&buf); err != nil {
+			t.Error(err)
+		} else if buf.String() != expected {
+			t.Errorf("%s: CLI does not match %s\n%s", fixture.xml, fixture.cli, buf.String())
+		}
+
+		// the parsed CLI renders the same output
+		parsed := new(Ping)
+		buf.Reset()
+		if _, err := parsed.ReadCLIFrom(bytes.NewReader(cli)); err != nil {
+			t.Error(err)
+		} else if parsed.Rapid != fixture.rapid {
+			t.Errorf("%s: Rapid = %v", fixture.cli, parsed.Rapid)
+		} else if err := parsed.WriteCLITo(&buf); err != nil {
+			t.Error(err)
+		} else if buf.String() != expected {
+			t.Errorf("%s: CLI does not survive a round trip\n%s", fixture.cli, buf.String())
+		}
+	}
+}
+
+func TestICMPMessage(t *testing.T) {
+	probes := map[string]ProbeResult{
+		"":                                   {},
+		"Destination Net Unreachable":        {ICMPType: &ICMPType{3}, ICMPCode: &ICMPCode{0}},
+		"Time to live exceeded":              {ICMPType: &ICMPType{11}},
+		"Dest Unreachable, Bad Code: 42":     {ICMPType: &ICMPType{3}, ICMPCode: &ICMPCode{42}},
+		"Bad ICMP type: 5":                   {ICMPType: &ICMPType{5}, ICMPCode: &ICMPCode{0}},
+		"Communication prohibited by filter": {ICMPType: &ICMPType{3}, ICMPCode: &ICMPCode{13}},
+	}
+
+	for msg, probe := range probes {
+		if m := probe.ICMPMessage(); m != msg {
+			t.Errorf("ICMPMessage() = %q, should be %q", m, msg)
+		}
+	}
+
+	probe := ProbeResult{}
+	if err := probe.parseICMPMessage("Dest Unreachable, Bad Code: 42"); err != nil {
+		t.Error(err)
+	} else if probe.ICMPType.IntegerTypeValue != 3 || probe.ICMPCode.IntegerCodeValue != 42 {
+		t.Errorf("unexpected ICMP type and code %v %v", probe.ICMPType, probe.ICMPCode)
+	} else if err := probe.parseICMPMessage("Unexpected thing"); err == nil {
+		t.Error("expected an error for an unknown ICMP error")
+	}
+}
+
 func TestNumberFormat(t *testing.T) {
 	msSlice := []uint{4635, 2639, 9478, 12850, 10227, 10796, 10612, 13286, 10605}
 	correctOutput := []string{"4.635", "2.639", "9.478", "12.850", "10.227", "10.796", "10.612", "13.286", "10.605"}
@@ -287,3 +471,88 @@ func TestWriteJSONTo(t *testing.T) {
 		t.Error("JSON bytes not equal")
 	}
 }
+
+func TestTimeAccessors(t *testing.T) {
+	probe := pingXMLModel.ProbeResult[0]
+	summary := pingXMLModel.ProbeResultsSummary
+
+	if d := probe.RTTDuration(); d != 690*time.Microsecond {
+		t.Errorf("RTTDuration() = %v", d)
+	} else if ts := probe.Time().UTC(); !ts.Equal(time.Date(2015, 11, 12, 17, 52, 44, 0, time.UTC)) {
+		t.Errorf("Time() = %v", ts)
+	} else if summary.MinimumRTT() != 644*time.Microsecond || summary.MaximumRTT() != 690*time.Microsecond ||
+		summary.AverageRTT() != 669*time.Microsecond || summary.StdDevRTT() != 20*time.Microsecond {
+		t.Error("unexpected summary round trip times")
+	}
+}
+
+func TestWriteJSONToWithOptions(t *testing.T) {
+	buf := bytes.Buffer{}
+	opts := jresponse.JSONOptions{ISO8601Timestamps: true, MillisecondDurations: true}
+
+	if _, err := pingXMLModel.WriteJSONToWithOptions(&buf, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{`"date-determined":"2015-11-12T17:52:44Z"`, `"rtt-ms":0.69`, `"rtt-stddev-ms":0.02`} {
+		if !strings.Contains(buf.String(), field) {
+			t.Errorf("JSON does not contain %s: %s", field, buf.String())
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if addr, err := pingXMLModel.TargetAddr(); err != nil || addr != netip.MustParseAddr("8.8.8.8") {
+		t.Errorf("TargetAddr() = %v, %v", addr, err)
+	} else if addr, err := pingXMLModel.ProbeResult[0].Addr(); err != nil || addr != netip.MustParseAddr("8.8.8.8") {
+		t.Errorf("Addr() = %v, %v", addr, err)
+	}
+
+	reply := `<ping-results>
+<target-host>example.net</target-host>
+<target-ip>2001:db8::1</target-ip>
+<probe-result><ip-address>2001:db8::1</ip-address></probe-result>
+<probe-result><ip-address>2001:db8::zz</ip-address></probe-result>
+</ping-results>`
+
+	_, err := new(Ping).ReadXMLFrom(bytes.NewBufferString(reply))
+
+	var addrErr *jresponse.AddrError
+	if !errors.As(err, &addrErr) {
+		t.Errorf("expected an AddrError, got %v", err)
+	} else if addrErr.Path != "ping-results/probe-result[2]/ip-address" {
+		t.Errorf("unexpected path %s", addrErr.Path)
+	}
+}
+
+func TestReadJunosJSONFrom(t *testing.T) {
+
+	ping := new(Ping)
+
+	if file, err := os.Open(PING_JUNOS_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := ping.ReadJunosJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(ping, pingXMLModel) {
+		t.Log(pingXMLModel)
+		t.Log(ping)
+		t.Error("unmarshalled Junos JSON does not match the ping xml model")
+	}
+}
+
+func TestWriteJunosJSONTo(t *testing.T) {
+
+	buf := bytes.Buffer{}
+	if _, err := pingXMLModel.WriteJunosJSONTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	ping := new(Ping)
+	if _, err := ping.ReadJunosJSONFrom(&buf); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(ping, pingXMLModel) {
+		t.Log(pingXMLModel)
+		t.Log(ping)
+		t.Error("Junos JSON does not read back as the ping xml model")
+	}
+}
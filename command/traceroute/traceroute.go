@@ -3,27 +3,115 @@
 package traceroute
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"math"
+	"net"
+	"net/netip"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	tmpl "text/template"
+	"time"
 
+	"github.com/JReyLBC/jresponse"
 	log "github.com/Sirupsen/logrus"
-	"strings"
 )
 
-const traceRouteTmplStr = "traceroute to {{ .TargetHost }} " +
-	"({{ .TargetIP }}), {{ .MaxHopIndex }} hops max, {{ .PacketSize }} byte packets\n" +
+const traceRouteTmplStr = "{{if .TargetIP}}traceroute to {{ .TargetHost }} " +
+	"({{ .TargetIP }}), {{ .MaxHopIndex }} hops max, {{ .PacketSize }} byte packets\n{{end}}" +
+
+	"{{range $i, $element := .Hops }}{{ formatHop $element }}\n{{end}}" +
+	"{{with .TraceRouteFailure}}{{.}}\n{{end}}"
+
+// annotation returns the annotation traceroute prints after the probe's
+// RTT, or "" if it has none.
+func (probe *ProbeResult) annotation() string {
+	if probe.ICMPType == nil || probe.ICMPType.IntegerTypeValue != icmpUnreach {
+		return ""
+	}
+
+	var code uint
+	if probe.ICMPCode != nil {
+		code = probe.ICMPCode.IntegerCodeValue
+	}
+
+	if code == icmpUnreachPort {
+		return ""
+	} else if annotation, ok := icmpUnreachAnnotations[code]; ok {
+		return annotation
+	}
+	return fmt.Sprintf("!<%d>", code)
+}
+
+// sameLabelsFollow reports whether the next probe of the hop that got a
+// response came from the same router with the same label stack as probe i.
+func (h *Hop) sameLabelsFollow(i int) bool {
+	for _, next := range h.ProbeResult[i+1:] {
+		if next.ProbeSuccess != nil {
+			return next.IPAddress == h.ProbeResult[i].IPAddress &&
+				reflect.DeepEqual(next.MPLSLabels, h.ProbeResult[i].MPLSLabels)
+		}
+	}
+	return false
+}
+
+// formatHop renders a hop as traceroute prints it: a "*" for each probe
+// without a response, and the address of a responding router whenever it
+// differs from the one that answered the previous probe. An MPLS label
+// stack is printed on lines of its own after the last of the probes that
+// reported it, and the hop's remaining probes continue on the next line.
+func formatHop(hop Hop) string {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "%2d ", hop.TTLValue)
+
+	var (
+		lastIPAddr string
+		lineBreak  bool
+	)
+	for i, probe := range hop.ProbeResult {
+		if lineBreak {
+			buf.WriteString("\n")
+			lineBreak = false
+		}
 
-	"{{range $i, $element := .Hops }} " +
-	"{{.TTLValue}}  {{ .TrimmedLastHostName }} ({{ .LastIPAddr }})  " +
-	"{{ range $_, $probeResult := .ProbeResult }}{{ formatAsMs $probeResult.RTT }} ms  " +
-	"{{end}}\n{{end}}"
+		if probe.ProbeSuccess == nil {
+			buf.WriteString(" *")
+			continue
+		}
+
+		if probe.IPAddress != lastIPAddr {
+			lastIPAddr = probe.IPAddress
+			if hostName := strings.TrimSpace(probe.HostName); hostName != "" {
+				fmt.Fprintf(&buf, " %s (%s)", hostName, probe.IPAddress)
+			} else {
+				fmt.Fprintf(&buf, " %s", probe.IPAddress)
+			}
+		}
+
+		fmt.Fprintf(&buf, "  %s ms", formatAsMs(probe.RTT))
+		if annotation := probe.annotation(); annotation != "" {
+			buf.WriteString(" " + annotation)
+		}
+
+		if len(probe.MPLSLabels) > 0 && !hop.sameLabelsFollow(i) {
+			for _, label := range probe.MPLSLabels {
+				buf.WriteString("\n     " + label.String())
+			}
+			lineBreak = true
+		}
+	}
+
+	return buf.String()
+}
 
 func formatAsMs(ms uint) string {
-	return fmt.Sprintf("%g", float32(ms)/1000)
+	return fmt.Sprintf("%.3f", float32(ms)/1000)
 }
 
 func add(a, b int) int {
@@ -35,7 +123,7 @@ var (
 )
 
 func init() {
-	fmtFuncMap := tmpl.FuncMap{"add": add, "formatAsMs": formatAsMs}
+	fmtFuncMap := tmpl.FuncMap{"add": add, "formatAsMs": formatAsMs, "formatHop": formatHop}
 
 	var err error
 	if traceRouteTempl, err = tmpl.New("traceRouteTmpl").
@@ -43,12 +131,15 @@ func init() {
 		Parse(traceRouteTmplStr); err != nil {
 		log.Fatalln(err)
 	}
+
+	jresponse.Register(xml.Name{Space: jresponse.JunosNamespace("junos-probe-tests"), Local: "traceroute-results"},
+		func() jresponse.ResponseReaderWriter { return new(TraceRoute) })
 }
 
 type ICMPCode struct {
-	IntegerCodeValue uint   `xml:"integer-code-value,omitempty"    json:"integer-code-value,omitempty"`
-	ICMPTimxceed     string `xml:"icmp-timxceed-intrans,omitempty" json:"icmp-timxceed-intrans,omitempty"`
-	ICMPUnreachPort  string `xml:"icmp-unreach-port,omitempty"     json:"icmp-unreach-port,omitempty"`
+	IntegerCodeValue uint   `xml:"integer-code-value,attr,omitempty" json:"integer-code-value,omitempty"`
+	ICMPTimxceed     string `xml:"icmp-timxceed-intrans,omitempty"   json:"icmp-timxceed-intrans,omitempty"`
+	ICMPUnreachPort  string `xml:"icmp-unreach-port,omitempty"       json:"icmp-unreach-port,omitempty"`
 }
 
 type ICMPType struct {
@@ -57,15 +148,43 @@ type ICMPType struct {
 	ICMPUnreach      string `xml:"icmp-unreach,omitempty"            json:"icpm-unreach,omitempty"`
 }
 
+// MPLSLabel is an MPLS label stack entry reported by a router in the ICMP
+// extensions (RFC 4950) of its time exceeded message.
+type MPLSLabel struct {
+	Label         uint `xml:"label"           json:"label"`
+	CoS           uint `xml:"cos"             json:"cos"`
+	TTL           uint `xml:"ttl"             json:"ttl"`
+	BottomOfStack uint `xml:"bottom-of-stack" json:"bottom-of-stack"`
+}
+
+// String returns the label stack entry as traceroute prints it.
+func (label MPLSLabel) String() string {
+	return fmt.Sprintf("MPLS Label=%d CoS=%d TTL=%d S=%d", label.Label, label.CoS, label.TTL, label.BottomOfStack)
+}
+
 type ProbeResult struct {
-	DateDetermined uint    `xml:"date-determined,attr,omitempty" json:"date-determined,omitempty"`
-	ProbeIndex     uint    `xml:"probe-index,omitempty"          json:"probe-index,omitempty"`
-	IPAddress      string  `xml:"ip-address,omitempty"           json:"ip-address,omitempty"`
-	HostName       string  `xml:"host-name,omitempty"            json:"host-name,omitempty"`
-	ProbeSuccess   *string `xml:"probe-success,omitempty"        json:"probe-success,omitempty"`
-	ProbeFailure   *string `xml:"probe-failure,omitempty"        json:"probe-failure,omitempty"`
-	ProbeReached   string  `xml:"probe-reached,omitempty"        json:"probe-reached,omitempty"`
-	RTT            uint    `xml:"rtt,omitempty"                  json:"rtt,omitempty"`
+	DateDetermined uint        `xml:"date-determined,attr,omitempty" json:"date-determined,omitempty"`
+	ProbeIndex     uint        `xml:"probe-index,omitempty"          json:"probe-index,omitempty"`
+	IPAddress      string      `xml:"ip-address,omitempty"           json:"ip-address,omitempty"`
+	HostName       string      `xml:"host-name,omitempty"            json:"host-name,omitempty"`
+	ICMPType       *ICMPType   `xml:"icmp-type,omitempty"            json:"icmp-type,omitempty"`
+	ICMPCode       *ICMPCode   `xml:"icmp-code,omitempty"            json:"icmp-code,omitempty"`
+	ProbeSuccess   *string     `xml:"probe-success,omitempty"        json:"probe-success,omitempty"`
+	ProbeFailure   *string     `xml:"probe-failure,omitempty"        json:"probe-failure,omitempty"`
+	ProbeReached   string      `xml:"probe-reached,omitempty"        json:"probe-reached,omitempty"`
+	RTT            uint        `xml:"rtt,omitempty"                  json:"rtt,omitempty"`
+	MPLSLabels     []MPLSLabel `xml:"mpls-label,omitempty"         json:"mpls-label,omitempty"`
+}
+
+// Time returns when the probe's result was determined.
+func (probe *ProbeResult) Time() time.Time {
+	return time.Unix(int64(probe.DateDetermined), 0)
+}
+
+// RTTDuration returns the probe's round trip time, which Junos reports in
+// microseconds.
+func (probe *ProbeResult) RTTDuration() time.Duration {
+	return time.Duration(probe.RTT) * time.Microsecond
 }
 
 type Hop struct {
@@ -90,25 +209,44 @@ type TraceRoute struct {
 	Hops              []Hop      `xml:"hop,omitempty"                json:"hop,omitempty"`
 	Errors            []RPCError `xml:"rpc-error,omitempty"          json:"rpc-error,omitempty"`
 	TraceRouteFailure string     `xml:"traceroute-failure,omitempty" json:"traceroute-failure,omitempty"`
-	OriginHost        string `json:"originhost,omitempty"` 
-	OriginIP          string `json:"originip,omitempty"`		
+	OriginHost        string `xml:"-" json:"originhost,omitempty"` 
+	OriginIP          string `xml:"-" json:"originip,omitempty"`		
 }
 
-type RPCError struct {
-	Type     string `xml:"error-type"     json:"error-type"`
-	Tag      string `xml:"error-tag"      json:"error-tag"`
-	Severity string `xml:"error-severity" json:"error-severity"`
-	Path     string `xml:"error-path"     json:"error-path"`
-	Message  string `xml:"error-message"  json:"error-message"`
-	Info     string `xml:",innerxml"      json:",string`
+type RPCError = jresponse.RPCError
+
+// TargetAddr returns the address the route was traced to.
+func (traceRoute *TraceRoute) TargetAddr() (netip.Addr, error) {
+	return jresponse.ParseAddr(traceRoute.TargetIP)
 }
 
-func (traceRoute *TraceRoute) WriteXMLTo(w io.Writer) (n int64, err error) {
-	if s, err := xml.Marshal(traceRoute); err != nil {
-		return 0, err
-	} else {
-		return bytes.NewBuffer(s).WriteTo(w)
+// LastAddr returns the address of the last router that replied at this hop.
+func (h *Hop) LastAddr() (netip.Addr, error) {
+	return jresponse.ParseAddr(h.LastIPAddr)
+}
+
+// Addr returns the address the probe's reply came from.
+func (probe *ProbeResult) Addr() (netip.Addr, error) {
+	return jresponse.ParseAddr(probe.IPAddress)
+}
+
+// Validate checks the addresses in the response, returning a
+// *jresponse.AddrError for each one that is malformed.
+func (traceRoute *TraceRoute) Validate() error {
+	v := jresponse.AddrValidator{}
+	v.Addr("traceroute-results/target-ip", traceRoute.TargetIP)
+	for i, hop := range traceRoute.Hops {
+		path := fmt.Sprintf("traceroute-results/hop[%d]", i+1)
+		v.Addr(path+"/last-ip-address", hop.LastIPAddr)
+		for j, probe := range hop.ProbeResult {
+			v.Addr(fmt.Sprintf("%s/probe-result[%d]/ip-address", path, j+1), probe.IPAddress)
+		}
 	}
+	return v.Err()
+}
+
+func (traceRoute *TraceRoute) WriteXMLTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteXML(w, traceRoute, traceRoute.XMLName)
 }
 
 func (traceRoute *TraceRoute) WriteJSONTo(w io.Writer) (n int64, err error) {
@@ -119,6 +257,23 @@ func (traceRoute *TraceRoute) WriteJSONTo(w io.Writer) (n int64, err error) {
 	}
 }
 
+var traceRouteJSONTimeFields = jresponse.JSONTimeFields{
+	Timestamps: []string{"date-determined"},
+	Durations:  map[string]time.Duration{"rtt": time.Microsecond},
+}
+
+// WriteJSONToWithOptions writes the same JSON as WriteJSONTo, with its
+// timestamps and round trip times encoded as selected by opts.
+func (traceRoute *TraceRoute) WriteJSONToWithOptions(w io.Writer, opts jresponse.JSONOptions) (n int64, err error) {
+	return jresponse.WriteJSONWithOptions(w, traceRoute, traceRouteJSONTimeFields, opts)
+}
+
+// WriteJunosJSONTo writes the traceroute results in the JSON Junos encodes
+// them as with "| display json".
+func (traceRoute *TraceRoute) WriteJunosJSONTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteJunosJSON(w, traceRoute)
+}
+
 func (traceRoute *TraceRoute) WriteCLITo(w io.Writer) error {
 	return traceRouteTempl.Execute(w, traceRoute)
 }
@@ -131,13 +286,15 @@ func (traceRoute *TraceRoute) ReadXMLFrom(r io.Reader) (n int64, err error) {
 		return n, err
 	}
 
-	pNoNewlines := bytes.Replace(buf.Bytes(), []byte("\n"), []byte(""), -1)
-
-	if err := xml.Unmarshal(pNoNewlines, traceRoute); err != nil {
+	if err := jresponse.UnmarshalReply(buf.Bytes(), traceRoute, &traceRoute.Errors); err != nil {
 		return 0, err
-	} else {
-		return n, nil
 	}
+
+	if err := jresponse.CheckErrors(traceRoute.Errors); err != nil {
+		return n, err
+	}
+
+	return n, traceRoute.Validate()
 }
 
 func (traceRoute *TraceRoute) ReadJSONFrom(r io.Reader) (n int64, err error) {
@@ -149,6 +306,255 @@ func (traceRoute *TraceRoute) ReadJSONFrom(r io.Reader) (n int64, err error) {
 	} else if err = json.Unmarshal(buf.Bytes(), traceRoute); err != nil {
 		return n, err
 	} else {
-		return n, nil
+		return n, traceRoute.Validate()
+	}
+}
+
+// ReadJunosJSONFrom reads the traceroute results from the JSON Junos encodes
+// them as, captured with "| display json" or from its REST API.
+func (traceRoute *TraceRoute) ReadJunosJSONFrom(r io.Reader) (n int64, err error) {
+	return jresponse.ReadJunosJSONFrom(r, traceRoute)
+}
+
+const (
+	icmpUnreach  = 3
+	icmpTimxceed = 11
+
+	icmpUnreachPort = 3
+)
+
+var (
+	traceRouteHeaderRegexp = regexp.MustCompile(`^traceroute6? to (\S+) \((\S+)\), ` +
+		`(\d+) hops max, (\d+) byte packets$`)
+	mplsLabelRegexp = regexp.MustCompile(`^\s+MPLS Label=(\d+) CoS=(\d+) TTL=(\d+) S=(\d+)$`)
+
+	// icmpUnreachAnnotations holds the annotations printed after the RTT of
+	// a probe answered by an ICMP destination unreachable, by code. Port
+	// unreachable, the normal answer from the target, isn't annotated.
+	icmpUnreachAnnotations = map[uint]string{
+		0: "!N", 1: "!H", 2: "!P", 4: "!F", 5: "!S", 6: "!U", 7: "!W",
+		8: "!I", 9: "!A", 10: "!Z", 11: "!Q", 12: "!T", 13: "!X", 14: "!V", 15: "!C",
+	}
+)
+
+// parseMs converts a millisecond value as printed by the CLI, e.g. "13.876",
+// into the microseconds used by the XML reply.
+func parseMs(ms string) (uint, error) {
+	f, err := strconv.ParseFloat(ms, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(math.Floor(f*1000 + 0.5)), nil
+}
+
+// parseAnnotation returns the ICMP unreachable code for an annotation such
+// as "!H", "!F-1500" or "!<10>".
+func parseAnnotation(annotation string) (uint, error) {
+	if strings.HasPrefix(annotation, "!<") && strings.HasSuffix(annotation, ">") {
+		code, err := strconv.ParseUint(annotation[2:len(annotation)-1], 10, 0)
+		return uint(code), err
+	} else if strings.HasPrefix(annotation, "!F") {
+		// the MTU may follow, e.g. "!F-1500"
+		annotation = "!F"
+	}
+
+	for code, a := range icmpUnreachAnnotations {
+		if a == annotation {
+			return code, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown annotation %q", annotation)
+}
+
+// parseHop adds the probes found in fields, the whitespace separated
+// contents of a hop line after the TTL, to hop.
+func (traceRoute *TraceRoute) parseHop(hop *Hop, fields []string) error {
+
+	var hostName, ipAddr string
+	if len(hop.ProbeResult) > 0 {
+		hostName, ipAddr = hop.LastHostName, hop.LastIPAddr
+	}
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		probe := ProbeResult{ProbeIndex: uint(len(hop.ProbeResult) + 1)}
+
+		switch {
+		case field == "*":
+			probe.ProbeFailure = new(string)
+			hop.ProbeResult = append(hop.ProbeResult, probe)
+
+		case i+1 < len(fields) && fields[i+1] == "ms":
+			if ipAddr == "" {
+				return fmt.Errorf("round trip time %s ms without an address", field)
+			}
+
+			rtt, err := parseMs(field)
+			if err != nil {
+				return err
+			}
+
+			probe.IPAddress, probe.HostName, probe.RTT = ipAddr, hostName, rtt
+			probe.ProbeSuccess = new(string)
+			if ipAddr == traceRoute.TargetIP {
+				probe.ICMPType = &ICMPType{IntegerTypeValue: icmpUnreach}
+				probe.ICMPCode = &ICMPCode{IntegerCodeValue: icmpUnreachPort}
+			} else {
+				probe.ICMPType = &ICMPType{IntegerTypeValue: icmpTimxceed}
+				probe.ICMPCode = &ICMPCode{}
+			}
+
+			hop.ProbeResult = append(hop.ProbeResult, probe)
+			hop.LastHostName, hop.LastIPAddr = hostName, ipAddr
+			i++
+
+		case strings.HasPrefix(field, "!"):
+			last := len(hop.ProbeResult) - 1
+			if last < 0 || hop.ProbeResult[last].ProbeSuccess == nil {
+				return fmt.Errorf("annotation %s without a response", field)
+			}
+
+			code, err := parseAnnotation(field)
+			if err != nil {
+				return err
+			}
+			hop.ProbeResult[last].ICMPType = &ICMPType{IntegerTypeValue: icmpUnreach}
+			hop.ProbeResult[last].ICMPCode = &ICMPCode{IntegerCodeValue: code}
+
+		case i+1 < len(fields) && strings.HasPrefix(fields[i+1], "(") &&
+			strings.HasSuffix(fields[i+1], ")"):
+			hostName, ipAddr = field, strings.Trim(fields[i+1], "()")
+			i++
+
+		case net.ParseIP(field) != nil:
+			// addresses are printed without a host name when not resolving
+			hostName, ipAddr = "", field
+
+		default:
+			return fmt.Errorf("unexpected %q", field)
+		}
+	}
+
+	return nil
+}
+
+// parseMPLSLabel parses the label, CoS, TTL and bottom of stack values in
+// fields.
+func parseMPLSLabel(fields []string) (label MPLSLabel, err error) {
+	var values [4]uint
+	for i, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 0)
+		if err != nil {
+			return label, err
+		}
+		values[i] = uint(v)
+	}
+	return MPLSLabel{Label: values[0], CoS: values[1], TTL: values[2], BottomOfStack: values[3]}, nil
+}
+
+// labelRun returns the probes of the hop a label stack printed after its
+// last probe belongs to: those that got a response since the last probe
+// with a label stack.
+func (h *Hop) labelRun() (run []*ProbeResult) {
+	for i := len(h.ProbeResult) - 1; i >= 0; i-- {
+		probe := &h.ProbeResult[i]
+		if probe.MPLSLabels != nil {
+			break
+		} else if probe.ProbeSuccess != nil {
+			run = append(run, probe)
+		}
+	}
+	return run
+}
+
+// ReadCLIFrom parses the text output of the Junos traceroute command, as
+// produced by WriteCLITo, into traceRoute.
+func (traceRoute *TraceRoute) ReadCLIFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
 	}
+
+	var (
+		headerSeen bool
+		labelRun   []*ProbeResult
+	)
+	scanner := bufio.NewScanner(&buf)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		fields := strings.Fields(line)
+
+		if len(fields) == 0 {
+			continue
+
+		} else if strings.HasPrefix(line, "traceroute: ") || strings.HasPrefix(line, "traceroute6: ") {
+			traceRoute.TraceRouteFailure = line
+			continue
+
+		} else if m := traceRouteHeaderRegexp.FindStringSubmatch(line); m != nil {
+			headerSeen = true
+			traceRoute.TargetHost, traceRoute.TargetIP = m[1], m[2]
+
+			var maxHops, packetSize uint64
+			if maxHops, err = strconv.ParseUint(m[3], 10, 0); err == nil {
+				packetSize, err = strconv.ParseUint(m[4], 10, 0)
+			}
+			if err != nil {
+				return n, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			traceRoute.MaxHopIndex, traceRoute.PacketSize = uint(maxHops), uint(packetSize)
+			continue
+
+		} else if !headerSeen {
+			return n, fmt.Errorf("line %d: traceroute output before header", lineNum)
+
+		} else if m := mplsLabelRegexp.FindStringSubmatch(line); m != nil {
+			label, err := parseMPLSLabel(m[1:])
+			if err != nil {
+				return n, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+
+			// the first label of a stack starts a new run
+			if labelRun == nil && len(traceRoute.Hops) > 0 {
+				labelRun = traceRoute.Hops[len(traceRoute.Hops)-1].labelRun()
+			}
+			if len(labelRun) == 0 {
+				return n, fmt.Errorf("line %d: MPLS label without a response", lineNum)
+			}
+			for _, probe := range labelRun {
+				probe.MPLSLabels = append(probe.MPLSLabels, label)
+			}
+			continue
+		}
+
+		labelRun = nil
+
+		// an RTT of a whole number of milliseconds can start a continued
+		// line, so a TTL must not be followed by "ms"
+		if ttl, err := strconv.ParseUint(fields[0], 10, 0); err == nil && (len(fields) == 1 || fields[1] != "ms") {
+			traceRoute.Hops = append(traceRoute.Hops, Hop{TTLValue: uint(ttl)})
+			fields = fields[1:]
+		} else if len(traceRoute.Hops) == 0 {
+			return n, fmt.Errorf("line %d: probe results before the first hop", lineNum)
+		}
+
+		// lines without a TTL continue the previous hop
+		hop := &traceRoute.Hops[len(traceRoute.Hops)-1]
+		if err = traceRoute.parseHop(hop, fields); err != nil {
+			return n, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return n, err
+	}
+
+	if !headerSeen && traceRoute.TraceRouteFailure == "" {
+		return n, fmt.Errorf("no traceroute header found")
+	}
+
+	return n, traceRoute.Validate()
 }
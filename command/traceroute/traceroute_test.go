@@ -3,9 +3,13 @@ package traceroute
 import (
 	"bytes"
 	"encoding/xml"
+	"errors"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/JReyLBC/jresponse"
 )
 
 var (
@@ -13,9 +17,15 @@ var (
 )
 
 const (
-	TRACE_ROUTE_XML_FILE  = "traceroute_8.8.8.8.xml"
-	TRACE_ROUTE_JSON_FILE = "traceroute_8.8.8.8.json"
-	TRACE_ROUTE_CLIE_FILE = "traceroute_8.8.8.8.cli"
+	TRACE_ROUTE_XML_FILE        = "traceroute_8.8.8.8.xml"
+	TRACE_ROUTE_JSON_FILE       = "traceroute_8.8.8.8.json"
+	TRACE_ROUTE_CLIE_FILE       = "traceroute_8.8.8.8.cli"
+	TRACE_ROUTE_JUNOS_JSON_FILE = "traceroute_8.8.8.8_junos_synthetic.json"
+
+	TRACE_ROUTE_FAILURES_XML_FILE = "traceroute_failures.xml"
+	TRACE_ROUTE_FAILURES_CLI_FILE = "traceroute_failures.cli"
+	TRACE_ROUTE_MPLS_XML_FILE     = "traceroute_mpls.xml"
+	TRACE_ROUTE_MPLS_CLI_FILE     = "traceroute_mpls.cli"
 )
 
 func initTraceRouteModel() {
@@ -37,6 +47,8 @@ func initTraceRouteModel() {
 						ProbeIndex:     1,
 						IPAddress:      "10.226.0.1",
 						HostName:       "10.226.0.1",
+						ICMPType:       &ICMPType{IntegerTypeValue: 11},
+						ICMPCode:       &ICMPCode{IntegerCodeValue: 0},
 						RTT:            13876,
 						ProbeSuccess:   new(string),
 					},
@@ -45,6 +57,8 @@ func initTraceRouteModel() {
 						ProbeIndex:     2,
 						IPAddress:      "10.226.0.1",
 						HostName:       "10.226.0.1",
+						ICMPType:       &ICMPType{IntegerTypeValue: 11},
+						ICMPCode:       &ICMPCode{IntegerCodeValue: 0},
 						RTT:            11752,
 						ProbeSuccess:   new(string),
 					},
@@ -53,6 +67,8 @@ func initTraceRouteModel() {
 						ProbeIndex:     3,
 						IPAddress:      "10.226.0.1",
 						HostName:       "10.226.0.1",
+						ICMPType:       &ICMPType{IntegerTypeValue: 11},
+						ICMPCode:       &ICMPCode{IntegerCodeValue: 0},
 						RTT:            10973,
 						ProbeSuccess:   new(string),
 					},
@@ -136,6 +152,155 @@ func TestReadJSONFrom(t *testing.T) {
 	}
 }
 
+func TestReadCLIFrom(t *testing.T) {
+
+	tr := new(TraceRoute)
+
+	// the CLI fixture has no namespace or probe timestamps
+	cliModel := *traceRouteXMLModel
+	cliModel.XMLName = xml.Name{}
+	cliModel.Hops = make([]Hop, len(traceRouteXMLModel.Hops))
+	for i, hop := range traceRouteXMLModel.Hops {
+		probes := make([]ProbeResult, len(hop.ProbeResult))
+		for j, probe := range hop.ProbeResult {
+			probe.DateDetermined = 0
+			probes[j] = probe
+		}
+		hop.ProbeResult = probes
+		cliModel.Hops[i] = hop
+	}
+
+	if file, err := os.Open(TRACE_ROUTE_CLIE_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := tr.ReadCLIFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(tr, &cliModel) {
+		t.Log(&cliModel)
+		t.Log(tr)
+		t.Error("parsed CLI does not match trace route model")
+	}
+}
+
+func TestReadCLIFromTimeouts(t *testing.T) {
+	cli := "traceroute to 10.0.0.9 (10.0.0.9), 30 hops max, 40 byte packets\n" +
+		" 1  * * *\n" +
+		" 2  r1.example.net (10.0.0.1)  1.5 ms  r2.example.net (10.0.0.2)  2.25 ms *\n" +
+		" 3  10.0.0.3  3 ms !H  10.0.0.3  3.5 ms !X *\n" +
+		" 4  10.0.0.9 (10.0.0.9)  4.125 ms\n" +
+		"    10.0.0.8 (10.0.0.8)  4.5 ms !<10>\n"
+
+	failure := func(index uint) ProbeResult {
+		return ProbeResult{ProbeIndex: index, ProbeFailure: new(string)}
+	}
+
+	model := &TraceRoute{
+		TargetHost:  "10.0.0.9",
+		TargetIP:    "10.0.0.9",
+		MaxHopIndex: 30,
+		PacketSize:  40,
+		Hops: []Hop{
+			{
+				TTLValue:    1,
+				ProbeResult: []ProbeResult{failure(1), failure(2), failure(3)},
+			},
+			{
+				TTLValue:     2,
+				LastIPAddr:   "10.0.0.2",
+				LastHostName: "r2.example.net",
+				ProbeResult: []ProbeResult{
+					{
+						ProbeIndex:   1,
+						IPAddress:    "10.0.0.1",
+						HostName:     "r1.example.net",
+						ICMPType:     &ICMPType{IntegerTypeValue: 11},
+						ICMPCode:     &ICMPCode{},
+						ProbeSuccess: new(string),
+						RTT:          1500,
+					},
+					{
+						ProbeIndex:   2,
+						IPAddress:    "10.0.0.2",
+						HostName:     "r2.example.net",
+						ICMPType:     &ICMPType{IntegerTypeValue: 11},
+						ICMPCode:     &ICMPCode{},
+						ProbeSuccess: new(string),
+						RTT:          2250,
+					},
+					failure(3),
+				},
+			},
+			{
+				TTLValue:   3,
+				LastIPAddr: "10.0.0.3",
+				ProbeResult: []ProbeResult{
+					{
+						ProbeIndex:   1,
+						IPAddress:    "10.0.0.3",
+						ICMPType:     &ICMPType{IntegerTypeValue: 3},
+						ICMPCode:     &ICMPCode{IntegerCodeValue: 1},
+						ProbeSuccess: new(string),
+						RTT:          3000,
+					},
+					{
+						ProbeIndex:   2,
+						IPAddress:    "10.0.0.3",
+						ICMPType:     &ICMPType{IntegerTypeValue: 3},
+						ICMPCode:     &ICMPCode{IntegerCodeValue: 13},
+						ProbeSuccess: new(string),
+						RTT:          3500,
+					},
+					failure(3),
+				},
+			},
+			{
+				TTLValue:     4,
+				LastIPAddr:   "10.0.0.8",
+				LastHostName: "10.0.0.8",
+				ProbeResult: []ProbeResult{
+					{
+						ProbeIndex:   1,
+						IPAddress:    "10.0.0.9",
+						HostName:     "10.0.0.9",
+						ICMPType:     &ICMPType{IntegerTypeValue: 3},
+						ICMPCode:     &ICMPCode{IntegerCodeValue: 3},
+						ProbeSuccess: new(string),
+						RTT:          4125,
+					},
+					{
+						ProbeIndex:   2,
+						IPAddress:    "10.0.0.8",
+						HostName:     "10.0.0.8",
+						ICMPType:     &ICMPType{IntegerTypeValue: 3},
+						ICMPCode:     &ICMPCode{IntegerCodeValue: 10},
+						ProbeSuccess: new(string),
+						RTT:          4500,
+					},
+				},
+			},
+		},
+	}
+
+	tr := new(TraceRoute)
+	if _, err := tr.ReadCLIFrom(bytes.NewBufferString(cli)); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(tr, model) {
+		t.Log(model)
+		t.Log(tr)
+		t.Error("parsed CLI does not match trace route model")
+	}
+
+	invalid := []string{
+		" 1  10.0.0.1 (10.0.0.1)  1.5 ms\n",
+		"traceroute to 10.0.0.9 (10.0.0.9), 30 hops max, 40 byte packets\n 1  1.5 ms\n",
+		"traceroute to 10.0.0.9 (10.0.0.9), 30 hops max, 40 byte packets\n 1  * !H\n",
+	}
+	for i, input := range invalid {
+		if _, err := new(TraceRoute).ReadCLIFrom(bytes.NewBufferString(input)); err == nil {
+			t.Errorf("input %d: expected an error", i)
+		}
+	}
+}
+
 func TestWriteCLITo(t *testing.T) {
 
 	modelBuf := bytes.Buffer{}
@@ -168,9 +333,119 @@ func TestWriteCLITo(t *testing.T) {
 	}
 }
 
+func TestWriteCLIToFailures(t *testing.T) {
+	fixtures := map[string]string{
+		TRACE_ROUTE_FAILURES_XML_FILE: TRACE_ROUTE_FAILURES_CLI_FILE,
+		TRACE_ROUTE_MPLS_XML_FILE:     TRACE_ROUTE_MPLS_CLI_FILE,
+	}
+
+	for xmlFile, cliFile := range fixtures {
+		tr := new(TraceRoute)
+		if file, err := os.Open(xmlFile); err != nil {
+			t.Fatal(err)
+		} else if _, err := tr.ReadXMLFrom(file); err != nil {
+			t.Fatal(err)
+		}
+
+		cli, err := os.ReadFile(cliFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf := bytes.Buffer{}
+		if err := tr.WriteCLITo(&buf); err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(buf.Bytes(), cli) {
+			t.Errorf("CLI does not match %s\n%s", cliFile, buf.String())
+		}
+
+		// the parsed CLI renders the same output
+		parsed := new(TraceRoute)
+		buf.Reset()
+		if _, err := parsed.ReadCLIFrom(bytes.NewReader(cli)); err != nil {
+			t.Error(err)
+		} else if err := parsed.WriteCLITo(&buf); err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(buf.Bytes(), cli) {
+			t.Errorf("%s does not survive a round trip\n%s", cliFile, buf.String())
+		}
+	}
+}
+
+func TestMPLSLabels(t *testing.T) {
+	tr := new(TraceRoute)
+	if file, err := os.Open(TRACE_ROUTE_MPLS_XML_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := tr.ReadXMLFrom(file); err != nil {
+		t.Fatal(err)
+	}
+
+	labels := []MPLSLabel{{Label: 299808, TTL: 1}, {Label: 16, TTL: 1, BottomOfStack: 1}}
+	if probe := tr.Hops[1].ProbeResult[2]; !reflect.DeepEqual(probe.MPLSLabels, labels) {
+		t.Errorf("MPLSLabels = %v, should be %v", probe.MPLSLabels, labels)
+	} else if s := labels[1].String(); s != "MPLS Label=16 CoS=0 TTL=1 S=1" {
+		t.Errorf("String() = %q", s)
+	}
+
+	buf := bytes.Buffer{}
+	if _, err := tr.WriteJSONTo(&buf); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(buf.String(), `"mpls-label":[{"label":299808,"cos":0,"ttl":1,"bottom-of-stack":0},`) {
+		t.Errorf("JSON does not contain the label stack: %s", buf.String())
+	}
+
+	parsed := new(TraceRoute)
+	if _, err := parsed.ReadJSONFrom(&buf); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(parsed.Hops, tr.Hops) {
+		t.Error("label stacks do not survive a JSON round trip")
+	}
+
+	// a label stack printed once belongs to every probe before it
+	fromCLI := new(TraceRoute)
+	if file, err := os.Open(TRACE_ROUTE_MPLS_CLI_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := fromCLI.ReadCLIFrom(file); err != nil {
+		t.Fatal(err)
+	}
+	for i, hop := range tr.Hops {
+		for j, probe := range hop.ProbeResult {
+			if labels := fromCLI.Hops[i].ProbeResult[j].MPLSLabels; !reflect.DeepEqual(labels, probe.MPLSLabels) {
+				t.Errorf("hop %d probe %d: MPLSLabels = %v, should be %v", i+1, j+1, labels, probe.MPLSLabels)
+			}
+		}
+	}
+
+	// a continued line may start with a whole number of milliseconds
+	cli := "traceroute to 10.0.0.1 (10.0.0.1), 30 hops max, 40 byte packets\n" +
+		" 1  10.0.0.1 (10.0.0.1)  1.5 ms\n" +
+		"     MPLS Label=3 CoS=0 TTL=1 S=1\n" +
+		"  2 ms  1.25 ms\n"
+	if _, err := new(TraceRoute).ReadCLIFrom(bytes.NewBufferString(cli)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTraceRouteFailure(t *testing.T) {
+	cli := "traceroute: unknown host www.example.invalid\n"
+
+	tr := new(TraceRoute)
+	buf := bytes.Buffer{}
+	if _, err := tr.ReadCLIFrom(bytes.NewBufferString(cli)); err != nil {
+		t.Error(err)
+	} else if tr.TraceRouteFailure != strings.TrimSpace(cli) {
+		t.Errorf("TraceRouteFailure = %q", tr.TraceRouteFailure)
+	} else if err := tr.WriteCLITo(&buf); err != nil {
+		t.Error(err)
+	} else if buf.String() != cli {
+		t.Errorf("unexpected CLI %q", buf.String())
+	}
+}
+
 func TestNumberFormat(t *testing.T) {
-	msSlice := []uint{4635, 2639, 9478, 12850, 10227, 10796, 10612, 13286, 10605}
-	correctOutput := []string{"4.635", "2.639", "9.478", "12.85", "10.227", "10.796", "10.612", "13.286", "10.605"}
+	msSlice := []uint{4635, 2639, 9478, 12850, 10227, 10796, 10612, 13286, 10605, 1750, 10970, 2000, 500}
+	correctOutput := []string{"4.635", "2.639", "9.478", "12.850", "10.227", "10.796", "10.612", "13.286", "10.605",
+		"1.750", "10.970", "2.000", "0.500"}
 
 	for index, num := range msSlice {
 		if strNum := formatAsMs(num); strNum != correctOutput[index] {
@@ -229,3 +504,40 @@ func TestWriteJSONTo(t *testing.T) {
 		t.Error("JSON bytes not equal")
 	}
 }
+
+func TestValidate(t *testing.T) {
+	hop := traceRouteXMLModel.Hops[0]
+	if addr, err := hop.LastAddr(); err != nil || addr.String() != hop.LastIPAddr {
+		t.Errorf("LastAddr() = %v, %v", addr, err)
+	} else if addr, err := hop.ProbeResult[0].Addr(); err != nil || addr.String() != hop.ProbeResult[0].IPAddress {
+		t.Errorf("Addr() = %v, %v", addr, err)
+	}
+
+	reply := `{"target-host":"example.net","target-ip":"2001:db8::1","hop":[` +
+		`{"ttl-value":1,"last-ip-address":"fe80::1%ge-0/0/0.0","probe-result":[{"ip-address":"fe80::1%ge-0/0/0.0"}]},` +
+		`{"ttl-value":2,"last-ip-address":"2001:db8::1","probe-result":[{"ip-address":"2001:db8::1"},{"ip-address":"2001:db8:1"}]}]}`
+
+	_, err := new(TraceRoute).ReadJSONFrom(bytes.NewBufferString(reply))
+
+	var addrErr *jresponse.AddrError
+	if !errors.As(err, &addrErr) {
+		t.Errorf("expected an AddrError, got %v", err)
+	} else if addrErr.Path != "traceroute-results/hop[2]/probe-result[2]/ip-address" || addrErr.Value != "2001:db8:1" {
+		t.Errorf("unexpected AddrError %+v", addrErr)
+	}
+}
+
+func TestReadJunosJSONFrom(t *testing.T) {
+
+	tr := new(TraceRoute)
+
+	if file, err := os.Open(TRACE_ROUTE_JUNOS_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := tr.ReadJunosJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(tr, traceRouteXMLModel) {
+		t.Log(traceRouteXMLModel)
+		t.Log(tr)
+		t.Error("unmarshalled Junos JSON does not match the trace route xml model")
+	}
+}
@@ -0,0 +1,54 @@
+package jresponse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// junosDurationRegexp matches the ages and uptimes Junos prints, such as
+// "1w1d 19:44:07", "6d 18:32:08" and "00:05:12", and the shorter "1:02:03"
+// and "44:12" of BGP session uptimes.
+var junosDurationRegexp = regexp.MustCompile(`^(?:(?:(\d+)w)?(?:(\d+)d)? ?(\d+):)?(\d+):(\d{2})$`)
+
+// ParseJunosDuration parses a duration in the format Junos uses for route
+// ages and session uptimes.
+func ParseJunosDuration(s string) (time.Duration, error) {
+	m := junosDurationRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("jresponse: invalid duration %q", s)
+	}
+
+	var d time.Duration
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(m[i+1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(v) * unit
+	}
+	return d, nil
+}
+
+// FormatJunosDuration formats d, truncated to the second, the way Junos
+// prints route ages.
+func FormatJunosDuration(d time.Duration) string {
+	secs := int64(d / time.Second)
+	weeks, secs := secs/(7*86400), secs%(7*86400)
+	days, secs := secs/86400, secs%86400
+	clock := fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+
+	switch {
+	case weeks > 0:
+		return fmt.Sprintf("%dw%dd %s", weeks, days, clock)
+	case days > 0:
+		return fmt.Sprintf("%dd %s", days, clock)
+	default:
+		return clock
+	}
+}
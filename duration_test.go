@@ -0,0 +1,47 @@
+package jresponse_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+func TestJunosDuration(t *testing.T) {
+	durations := map[string]time.Duration{
+		"6d 18:32:08":   6*24*time.Hour + 18*time.Hour + 32*time.Minute + 8*time.Second,
+		"1w1d 19:44:07": 762247 * time.Second,
+		"00:05:12":      5*time.Minute + 12*time.Second,
+		"2w0d 00:00:00": 14 * 24 * time.Hour,
+	}
+
+	// BGP session uptimes drop leading zero hours, so they don't round trip
+	uptimes := map[string]time.Duration{
+		"1:02:03": time.Hour + 2*time.Minute + 3*time.Second,
+		"44:12":   44*time.Minute + 12*time.Second,
+	}
+
+	for s, d := range uptimes {
+		if parsed, err := jresponse.ParseJunosDuration(s); err != nil {
+			t.Error(err)
+		} else if parsed != d {
+			t.Errorf("ParseJunosDuration(%q) = %v, should be %v", s, parsed, d)
+		}
+	}
+
+	for s, d := range durations {
+		if parsed, err := jresponse.ParseJunosDuration(s); err != nil {
+			t.Error(err)
+		} else if parsed != d {
+			t.Errorf("ParseJunosDuration(%q) = %v, should be %v", s, parsed, d)
+		} else if formatted := jresponse.FormatJunosDuration(d); formatted != s {
+			t.Errorf("FormatJunosDuration(%v) = %q, should be %q", d, formatted, s)
+		}
+	}
+
+	for _, s := range []string{"", "5 minutes", "1w 12:00", "1d12:00:00:00"} {
+		if _, err := jresponse.ParseJunosDuration(s); err == nil {
+			t.Errorf("ParseJunosDuration(%q) should fail", s)
+		}
+	}
+}
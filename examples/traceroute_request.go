@@ -1,32 +1,29 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"log"
 	"os"
+	"time"
 
-	"github.com/JReyLBC/jresponse/command/traceroute"
+	"github.com/JReyLBC/jresponse/client"
 	"github.com/Juniper/go-netconf/netconf"
 )
 
 func main() {
 
-	rawMethod := netconf.RawMethod("<traceroute><host>8.8.8.8</host></traceroute>")
-	s := new(netconf.Session)
-	var err error
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
 
-	if s, err = netconf.DialSSH("192.168.1.1", netconf.SSHConfigPassword("username", "password")); err != nil {
+	c, err := client.Dial(ctx, "192.168.1.1", netconf.SSHConfigPassword("username", "password"))
+	if err != nil {
 		log.Fatal(err)
-	} else {
-		defer s.Close()
 	}
+	defer c.Close()
 
-	tr := new(traceroute.TraceRoute)
-	if ncReply, err := s.Exec(rawMethod); err != nil {
+	tr, err := c.Traceroute(ctx, client.TracerouteRequest{Host: "8.8.8.8"})
+	if err != nil {
 		log.Fatal(err)
-	} else if _, err := tr.ReadXMLFrom(bytes.NewBuffer([]byte(ncReply.Data))); err != nil {
-		log.Fatal(err)
-	} else {
-		tr.WriteCLITo(os.Stdout)
 	}
+	tr.WriteCLITo(os.Stdout)
 }
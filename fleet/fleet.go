@@ -0,0 +1,381 @@
+// Package fleet runs an RPC across an inventory of Junos devices, a bounded
+// number at a time, and collects each device's typed response or error.
+//
+//	e := &fleet.Executor{
+//		Config:      netconf.SSHConfigPassword("username", "password"),
+//		Concurrency: 20,
+//		Timeout:     time.Minute,
+//		Retries:     2,
+//	}
+//	results, err := e.Run(ctx, inventory, fleet.Traceroute(client.TracerouteRequest{Host: "8.8.8.8"}))
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/client"
+	"golang.org/x/crypto/ssh"
+)
+
+// Defaults used for the Executor fields left at zero.
+const (
+	DefaultConcurrency = 10
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = 30 * time.Second
+)
+
+// Device is a device of an inventory.
+type Device struct {
+	// Name keys the device's Result, and is the OriginHost of its
+	// response. It defaults to the host of Addr.
+	Name string
+	// Addr is the host to dial, with an optional port.
+	Addr string
+	// Config, when set, is used to log in to the device instead of the
+	// Executor's.
+	Config *ssh.ClientConfig
+}
+
+func (d Device) name() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	if host, _, err := net.SplitHostPort(d.Addr); err == nil {
+		return host
+	}
+	return d.Addr
+}
+
+// RPC runs an RPC on a device, returning its response.
+type RPC func(ctx context.Context, c *client.Client) (jresponse.ResponseReaderWriter, error)
+
+// Exec returns an RPC running rpc, as client.Client.Exec does, into the
+// response returned by newResponse.
+func Exec(rpc interface{}, newResponse func() jresponse.ResponseReaderWriter) RPC {
+	return func(ctx context.Context, c *client.Client) (jresponse.ResponseReaderWriter, error) {
+		resp := newResponse()
+		if err := c.Exec(ctx, rpc, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// Traceroute returns an RPC running the traceroute req.
+func Traceroute(req client.TracerouteRequest) RPC {
+	return func(ctx context.Context, c *client.Client) (jresponse.ResponseReaderWriter, error) {
+		tr, err := c.Traceroute(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return tr, nil
+	}
+}
+
+// Ping returns an RPC running the ping req.
+func Ping(req client.PingRequest) RPC {
+	return func(ctx context.Context, c *client.Client) (jresponse.ResponseReaderWriter, error) {
+		p, err := c.Ping(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+}
+
+// ShowRouteProtocolBGP returns an RPC running show route protocol bgp.
+func ShowRouteProtocolBGP(req client.ShowRouteProtocolBGPRequest) RPC {
+	return func(ctx context.Context, c *client.Client) (jresponse.ResponseReaderWriter, error) {
+		b, err := c.ShowRouteProtocolBGP(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+}
+
+// Result is the outcome of an RPC on a device: its response, or the error
+// of the last attempt.
+type Result struct {
+	Device   Device
+	Response jresponse.ResponseReaderWriter
+	Err      error
+	// Attempts is the number of times the RPC was tried, which is 0 if the
+	// run was canceled before the device's turn.
+	Attempts int
+	Elapsed  time.Duration
+}
+
+// Results are the Results of a run, keyed by the OriginHost of each
+// device, its Name.
+type Results map[string]*Result
+
+// Failed returns the Results with an error, ordered by name.
+func (rs Results) Failed() []*Result {
+	var failed []*Result
+	for _, r := range rs {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].Device.Name < failed[j].Device.Name
+	})
+	return failed
+}
+
+// Err returns the errors of the failed devices, joined, or nil if the RPC
+// succeeded everywhere.
+func (rs Results) Err() error {
+	var errs []error
+	for _, r := range rs.Failed() {
+		errs = append(errs, fmt.Errorf("%s: %w", r.Device.Name, r.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// EventKind is the kind of an Event.
+type EventKind int
+
+const (
+	// Started is sent as a device's first attempt starts.
+	Started EventKind = iota
+	// Retrying is sent when an attempt failed and another is due.
+	Retrying
+	// Finished is sent with a device's Result.
+	Finished
+)
+
+// Event reports the progress of a run.
+type Event struct {
+	Kind   EventKind
+	Device Device
+	// Attempt is the attempt that is starting, or failed when Retrying.
+	Attempt int
+	// Err is the error of the failed attempt when Retrying, and of the
+	// device when Finished.
+	Err error
+	// Result is set when Finished.
+	Result *Result
+	// Finished and Total count the devices of the run.
+	Finished, Total int
+}
+
+// Executor runs RPCs across devices.
+type Executor struct {
+	// Config is used to log in to devices without their own.
+	Config *ssh.ClientConfig
+
+	// Concurrency is the most devices an RPC runs on at a time,
+	// DefaultConcurrency if zero.
+	Concurrency int
+
+	// Timeout, when set, bounds each attempt on a device, from dialing it
+	// to decoding its reply.
+	Timeout time.Duration
+
+	// Retries is how many times an attempt is retried after failing, such
+	// as when the device can't be reached. RPCs the device itself rejects
+	// with an rpc-error, and requests failing validation, aren't retried.
+	Retries int
+
+	// Backoff is the wait before the first retry, doubled for each retry
+	// after it up to MaxBackoff. They default to DefaultBackoff and
+	// DefaultMaxBackoff.
+	Backoff, MaxBackoff time.Duration
+
+	// Progress, when set, is called with the progress of each device. The
+	// calls are made one at a time.
+	Progress func(Event)
+
+	// Dial, when set, replaces client.Dial to connect to devices, such as
+	// to dial devices through a proxy.
+	Dial func(ctx context.Context, device Device) (*client.Client, error)
+}
+
+// run is the state of an Executor's Run.
+type run struct {
+	e        *Executor
+	rpc      RPC
+	mu       sync.Mutex
+	results  Results
+	finished int
+	total    int
+}
+
+// Run runs rpc on each device of inventory, returning a Result for every
+// device, once all finished or ctx is done. Devices not tried when ctx is
+// done get its error. Run only fails if inventory names a device twice.
+func (e *Executor) Run(ctx context.Context, inventory []Device, rpc RPC) (Results, error) {
+	r := &run{e: e, rpc: rpc, results: make(Results, len(inventory)), total: len(inventory)}
+
+	devices := make([]Device, len(inventory))
+	for i, d := range inventory {
+		d.Name = d.name()
+		if _, ok := r.results[d.Name]; ok {
+			return nil, fmt.Errorf("fleet: device %s listed twice", d.Name)
+		}
+		r.results[d.Name] = nil
+		devices[i] = d
+	}
+
+	concurrency := e.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	if concurrency > len(devices) {
+		concurrency = len(devices)
+	}
+
+	queue := make(chan Device)
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range queue {
+				r.device(ctx, d)
+			}
+		}()
+	}
+
+	for _, d := range devices {
+		select {
+		case <-ctx.Done():
+			r.finish(&Result{Device: d, Err: ctx.Err()})
+		default:
+			select {
+			case queue <- d:
+			case <-ctx.Done():
+				r.finish(&Result{Device: d, Err: ctx.Err()})
+			}
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return r.results, nil
+}
+
+// device runs the RPC on d, retrying failed attempts.
+func (r *run) device(ctx context.Context, d Device) {
+	result := &Result{Device: d}
+	start := time.Now()
+	defer func() {
+		result.Elapsed = time.Since(start)
+		r.finish(result)
+	}()
+
+	for {
+		result.Attempts++
+		if result.Attempts == 1 {
+			r.progress(Event{Kind: Started, Device: d, Attempt: 1})
+		}
+
+		result.Response, result.Err = r.attempt(ctx, d)
+		if result.Err == nil || result.Attempts > r.e.Retries || !retryable(ctx, result.Err) {
+			return
+		}
+
+		r.progress(Event{Kind: Retrying, Device: d, Attempt: result.Attempts, Err: result.Err})
+
+		select {
+		case <-time.After(r.e.backoff(result.Attempts)):
+		case <-ctx.Done():
+			result.Err = ctx.Err()
+			return
+		}
+	}
+}
+
+// attempt dials d and runs the RPC on it once.
+func (r *run) attempt(ctx context.Context, d Device) (jresponse.ResponseReaderWriter, error) {
+	if r.e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.e.Timeout)
+		defer cancel()
+	}
+
+	c, err := r.e.dial(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	return r.rpc(ctx, c)
+}
+
+func (e *Executor) dial(ctx context.Context, d Device) (*client.Client, error) {
+	if e.Dial != nil {
+		return e.Dial(ctx, d)
+	}
+
+	config := d.Config
+	if config == nil {
+		config = e.Config
+	}
+
+	c, err := client.Dial(ctx, d.Addr, config)
+	if err != nil {
+		return nil, err
+	}
+	c.SetHost(d.Name)
+	return c, nil
+}
+
+// backoff returns the wait after the failed attempt.
+func (e *Executor) backoff(attempt int) time.Duration {
+	wait, max := e.Backoff, e.MaxBackoff
+	if wait <= 0 {
+		wait = DefaultBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	for i := 1; i < attempt && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	return wait
+}
+
+// retryable reports whether another attempt might succeed where one failed
+// with err. The device answering with an rpc-error, or a request failing
+// validation, would fail the same way again.
+func retryable(ctx context.Context, err error) bool {
+	var rpcErr *jresponse.RPCError
+	var optErr *client.OptionError
+	return ctx.Err() == nil && !errors.As(err, &rpcErr) && !errors.As(err, &optErr)
+}
+
+func (r *run) progress(event Event) {
+	if r.e.Progress == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event.Finished, event.Total = r.finished, r.total
+	r.e.Progress(event)
+}
+
+func (r *run) finish(result *Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results[result.Device.Name] = result
+	r.finished++
+	if r.e.Progress != nil {
+		r.e.Progress(Event{Kind: Finished, Device: result.Device, Attempt: result.Attempts, Err: result.Err, Result: result, Finished: r.finished, Total: r.total})
+	}
+}
@@ -0,0 +1,262 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/client"
+	"github.com/JReyLBC/jresponse/command/traceroute"
+	"github.com/JReyLBC/jresponse/netconftest"
+)
+
+// newFleet starts a fake device for each name, returning the inventory to
+// reach them.
+func newFleet(t *testing.T, names ...string) ([]Device, map[string]*netconftest.Server) {
+	var inventory []Device
+	servers := make(map[string]*netconftest.Server)
+
+	for _, name := range names {
+		s := netconftest.NewServer()
+		t.Cleanup(s.Close)
+		servers[name] = s
+		inventory = append(inventory, Device{Name: name, Addr: s.Addr, Config: s.ClientConfig()})
+	}
+	return inventory, servers
+}
+
+var tracerouteRPC = Traceroute(client.TracerouteRequest{Host: "8.8.8.8"})
+
+func TestRun(t *testing.T) {
+
+	inventory, servers := newFleet(t, "pe1", "pe2", "p1")
+
+	failure := jresponse.RPCError{Type: "application", Tag: "operation-failed", Severity: jresponse.SeverityError, Message: "no route to host"}
+	servers["p1"].SetReply("traceroute", netconftest.Reply{Errors: []jresponse.RPCError{failure}})
+
+	var events []Event
+	e := &Executor{Retries: 2, Backoff: time.Millisecond, Progress: func(event Event) {
+		events = append(events, event)
+	}}
+
+	results, err := e.Run(context.Background(), inventory, tracerouteRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results", len(results))
+	}
+	for _, name := range []string{"pe1", "pe2"} {
+		r := results[name]
+		if r.Err != nil {
+			t.Fatalf("%s: %v", name, r.Err)
+		}
+		tr := r.Response.(*traceroute.TraceRoute)
+		if tr.OriginHost != name || tr.OriginIP != "127.0.0.1" || tr.TargetHost != "8.8.8.8" || r.Attempts != 1 {
+			t.Errorf("%s: got target %q from %q (%q) after %d attempts", name, tr.TargetHost, tr.OriginHost, tr.OriginIP, r.Attempts)
+		}
+	}
+
+	// the device rejecting the rpc isn't retried
+	var rpcErr *jresponse.RPCError
+	if r := results["p1"]; !errors.As(r.Err, &rpcErr) || rpcErr.Message != failure.Message || r.Attempts != 1 || r.Response != nil {
+		t.Errorf("p1: got %+v", r)
+	}
+	if failed := results.Failed(); len(failed) != 1 || failed[0].Device.Name != "p1" {
+		t.Errorf("got failed %+v", failed)
+	}
+	if err := results.Err(); err == nil || err.Error() != "p1: rpc error: no route to host" {
+		t.Errorf("got %v", err)
+	}
+
+	kinds := make(map[EventKind]int)
+	for _, event := range events {
+		kinds[event.Kind]++
+		if event.Total != 3 {
+			t.Errorf("got event %+v", event)
+		}
+	}
+	if kinds[Started] != 3 || kinds[Retrying] != 0 || kinds[Finished] != 3 || events[len(events)-1].Finished != 3 {
+		t.Errorf("got events %+v", events)
+	}
+}
+
+func TestRunDuplicate(t *testing.T) {
+
+	inventory := []Device{{Addr: "192.0.2.1"}, {Addr: "192.0.2.1:830"}}
+	if _, err := new(Executor).Run(context.Background(), inventory, tracerouteRPC); err == nil {
+		t.Error("expected an error running on a device listed twice")
+	}
+}
+
+func TestRetries(t *testing.T) {
+
+	inventory, _ := newFleet(t, "pe1", "pe2")
+
+	// pe1 can be reached on the third attempt, pe2 never
+	mu := sync.Mutex{}
+	attempts := make(map[string]int)
+	var events []Event
+
+	e := &Executor{
+		Retries:    2,
+		Backoff:    10 * time.Millisecond,
+		MaxBackoff: 15 * time.Millisecond,
+		Dial: func(ctx context.Context, d Device) (*client.Client, error) {
+			mu.Lock()
+			attempts[d.Name]++
+			n := attempts[d.Name]
+			mu.Unlock()
+
+			if d.Name == "pe2" || n < 3 {
+				return nil, fmt.Errorf("dial %s: connection refused", d.Addr)
+			}
+			c, err := client.Dial(ctx, d.Addr, d.Config)
+			if err == nil {
+				c.SetHost(d.Name)
+			}
+			return c, err
+		},
+		Progress: func(event Event) {
+			events = append(events, event)
+		},
+	}
+
+	start := time.Now()
+	results, err := e.Run(context.Background(), inventory, tracerouteRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// backoffs of 10ms, then 15ms
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("retried after %v", elapsed)
+	}
+
+	if r := results["pe1"]; r.Err != nil || r.Attempts != 3 || r.Response.(*traceroute.TraceRoute).OriginHost != "pe1" {
+		t.Errorf("pe1: got %+v", r)
+	}
+	if r := results["pe2"]; r.Err == nil || r.Attempts != 3 || r.Response != nil {
+		t.Errorf("pe2: got %+v", r)
+	}
+
+	retries := 0
+	for _, event := range events {
+		if event.Kind == Retrying {
+			retries++
+			if event.Err == nil || event.Attempt > 2 {
+				t.Errorf("got event %+v", event)
+			}
+		}
+	}
+	if retries != 4 {
+		t.Errorf("got %d retries, want 4", retries)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+
+	inventory, servers := newFleet(t, "pe1", "pe2")
+	servers["pe2"].SetReply("traceroute", netconftest.Reply{Data: "<traceroute-results/>", Delay: time.Minute})
+
+	e := &Executor{Timeout: 200 * time.Millisecond, Retries: 1, Backoff: time.Millisecond}
+	results, err := e.Run(context.Background(), inventory, tracerouteRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := results["pe1"]; r.Err != nil {
+		t.Errorf("pe1: %v", r.Err)
+	}
+	if r := results["pe2"]; !errors.Is(r.Err, context.DeadlineExceeded) || r.Attempts != 2 {
+		t.Errorf("pe2: got %+v", r)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+
+	inventory, _ := newFleet(t, "pe1", "pe2", "pe3", "pe4", "pe5")
+
+	mu := sync.Mutex{}
+	running, most := 0, 0
+
+	e := &Executor{Concurrency: 2}
+	rpc := func(ctx context.Context, c *client.Client) (jresponse.ResponseReaderWriter, error) {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		return tracerouteRPC(ctx, c)
+	}
+
+	results, err := e.Run(context.Background(), inventory, rpc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := results.Err(); err != nil {
+		t.Error(err)
+	}
+	if most != 2 {
+		t.Errorf("ran on %d devices at a time, want 2", most)
+	}
+}
+
+func TestCancel(t *testing.T) {
+
+	inventory, servers := newFleet(t, "pe1", "pe2", "pe3")
+	for _, s := range servers {
+		s.SetReply("traceroute", netconftest.Reply{Data: "<traceroute-results/>", Delay: time.Minute})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Executor{Concurrency: 1, Retries: 3, Progress: func(event Event) {
+		if event.Kind == Started {
+			cancel()
+		}
+	}}
+
+	results, err := e.Run(ctx, inventory, tracerouteRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tried := 0
+	for name, r := range results {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("%s: got %v, want %v", name, r.Err, context.Canceled)
+		}
+		tried += r.Attempts
+	}
+	if tried != 1 {
+		t.Errorf("got %d attempts, want 1", tried)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+
+	e := &Executor{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got := e.backoff(attempt + 1); got != want*time.Millisecond {
+			t.Errorf("attempt %d: got %v, want %v", attempt+1, got, want*time.Millisecond)
+		}
+	}
+
+	if got := new(Executor).backoff(1); got != DefaultBackoff {
+		t.Errorf("got %v, want %v", got, DefaultBackoff)
+	}
+}
@@ -0,0 +1,300 @@
+package jresponse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Format identifies a representation responses can be encoded to and
+// decoded from.
+type Format string
+
+const (
+	XML  Format = "xml"
+	JSON Format = "json"
+	CLI  Format = "cli"
+
+	// JunosJSON is the JSON Junos itself encodes replies as, from
+	// "| display json" or its REST API. It has no MIME type of its own, as
+	// Junos serves it as application/json.
+	JunosJSON Format = "junos-json"
+)
+
+// ErrUnsupportedFormat is returned when no codec exists for a format and
+// response type.
+var ErrUnsupportedFormat = errors.New("jresponse: unsupported format")
+
+// EncodeFunc writes resp to w in a particular format.
+type EncodeFunc func(w io.Writer, resp interface{}) (n int64, err error)
+
+// DecodeFunc reads resp from r in a particular format.
+type DecodeFunc func(r io.Reader, resp interface{}) (n int64, err error)
+
+type codecKey struct {
+	format Format
+	typ    reflect.Type
+}
+
+type codec struct {
+	encode EncodeFunc
+	decode DecodeFunc
+}
+
+var (
+	formatMu  sync.RWMutex
+	mimeTypes = map[Format][]string{
+		XML:  {"application/xml", "text/xml"},
+		JSON: {"application/json"},
+		CLI:  {"text/plain"},
+	}
+	codecs = map[codecKey]codec{}
+)
+
+// RegisterFormat associates MIME types with f, so that f can be negotiated
+// from an Accept header. The first MIME type registered for a format is the
+// one returned by its MIMEType method.
+func RegisterFormat(f Format, types ...string) {
+	formatMu.Lock()
+	defer formatMu.Unlock()
+
+	for _, t := range types {
+		mimeTypes[f] = append(mimeTypes[f], strings.ToLower(t))
+	}
+}
+
+// RegisterCodec registers the functions used to encode and decode responses
+// of the same type as resp in format f. Either function may be nil when the
+// format is write or read only. Response packages use this to support
+// formats beyond XML, JSON and CLI, or to override them.
+func RegisterCodec(f Format, resp interface{}, encode EncodeFunc, decode DecodeFunc) {
+	formatMu.Lock()
+	defer formatMu.Unlock()
+
+	codecs[codecKey{f, reflect.TypeOf(resp)}] = codec{encode, decode}
+}
+
+func lookupCodec(f Format, resp interface{}) (codec, bool) {
+	formatMu.RLock()
+	defer formatMu.RUnlock()
+
+	c, ok := codecs[codecKey{f, reflect.TypeOf(resp)}]
+	return c, ok
+}
+
+// MIMEType returns the preferred MIME type for f, or an empty string when
+// none is registered.
+func (f Format) MIMEType() string {
+	formatMu.RLock()
+	defer formatMu.RUnlock()
+
+	if types := mimeTypes[f]; len(types) > 0 {
+		return types[0]
+	}
+	return ""
+}
+
+// FormatForMIME returns the format registered for the MIME type, ignoring
+// any parameters such as charset.
+func FormatForMIME(mimeType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return "", false
+	}
+
+	formatMu.RLock()
+	defer formatMu.RUnlock()
+
+	// iterate in a fixed order so a MIME type registered for two
+	// formats always resolves the same way
+	formats := make([]string, 0, len(mimeTypes))
+	for f := range mimeTypes {
+		formats = append(formats, string(f))
+	}
+	sort.Strings(formats)
+
+	for _, f := range formats {
+		for _, t := range mimeTypes[Format(f)] {
+			if t == mediaType {
+				return Format(f), true
+			}
+		}
+	}
+	return "", false
+}
+
+// Negotiate picks the format best matching an HTTP Accept header, honoring
+// quality values and wildcards. As in RFC 9110, each format takes the
+// quality of the most specific media range matching it, so
+// "application/json;q=0, */*" excludes JSON. Formats of equal quality are
+// ordered by the media range that matched them, then by the order they're
+// offered in. The first of the offered formats is chosen when the header is
+// empty; false is returned when none of the offered formats is acceptable.
+func Negotiate(accept string, offered ...Format) (Format, bool) {
+	if len(offered) == 0 {
+		offered = []Format{JSON, XML, CLI}
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offered[0], true
+	}
+
+	ranges := parseAccept(accept)
+
+	var (
+		best      Format
+		bestQ     float64
+		bestIndex int
+	)
+
+	for _, f := range offered {
+		if q, index := acceptQuality(ranges, f); q > bestQ || (q > 0 && q == bestQ && index < bestIndex) {
+			best, bestQ, bestIndex = f, q, index
+		}
+	}
+
+	return best, bestQ > 0
+}
+
+// mediaRange is a media range from an Accept header, with its quality.
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept returns the media ranges of an Accept header in the order
+// they appear, skipping any that are malformed.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if qStr, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qStr, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType, q})
+	}
+
+	return ranges
+}
+
+// acceptQuality returns the quality the media ranges give f, the highest
+// of those given to each MIME type registered for it, along with the index
+// of the range it came from. A MIME type takes the quality of the most
+// specific range matching it, or the first of several equally specific
+// ones. The index is -1 when no range matches f.
+func acceptQuality(ranges []mediaRange, f Format) (q float64, index int) {
+	formatMu.RLock()
+	defer formatMu.RUnlock()
+
+	index = -1
+	for _, t := range mimeTypes[f] {
+		match, matchSpecificity := -1, 0
+		for i, r := range ranges {
+			if s := specificity(r.mediaType, t); s > matchSpecificity {
+				match, matchSpecificity = i, s
+			}
+		}
+
+		if match < 0 {
+			continue
+		} else if mq := ranges[match].q; index < 0 || mq > q || (mq == q && match < index) {
+			q, index = mq, match
+		}
+	}
+	return q, index
+}
+
+// specificity reports how specifically a media range, which may contain
+// wildcards, matches the MIME type t: 3 for t itself, 2 for its type/*
+// range and 1 for */*, or 0 when it doesn't match t.
+func specificity(mediaRange, t string) int {
+	switch {
+	case mediaRange == t:
+		return 3
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(t, strings.TrimSuffix(mediaRange, "*")):
+		return 2
+	case mediaRange == "*/*":
+		return 1
+	}
+	return 0
+}
+
+// countingWriter counts the bytes written through it, for writers such as
+// WriteCLITo that don't report a count themselves.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// Encode writes resp to w in format f, using a codec registered for the
+// response type if there is one.
+func Encode(w io.Writer, resp interface{}, f Format) (n int64, err error) {
+	if c, ok := lookupCodec(f, resp); ok && c.encode != nil {
+		return c.encode(w, resp)
+	}
+
+	writer, ok := resp.(JResponseWriter)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s for %T", ErrUnsupportedFormat, f, resp)
+	}
+
+	switch f {
+	case XML:
+		return writer.WriteXMLTo(w)
+	case JSON:
+		return writer.WriteJSONTo(w)
+	case CLI:
+		cw := &countingWriter{w: w}
+		err = writer.WriteCLITo(cw)
+		return cw.n, err
+	case JunosJSON:
+		return WriteJunosJSON(w, writer)
+	default:
+		return 0, fmt.Errorf("%w: %s for %T", ErrUnsupportedFormat, f, resp)
+	}
+}
+
+// Decode reads resp from r in format f, using a codec registered for the
+// response type if there is one.
+func Decode(r io.Reader, resp interface{}, f Format) (n int64, err error) {
+	if c, ok := lookupCodec(f, resp); ok && c.decode != nil {
+		return c.decode(r, resp)
+	}
+
+	reader, ok := resp.(JResponseReader)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s for %T", ErrUnsupportedFormat, f, resp)
+	}
+
+	switch f {
+	case XML:
+		return reader.ReadXMLFrom(r)
+	case JSON:
+		return reader.ReadJSONFrom(r)
+	case CLI:
+		return reader.ReadCLIFrom(r)
+	case JunosJSON:
+		return ReadJunosJSONFrom(r, reader)
+	default:
+		return 0, fmt.Errorf("%w: %s for %T", ErrUnsupportedFormat, f, resp)
+	}
+}
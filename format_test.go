@@ -0,0 +1,153 @@
+package jresponse_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/command/ping"
+	"github.com/JReyLBC/jresponse/command/traceroute"
+	"github.com/JReyLBC/jresponse/show/route/protocol/bgp"
+)
+
+const PING_XML_FILE = "command/ping/ping_8.8.8.8.xml"
+
+var (
+	_ jresponse.ResponseReaderWriter = new(ping.Ping)
+	_ jresponse.ResponseReaderWriter = new(traceroute.TraceRoute)
+	_ jresponse.ResponseReaderWriter = new(bgproute.BGPRoute)
+)
+
+func TestEncodeDecode(t *testing.T) {
+
+	p := new(ping.Ping)
+
+	if file, err := os.Open(PING_XML_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := jresponse.Decode(file, p, jresponse.XML); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []jresponse.Format{jresponse.XML, jresponse.JSON, jresponse.CLI, jresponse.JunosJSON} {
+		encodeBuf, writeBuf := bytes.Buffer{}, bytes.Buffer{}
+
+		n, err := jresponse.Encode(&encodeBuf, p, f)
+		if err != nil {
+			t.Error(err)
+			continue
+		} else if n != int64(encodeBuf.Len()) {
+			t.Errorf("%s: Encode returned %d bytes, wrote %d", f, n, encodeBuf.Len())
+		}
+
+		switch f {
+		case jresponse.XML:
+			_, err = p.WriteXMLTo(&writeBuf)
+		case jresponse.JSON:
+			_, err = p.WriteJSONTo(&writeBuf)
+		case jresponse.CLI:
+			err = p.WriteCLITo(&writeBuf)
+		case jresponse.JunosJSON:
+			_, err = p.WriteJunosJSONTo(&writeBuf)
+		}
+		if err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(encodeBuf.Bytes(), writeBuf.Bytes()) {
+			t.Errorf("%s: Encode output does not match the response's writer", f)
+		}
+	}
+
+	if _, err := jresponse.Encode(io.Discard, p, jresponse.Format("yaml")); !errors.Is(err, jresponse.ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+	if _, err := jresponse.Decode(bytes.NewBufferString(""), p, jresponse.Format("yaml")); !errors.Is(err, jresponse.ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+
+	summary := jresponse.Format("summary")
+	jresponse.RegisterFormat(summary, "text/x-ping-summary")
+	jresponse.RegisterCodec(summary, new(ping.Ping),
+		func(w io.Writer, resp interface{}) (int64, error) {
+			p := resp.(*ping.Ping)
+			n, err := fmt.Fprintf(w, "%s %d/%d", p.TargetHost,
+				p.ProbeResultsSummary.ResponsesReceived, p.ProbeResultsSummary.ProbesSent)
+			return int64(n), err
+		}, nil)
+
+	p := &ping.Ping{TargetHost: "8.8.8.8"}
+	p.ProbeResultsSummary.ProbesSent = 5
+	p.ProbeResultsSummary.ResponsesReceived = 4
+
+	buf := bytes.Buffer{}
+	if _, err := jresponse.Encode(&buf, p, summary); err != nil {
+		t.Error(err)
+	} else if buf.String() != "8.8.8.8 4/5" {
+		t.Errorf("unexpected summary output %q", buf.String())
+	}
+
+	// only the ping type has the codec
+	if _, err := jresponse.Encode(io.Discard, new(traceroute.TraceRoute), summary); err == nil {
+		t.Error("expected an error encoding a traceroute as a ping summary")
+	}
+
+	if f, ok := jresponse.FormatForMIME("text/x-ping-summary"); !ok || f != summary {
+		t.Errorf("MIME type resolved to %q, %v", f, ok)
+	}
+}
+
+func TestMIMEType(t *testing.T) {
+	types := map[jresponse.Format]string{
+		jresponse.XML:  "application/xml",
+		jresponse.JSON: "application/json",
+		jresponse.CLI:  "text/plain",
+	}
+
+	for f, mimeType := range types {
+		if m := f.MIMEType(); m != mimeType {
+			t.Errorf("%s.MIMEType() = %s, should be %s", f, m, mimeType)
+		} else if ff, ok := jresponse.FormatForMIME(mimeType + "; charset=utf-8"); !ok || ff != f {
+			t.Errorf("FormatForMIME(%s) = %s, should be %s", mimeType, ff, f)
+		}
+	}
+
+	if _, ok := jresponse.FormatForMIME("image/png"); ok {
+		t.Error("image/png should not map to a format")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		format jresponse.Format
+		ok     bool
+	}{
+		{"", jresponse.JSON, true},
+		{"*/*", jresponse.JSON, true},
+		{"application/xml", jresponse.XML, true},
+		{"text/plain;q=0.5, application/json;q=0.9", jresponse.JSON, true},
+		{"text/*", jresponse.XML, true},
+		{"text/html, text/plain;q=0.1", jresponse.CLI, true},
+		{"image/png", "", false},
+		{"application/json;q=0", "", false},
+		{"application/json;q=0, */*", jresponse.XML, true},
+		{"*/*;q=0.1, text/*;q=0, text/plain", jresponse.CLI, true},
+		{"text/*;q=0.2, */*;q=0.5", jresponse.JSON, true},
+		{"application/xml, application/json", jresponse.XML, true},
+	}
+
+	for _, test := range tests {
+		if f, ok := jresponse.Negotiate(test.accept); f != test.format || ok != test.ok {
+			t.Errorf("Negotiate(%q) = %q, %v; should be %q, %v", test.accept, f, ok, test.format, test.ok)
+		}
+	}
+
+	if f, ok := jresponse.Negotiate("*/*", jresponse.CLI); !ok || f != jresponse.CLI {
+		t.Errorf("Negotiate with offered formats = %q, %v", f, ok)
+	}
+}
@@ -13,6 +13,7 @@ type JResponseWriter interface {
 type JResponseReader interface {
 	ReadXMLFrom(r io.Reader) (n int64, err error)
 	ReadJSONFrom(r io.Reader) (n int64, err error)
+	ReadCLIFrom(r io.Reader) (n int64, err error)
 }
 
 type ResponseReaderWriter interface {
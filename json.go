@@ -0,0 +1,170 @@
+package jresponse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONOptions selects alternative encodings for the time related fields of
+// a response's JSON.
+type JSONOptions struct {
+	// ISO8601Timestamps writes timestamps, such as date-determined, as
+	// RFC 3339 strings in UTC instead of seconds since the epoch.
+	ISO8601Timestamps bool
+
+	// MillisecondDurations writes durations, such as rtt and age-seconds,
+	// as milliseconds. Their names get a "-ms" suffix, replacing a
+	// "-seconds" suffix, so consumers can't mistake the unit.
+	MillisecondDurations bool
+}
+
+// JSONTimeFields describes the time related JSON fields of a response type.
+type JSONTimeFields struct {
+	// Timestamps are the names of fields holding seconds since the epoch.
+	Timestamps []string
+
+	// Durations maps the names of fields holding durations to their unit.
+	Durations map[string]time.Duration
+}
+
+func (fields JSONTimeFields) isTimestamp(name string) bool {
+	for _, ts := range fields.Timestamps {
+		if ts == name {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSONWithOptions writes v as JSON to w, encoding the time related
+// fields described by fields as selected by opts. Response packages use it
+// to implement WriteJSONToWithOptions.
+func WriteJSONWithOptions(w io.Writer, v interface{}, fields JSONTimeFields, opts JSONOptions) (n int64, err error) {
+	s, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.ISO8601Timestamps || opts.MillisecondDurations {
+		if s, err = rewriteJSON(s, fields, opts); err != nil {
+			return 0, err
+		}
+	}
+
+	return bytes.NewBuffer(s).WriteTo(w)
+}
+
+// rewriteJSON re-encodes the JSON document p token by token, preserving
+// field order, and converts the time related fields on the way.
+func rewriteJSON(p []byte, fields JSONTimeFields, opts JSONOptions) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(p))
+	d.UseNumber()
+
+	// key is the key of the object's current value, or for an array the
+	// key of the field holding it, which its elements are converted by
+	type container struct {
+		object bool
+		count  int
+		key    string
+	}
+
+	var (
+		out   bytes.Buffer
+		stack []container
+	)
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return out.Bytes(), nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		// separators before keys and array elements; object values follow
+		// their key directly
+		isKey, key := false, ""
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			key = top.key
+			if tok != json.Delim('}') && tok != json.Delim(']') {
+				if !top.object || top.count%2 == 0 {
+					if top.count > 0 {
+						out.WriteByte(',')
+					}
+					isKey = top.object
+				} else {
+					out.WriteByte(':')
+				}
+				top.count++
+			}
+		}
+
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{':
+				stack = append(stack, container{object: true})
+			case '[':
+				stack = append(stack, container{key: key})
+			case '}', ']':
+				stack = stack[:len(stack)-1]
+			}
+			out.WriteRune(rune(delim))
+			continue
+		}
+
+		var value interface{} = tok
+		if isKey {
+			key = tok.(string)
+			stack[len(stack)-1].key = key
+			if _, ok := fields.Durations[key]; ok && opts.MillisecondDurations {
+				value = strings.TrimSuffix(key, "-seconds") + "-ms"
+			}
+		} else if value, err = convertJSONValue(key, tok, fields, opts); err != nil {
+			return nil, err
+		}
+
+		s, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(s)
+	}
+}
+
+// convertJSONValue converts the value of the field named key, if it is a
+// timestamp or duration and opts asks for it.
+func convertJSONValue(key string, tok json.Token, fields JSONTimeFields, opts JSONOptions) (interface{}, error) {
+	var raw string
+	switch tok := tok.(type) {
+	case json.Number:
+		raw = tok.String()
+	case string:
+		raw = strings.TrimSpace(tok)
+	default:
+		return tok, nil
+	}
+
+	if unit, ok := fields.Durations[key]; ok && opts.MillisecondDurations {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("jresponse: invalid duration %s: %q", key, raw)
+		}
+		return json.Number(strconv.FormatFloat(v*float64(unit)/float64(time.Millisecond), 'f', -1, 64)), nil
+	}
+
+	if fields.isTimestamp(key) && opts.ISO8601Timestamps {
+		secs, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("jresponse: invalid timestamp %s: %q", key, raw)
+		}
+		return time.Unix(secs, 0).UTC().Format(time.RFC3339), nil
+	}
+
+	return tok, nil
+}
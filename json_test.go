@@ -0,0 +1,76 @@
+package jresponse_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+func TestWriteJSONWithOptions(t *testing.T) {
+	type probe struct {
+		Date  uint   `json:"date-determined"`
+		RTT   uint   `json:"rtt"`
+		Age   string `json:"age-seconds"`
+		Other []int  `json:"other"`
+	}
+	v := struct {
+		Host   string  `json:"target-host"`
+		Probes []probe `json:"probe-result"`
+	}{"8.8.8.8", []probe{{1447350764, 690, "585128", []int{1, 2}}}}
+
+	fields := jresponse.JSONTimeFields{
+		Timestamps: []string{"date-determined"},
+		Durations:  map[string]time.Duration{"rtt": time.Microsecond, "age-seconds": time.Second},
+	}
+
+	tests := []struct {
+		opts jresponse.JSONOptions
+		json string
+	}{
+		{
+			jresponse.JSONOptions{},
+			`{"target-host":"8.8.8.8","probe-result":[{"date-determined":1447350764,"rtt":690,"age-seconds":"585128","other":[1,2]}]}`,
+		},
+		{
+			jresponse.JSONOptions{ISO8601Timestamps: true},
+			`{"target-host":"8.8.8.8","probe-result":[{"date-determined":"2015-11-12T17:52:44Z","rtt":690,"age-seconds":"585128","other":[1,2]}]}`,
+		},
+		{
+			jresponse.JSONOptions{ISO8601Timestamps: true, MillisecondDurations: true},
+			`{"target-host":"8.8.8.8","probe-result":[{"date-determined":"2015-11-12T17:52:44Z","rtt-ms":0.69,"age-ms":585128000,"other":[1,2]}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		buf := bytes.Buffer{}
+		if n, err := jresponse.WriteJSONWithOptions(&buf, v, fields, test.opts); err != nil {
+			t.Error(err)
+		} else if buf.String() != test.json {
+			t.Errorf("%+v: unexpected JSON\n%s\nshould be\n%s", test.opts, buf.String(), test.json)
+		} else if n != int64(buf.Len()) {
+			t.Errorf("returned %d bytes, wrote %d", n, buf.Len())
+		}
+	}
+
+	// an array's elements are converted by the key holding the array, not
+	// the last key of an object before them
+	nested := struct {
+		Values []interface{} `json:"values"`
+		RTTs   []uint        `json:"rtt"`
+	}{[]interface{}{map[string]uint{"rtt": 690}, 690}, []uint{1500}}
+	buf := bytes.Buffer{}
+	if _, err := jresponse.WriteJSONWithOptions(&buf, nested, fields, jresponse.JSONOptions{MillisecondDurations: true}); err != nil {
+		t.Error(err)
+	} else if want := `{"values":[{"rtt-ms":0.69},690],"rtt-ms":[1.5]}`; buf.String() != want {
+		t.Errorf("unexpected JSON\n%s\nshould be\n%s", buf.String(), want)
+	}
+
+	bad := struct {
+		RTT string `json:"rtt"`
+	}{"fast"}
+	if _, err := jresponse.WriteJSONWithOptions(&bytes.Buffer{}, bad, fields, jresponse.JSONOptions{MillisecondDurations: true}); err == nil {
+		t.Error("expected an error for a non-numeric duration")
+	}
+}
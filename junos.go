@@ -0,0 +1,86 @@
+package jresponse
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// JunosPrefix is the prefix Junos declares for its own namespace, which
+// qualifies attributes such as junos:style, junos:seconds and junos:format.
+const JunosPrefix = "junos"
+
+// JunosAttr is the value of an attribute in the junos namespace. It decodes
+// the attribute whatever Junos release its namespace names, or when the
+// prefix isn't declared at all, and encodes it with the junos prefix, so
+// the XML written by a response matches what the device sent.
+type JunosAttr string
+
+// MarshalXMLAttr encodes a as junos:name. An empty a is left out.
+func (a JunosAttr) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	if a == "" {
+		return xml.Attr{}, nil
+	}
+
+	// encoding/xml declares a new prefix on every element for attributes
+	// with a namespace, so the prefix is written as part of the name
+	return xml.Attr{Name: xml.Name{Local: JunosPrefix + ":" + name.Local}, Value: string(a)}, nil
+}
+
+// XMLNS is a namespace declaration for a prefix, such as the xmlns:junos
+// of a response's root element. Fields of this type are tagged with the
+// xmlns space and the prefix, e.g. `xml:"xmlns junos,attr,omitempty"`.
+// Responses keep the xmlns:junos qualifying attributes like junos:style and
+// junos:seconds in a JunosNS field, empty when the reply didn't declare it.
+type XMLNS string
+
+// MarshalXMLAttr encodes ns as xmlns:name. An empty ns is left out.
+func (ns XMLNS) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	if ns == "" {
+		return xml.Attr{}, nil
+	}
+	return xml.Attr{Name: xml.Name{Local: "xmlns:" + name.Local}, Value: string(ns)}, nil
+}
+
+// WriteXML writes the response v to w as XML. Unlike xml.Marshal, it keeps
+// the namespace of name, the XMLName v was decoded with, on the root
+// element. When name is empty the root element is named by v's XMLName
+// tag. When junos attributes are written but v doesn't declare the junos
+// prefix, e.g. because it was read from CLI or JSON, the root element
+// declares it as JunosNamespace(JunosPrefix). Response packages
+// use it to implement WriteXMLTo.
+func WriteXML(w io.Writer, v interface{}, name xml.Name) (n int64, err error) {
+	buf := bytes.Buffer{}
+	enc := xml.NewEncoder(&buf)
+
+	if name.Local == "" {
+		err = enc.Encode(v)
+	} else {
+		err = enc.EncodeElement(v, xml.StartElement{Name: name})
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// encoding/xml always writes a start and an end tag, and escapes > in
+	// attribute values, so the root start tag ends at the first >
+	p := buf.Bytes()
+	end := bytes.IndexByte(p, '>')
+	if end < 0 || !bytes.Contains(p, []byte(" "+JunosPrefix+":")) ||
+		bytes.Contains(p[:end], []byte(" xmlns:"+JunosPrefix+"=")) {
+		return buf.WriteTo(w)
+	}
+
+	// the declaration goes right after the root's name
+	at := bytes.IndexByte(p[:end], ' ')
+	if at < 0 {
+		at = end
+	}
+	decl := " xmlns:" + JunosPrefix + `="` + JunosNamespace(JunosPrefix) + `"`
+	out := bytes.Buffer{}
+	out.Grow(len(p) + len(decl))
+	out.Write(p[:at])
+	out.WriteString(decl)
+	out.Write(p[at:])
+	return out.WriteTo(w)
+}
@@ -0,0 +1,67 @@
+package jresponse_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+type junosElement struct {
+	XMLName xml.Name            `xml:"rt"`
+	JunosNS jresponse.XMLNS     `xml:"xmlns junos,attr,omitempty"`
+	Style   jresponse.JunosAttr `xml:"style,attr,omitempty"`
+	Seconds jresponse.JunosAttr `xml:"seconds,attr"`
+}
+
+func TestJunosAttr(t *testing.T) {
+	docs := map[string]string{
+		`<rt xmlns:junos="http://xml.juniper.net/junos/12.3R6/junos" junos:style="brief" junos:seconds="312"/>`: `<rt xmlns:junos="http://xml.juniper.net/junos/12.3R6/junos" junos:style="brief" junos:seconds="312"></rt>`,
+		`<rt xmlns:junos="http://xml.juniper.net/junos/15.1R7/junos" junos:seconds="312" junos:style="brief"/>`: `<rt xmlns:junos="http://xml.juniper.net/junos/15.1R7/junos" junos:style="brief" junos:seconds="312"></rt>`,
+		`<rt junos:style="brief" junos:seconds="312"/>`:                                                         `<rt xmlns:junos="http://xml.juniper.net/junos/*/junos" junos:style="brief" junos:seconds="312"></rt>`,
+		`<rt/>`: `<rt></rt>`,
+	}
+
+	for doc, want := range docs {
+		var rt junosElement
+		if err := xml.Unmarshal([]byte(doc), &rt); err != nil {
+			t.Errorf("%s: %v", doc, err)
+			continue
+		}
+
+		if doc != `<rt/>` && (rt.Style != "brief" || rt.Seconds != "312") {
+			t.Errorf("%s: decoded %+v", doc, rt)
+		}
+
+		buf := bytes.Buffer{}
+		if _, err := jresponse.WriteXML(&buf, &rt, rt.XMLName); err != nil {
+			t.Error(err)
+		} else if buf.String() != want {
+			t.Errorf("%s: WriteXML() = %s, should be %s", doc, buf.String(), want)
+		}
+	}
+}
+
+func TestWriteXML(t *testing.T) {
+	var rt junosElement
+	if err := xml.Unmarshal([]byte(`<rt xmlns="urn:routing" junos:style="detail"/>`), &rt); err != nil {
+		t.Fatal(err)
+	}
+
+	// xml.Marshal loses the namespace of the root element
+	buf := bytes.Buffer{}
+	if _, err := jresponse.WriteXML(&buf, &rt, rt.XMLName); err != nil {
+		t.Error(err)
+	} else if buf.String() != `<rt xmlns:junos="http://xml.juniper.net/junos/*/junos" xmlns="urn:routing" junos:style="detail"></rt>` {
+		t.Errorf("unexpected XML %s", buf.String())
+	}
+
+	// a response that wasn't decoded from XML is named by its tag
+	buf.Reset()
+	if _, err := jresponse.WriteXML(&buf, &junosElement{Style: "detail"}, xml.Name{}); err != nil {
+		t.Error(err)
+	} else if buf.String() != `<rt xmlns:junos="http://xml.juniper.net/junos/*/junos" junos:style="detail"></rt>` {
+		t.Errorf("unexpected XML %s", buf.String())
+	}
+}
@@ -0,0 +1,468 @@
+package jresponse
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Junos 14.2 and later encode RPC replies as JSON of their own, such as
+// the output of "| display json" and of the REST API. Each element is an
+// array of objects, one per occurrence of the element, holding its
+// children by name, its text as "data", and its attributes as an
+// "attributes" object:
+//
+//	{"ping-results": [{
+//	    "attributes": {"xmlns": "http://xml.juniper.net/junos/12.3R7/junos-probe-tests"},
+//	    "target-host": [{"data": "8.8.8.8"}],
+//	    "probe-result": [{
+//	        "attributes": {"date-determined": "1447350764"},
+//	        "probe-success": [{"data": [null]}],
+//	        ...
+//
+// Responses read and write it by converting to and from their XML.
+
+// junosJSONError reports Junos JSON that doesn't describe XML.
+type junosJSONError struct {
+	path string
+	msg  string
+}
+
+func (e *junosJSONError) Error() string {
+	if e.path == "" {
+		return "jresponse: junos json: " + e.msg
+	}
+	return fmt.Sprintf("jresponse: junos json: %s: %s", e.path, e.msg)
+}
+
+// element is an XML element as both encodings describe it.
+type element struct {
+	name     string
+	attrs    []xml.Attr
+	text     string
+	children []*element
+}
+
+// JunosJSONToXML converts a reply Junos encoded as JSON to the XML it
+// would have sent over NETCONF. A document of several elements, such as an
+// rpc-error next to a response, is wrapped in an rpc-reply.
+func JunosJSONToXML(p []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(p))
+	d.UseNumber()
+
+	roots, err := readJSONChildren(d, "")
+	if err != nil {
+		return nil, err
+	} else if _, err := d.Token(); err != io.EOF {
+		return nil, &junosJSONError{msg: "data after the document"}
+	}
+
+	var root *element
+	switch len(roots) {
+	case 0:
+		return nil, &junosJSONError{msg: "no root element"}
+	case 1:
+		root = roots[0]
+	default:
+		root = &element{name: "rpc-reply", children: roots}
+	}
+
+	buf := bytes.Buffer{}
+	enc := xml.NewEncoder(&buf)
+	if err := root.writeXML(enc); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readJSONChildren reads an object of elements, each an array of their
+// occurrences, as found at the top of a document and within elements.
+func readJSONChildren(d *json.Decoder, path string) ([]*element, error) {
+	if err := expectDelim(d, '{', path); err != nil {
+		return nil, err
+	}
+
+	var children []*element
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := tok.(string)
+
+		if err := expectDelim(d, '[', path+"/"+name); err != nil {
+			return nil, err
+		}
+		for d.More() {
+			e, err := readJSONElement(d, name, path+"/"+name)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, e)
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err := d.Token()
+	return children, err
+}
+
+// readJSONElement reads an occurrence of the element name.
+func readJSONElement(d *json.Decoder, name, path string) (*element, error) {
+	e := &element{name: name}
+
+	if err := expectDelim(d, '{', path); err != nil {
+		return nil, err
+	}
+
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch key := tok.(string); key {
+		case "attributes":
+			if e.attrs, err = readJSONAttributes(d, path); err != nil {
+				return nil, err
+			}
+		case "data":
+			if e.text, err = readJSONData(d, path); err != nil {
+				return nil, err
+			}
+		default:
+			if err := expectDelim(d, '[', path+"/"+key); err != nil {
+				return nil, err
+			}
+			for d.More() {
+				child, err := readJSONElement(d, key, path+"/"+key)
+				if err != nil {
+					return nil, err
+				}
+				e.children = append(e.children, child)
+			}
+			if _, err := d.Token(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	_, err := d.Token()
+	return e, err
+}
+
+// readJSONAttributes reads an attributes object, whose values are strings.
+func readJSONAttributes(d *json.Decoder, path string) ([]xml.Attr, error) {
+	if err := expectDelim(d, '{', path+"/attributes"); err != nil {
+		return nil, err
+	}
+
+	var attrs []xml.Attr
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := tok.(string)
+
+		value, err := readJSONScalar(d, path+"/@"+name)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	}
+
+	_, err := d.Token()
+	return attrs, err
+}
+
+// readJSONData reads the text of an element, which is [null] for an empty
+// one.
+func readJSONData(d *json.Decoder, path string) (string, error) {
+	var data json.RawMessage
+	if err := d.Decode(&data); err != nil {
+		return "", err
+	}
+
+	var values []interface{}
+	if json.Unmarshal(data, &values) == nil {
+		for _, v := range values {
+			if v != nil {
+				return "", &junosJSONError{path: path, msg: "data is neither text nor [null]"}
+			}
+		}
+		return "", nil
+	}
+
+	d = json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return readJSONScalar(d, path)
+}
+
+// readJSONScalar reads a string, or a number or boolean as the text Junos
+// would have written.
+func readJSONScalar(d *json.Decoder, path string) (string, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return "", err
+	}
+
+	switch v := tok.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	case nil:
+		return "", nil
+	default:
+		return "", &junosJSONError{path: path, msg: fmt.Sprintf("unexpected %v", v)}
+	}
+}
+
+func expectDelim(d *json.Decoder, delim json.Delim, path string) error {
+	tok, err := d.Token()
+	if err == io.EOF {
+		return &junosJSONError{path: path, msg: "unexpected end of document"}
+	} else if err != nil {
+		return err
+	} else if tok != delim {
+		return &junosJSONError{path: path, msg: fmt.Sprintf("got %v, want %v", tok, delim)}
+	}
+	return nil
+}
+
+// writeXML encodes e, whose names, like the attribute junos:style, keep
+// the prefixes they had.
+func (e *element) writeXML(enc *xml.Encoder) error {
+	start := xml.StartElement{Name: xml.Name{Local: e.name}, Attr: e.attrs}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if e.text != "" {
+		if err := enc.EncodeToken(xml.CharData(e.text)); err != nil {
+			return err
+		}
+	}
+	for _, child := range e.children {
+		if err := child.writeXML(enc); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// XMLToJunosJSON converts an XML reply to the JSON Junos would encode it
+// as. An rpc-reply envelope is left out, its elements becoming the
+// elements of the document.
+func XMLToJunosJSON(p []byte) ([]byte, error) {
+	root, err := readXMLElement(p)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := []*element{root}
+	if root.name == "rpc-reply" {
+		roots = root.children
+	}
+
+	buf := bytes.Buffer{}
+	writeJSONChildren(&buf, roots)
+
+	out := bytes.Buffer{}
+	if err := json.Indent(&out, buf.Bytes(), "", "    "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// readXMLElement reads the root element of p, keeping the prefixes of
+// names as written.
+func readXMLElement(p []byte) (*element, error) {
+	d := NewDecoder(bytes.NewReader(p))
+
+	var root *element
+	var stack []*element
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := &element{name: prefixed(t.Name)}
+			for _, attr := range t.Attr {
+				e.attrs = append(e.attrs, xml.Attr{Name: xml.Name{Local: prefixed(attr.Name)}, Value: attr.Value})
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			} else if root == nil {
+				root = e
+			} else {
+				return nil, errors.New("jresponse: more than one root element")
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("jresponse: unexpected end element </%s>", prefixed(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("jresponse: no root element found")
+	} else if len(stack) > 0 {
+		return nil, fmt.Errorf("jresponse: unclosed element <%s>", stack[len(stack)-1].name)
+	}
+	return root, nil
+}
+
+func prefixed(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// writeJSONChildren writes elements as an object of arrays, in which the
+// occurrences of an element are grouped where the first one was.
+func writeJSONChildren(buf *bytes.Buffer, elements []*element) {
+	var names []string
+	byName := make(map[string][]*element)
+	for _, e := range elements {
+		if _, ok := byName[e.name]; !ok {
+			names = append(names, e.name)
+		}
+		byName[e.name] = append(byName[e.name], e)
+	}
+
+	buf.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, name)
+		buf.WriteString(":[")
+		for j, e := range byName[name] {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			e.writeJSON(buf)
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte('}')
+}
+
+func (e *element) writeJSON(buf *bytes.Buffer) {
+	var fields []string
+
+	if len(e.attrs) > 0 {
+		attrs := bytes.Buffer{}
+		attrs.WriteString(`"attributes":{`)
+		for i, attr := range e.attrs {
+			if i > 0 {
+				attrs.WriteByte(',')
+			}
+			writeJSONString(&attrs, attr.Name.Local)
+			attrs.WriteByte(':')
+			writeJSONString(&attrs, attr.Value)
+		}
+		attrs.WriteByte('}')
+		fields = append(fields, attrs.String())
+	}
+
+	if len(e.children) > 0 {
+		children := bytes.Buffer{}
+		writeJSONChildren(&children, e.children)
+		// the children are written as fields of e's object
+		if inner := strings.TrimSuffix(strings.TrimPrefix(children.String(), "{"), "}"); inner != "" {
+			fields = append(fields, inner)
+		}
+	}
+
+	if e.text != "" {
+		data := bytes.Buffer{}
+		data.WriteString(`"data":`)
+		writeJSONString(&data, e.text)
+		fields = append(fields, data.String())
+	} else if len(e.children) == 0 {
+		fields = append(fields, `"data":[null]`)
+	}
+
+	buf.WriteByte('{')
+	buf.WriteString(strings.Join(fields, ","))
+	buf.WriteByte('}')
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	p, _ := json.Marshal(s)
+	buf.Write(p)
+}
+
+// ReadJunosJSONFrom reads a reply Junos encoded as JSON from r into resp,
+// through its XML. Response packages use it to implement
+// ReadJunosJSONFrom.
+func ReadJunosJSONFrom(r io.Reader, resp JResponseReader) (n int64, err error) {
+	buf := bytes.Buffer{}
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	}
+
+	p, err := JunosJSONToXML(buf.Bytes())
+	if err != nil {
+		return n, err
+	}
+
+	_, err = resp.ReadXMLFrom(bytes.NewReader(p))
+	return n, err
+}
+
+// WriteJunosJSON writes resp to w as the JSON Junos would encode it as,
+// through its XML. Response packages use it to implement WriteJunosJSONTo.
+func WriteJunosJSON(w io.Writer, resp JResponseWriter) (n int64, err error) {
+	buf := bytes.Buffer{}
+	if _, err := resp.WriteXMLTo(&buf); err != nil {
+		return 0, err
+	}
+
+	p, err := XMLToJunosJSON(buf.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return bytes.NewBuffer(p).WriteTo(w)
+}
+
+// ReadJunosJSON is ReadXML for replies Junos encoded as JSON.
+func ReadJunosJSON(r io.Reader) (ResponseReaderWriter, error) {
+	buf := bytes.Buffer{}
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	p, err := JunosJSONToXML(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return ReadXML(bytes.NewReader(p))
+}
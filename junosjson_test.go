@@ -0,0 +1,133 @@
+package jresponse_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/command/ping"
+)
+
+const PING_JUNOS_JSON_FILE = "command/ping/ping_8.8.8.8_junos_synthetic.json"
+
+func TestJunosJSONToXML(t *testing.T) {
+
+	tests := map[string]string{
+		`{"rt": [{"attributes": {"junos:style": "brief"}, "rt-destination": [{"data": "10.0.0.0/8"}]}]}`:                                        `<rt junos:style="brief"><rt-destination>10.0.0.0/8</rt-destination></rt>`,
+		`{"ping-results": [{"attributes": {"xmlns": "urn:probe"}, "probe-success": [{"data": [null]}], "rtt": [{"data": 690}]}]}`:               `<ping-results xmlns="urn:probe"><probe-success></probe-success><rtt>690</rtt></ping-results>`,
+		`{"hop": [{"ttl-value": [{"data": "1"}]}, {"ttl-value": [{"data": "2"}]}]}`:                                                             `<rpc-reply><hop><ttl-value>1</ttl-value></hop><hop><ttl-value>2</ttl-value></hop></rpc-reply>`,
+		`{"route-information": [{"data": ""}], "rpc-error": [{"error-severity": [{"data": "warning"}], "error-message": [{"data": "a & b"}]}]}`: `<rpc-reply><route-information></route-information><rpc-error><error-severity>warning</error-severity><error-message>a &amp; b</error-message></rpc-error></rpc-reply>`,
+	}
+
+	for input, want := range tests {
+		if got, err := jresponse.JunosJSONToXML([]byte(input)); err != nil {
+			t.Errorf("%s: %v", input, err)
+		} else if string(got) != want {
+			t.Errorf("%s:\ngot  %s\nwant %s", input, got, want)
+		}
+	}
+
+	for _, input := range []string{
+		``,
+		`{}`,
+		`[{"rt": []}]`,
+		`{"rt": {"data": "x"}}`,
+		`{"rt": [{"data": ["x"]}]}`,
+		`{"rt": [{"attributes": {"junos:style": {}}}]}`,
+		`{"rt": [{"data": "x"}]} {}`,
+	} {
+		if _, err := jresponse.JunosJSONToXML([]byte(input)); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestXMLToJunosJSON(t *testing.T) {
+
+	input := `<rpc-reply xmlns:junos="http://xml.juniper.net/junos/12.3R6/junos">
+    <route-information xmlns="http://xml.juniper.net/junos/12.3R6/junos-routing">
+        <rt junos:style="brief">
+            <rt-destination>10.0.0.0/8</rt-destination>
+            <rt-entry>
+                <active-tag>*</active-tag>
+                <age junos:seconds="585128">6d 18:32:08</age>
+            </rt-entry>
+            <rt-entry>
+                <current-active/>
+            </rt-entry>
+        </rt>
+    </route-information>
+</rpc-reply>`
+
+	got, err := jresponse.XMLToJunosJSON([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"route-information": [{
+		"attributes": {"xmlns": "http://xml.juniper.net/junos/12.3R6/junos-routing"},
+		"rt": [{
+			"attributes": {"junos:style": "brief"},
+			"rt-destination": [{"data": "10.0.0.0/8"}],
+			"rt-entry": [
+				{"active-tag": [{"data": "*"}], "age": [{"attributes": {"junos:seconds": "585128"}, "data": "6d 18:32:08"}]},
+				{"current-active": [{"data": [null]}]}
+			]
+		}]
+	}]}`
+	wantBuf := bytes.Buffer{}
+	if err := json.Compact(&wantBuf, []byte(want)); err != nil {
+		t.Fatal(err)
+	}
+	gotBuf := bytes.Buffer{}
+	if err := json.Compact(&gotBuf, got); err != nil {
+		t.Fatal(err)
+	}
+	if gotBuf.String() != wantBuf.String() {
+		t.Errorf("got  %s\nwant %s", gotBuf.String(), wantBuf.String())
+	}
+
+	for _, input := range []string{``, `<rt>`, `<rt/><rt/>`} {
+		if _, err := jresponse.XMLToJunosJSON([]byte(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestReadJunosJSON(t *testing.T) {
+
+	file, err := os.Open(PING_JUNOS_JSON_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	resp, err := jresponse.ReadJunosJSON(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := new(ping.Ping)
+	if file, err := os.Open(PING_XML_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := want.ReadXMLFrom(file); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Log(want)
+		t.Log(resp)
+		t.Error("Junos JSON does not read as the XML")
+	}
+
+	p := new(ping.Ping)
+	if _, err := file.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	} else if _, err := jresponse.Decode(file, p, jresponse.JunosJSON); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(p, want) {
+		t.Error("Decode does not read Junos JSON as the XML")
+	}
+}
@@ -0,0 +1,110 @@
+package netconftest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// endOfMessage ends each message of NETCONF 1.0 framing.
+const endOfMessage = "]]>]]>"
+
+// maxChunkSize is the largest chunk NETCONF 1.1 framing allows.
+const maxChunkSize = 4294967295
+
+var errFraming = errors.New("netconftest: invalid chunked framing")
+
+// framer reads and writes NETCONF messages, ended by endOfMessage until
+// both peers announce base:1.1 in their hellos, and chunked after that.
+type framer struct {
+	r       *bufio.Reader
+	w       io.Writer
+	chunked bool
+}
+
+func newFramer(rw io.ReadWriter) *framer {
+	return &framer{r: bufio.NewReader(rw), w: rw}
+}
+
+// ReadMessage reads the next message, without its framing.
+func (f *framer) ReadMessage() ([]byte, error) {
+	if f.chunked {
+		return f.readChunked()
+	}
+
+	var msg []byte
+	for {
+		b, err := f.r.ReadByte()
+		if err == io.EOF && len(msg) > 0 {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+
+		msg = append(msg, b)
+		if bytes.HasSuffix(msg, []byte(endOfMessage)) {
+			return msg[:len(msg)-len(endOfMessage)], nil
+		}
+	}
+}
+
+// readChunked reads the chunks of a message up to its end-of-chunks.
+func (f *framer) readChunked() ([]byte, error) {
+	var msg []byte
+	for {
+		if err := f.expect("\n#"); err != nil {
+			return nil, err
+		}
+
+		if b, err := f.r.ReadByte(); err != nil {
+			return nil, err
+		} else if b == '#' {
+			return msg, f.expect("\n")
+		}
+		f.r.UnreadByte()
+
+		line, err := f.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseUint(strings.TrimSuffix(line, "\n"), 10, 32)
+		if err != nil || size == 0 || size > maxChunkSize {
+			return nil, fmt.Errorf("%w: chunk size %q", errFraming, strings.TrimSpace(line))
+		}
+
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(f.r, chunk); err != nil {
+			return nil, err
+		}
+		msg = append(msg, chunk...)
+	}
+}
+
+// expect reads s, which must come next.
+func (f *framer) expect(s string) error {
+	p := make([]byte, len(s))
+	if _, err := io.ReadFull(f.r, p); err != nil {
+		return err
+	} else if string(p) != s {
+		return fmt.Errorf("%w: got %q, want %q", errFraming, p, s)
+	}
+	return nil
+}
+
+// WriteMessage writes msg with its framing, as a single chunk when
+// chunked, or none if msg is empty.
+func (f *framer) WriteMessage(msg []byte) error {
+	var err error
+	if f.chunked && len(msg) == 0 {
+		_, err = io.WriteString(f.w, "\n##\n")
+	} else if f.chunked {
+		_, err = fmt.Fprintf(f.w, "\n#%d\n%s\n##\n", len(msg), msg)
+	} else {
+		_, err = fmt.Fprintf(f.w, "%s%s", msg, endOfMessage)
+	}
+	return err
+}
@@ -0,0 +1,475 @@
+// Package netconftest runs a fake Junos device for tests: an SSH server on
+// the loopback interface speaking NETCONF, which answers RPCs with canned
+// replies, by default the fixtures of jresponse's response packages.
+//
+//	s := netconftest.NewServer()
+//	defer s.Close()
+//
+//	s.SetReply("traceroute", netconftest.Reply{Delay: time.Second})
+//	c, err := client.Dial(ctx, s.Addr, s.ClientConfig())
+package netconftest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// BaseNamespace is the namespace of NETCONF's own elements, such as
+	// hello and rpc-reply.
+	BaseNamespace = "urn:ietf:params:xml:ns:netconf:base:1.0"
+
+	// Base10 and Base11 are the capabilities announcing NETCONF 1.0, framed
+	// by ]]>]]>, and NETCONF 1.1, with chunked framing.
+	Base10 = "urn:ietf:params:netconf:base:1.0"
+	Base11 = "urn:ietf:params:netconf:base:1.1"
+
+	// JunosNamespace is declared for the junos prefix on each rpc-reply.
+	JunosNamespace = "http://xml.juniper.net/junos/12.3R7/junos"
+
+	// User and Password are the credentials the server accepts.
+	User     = "netconf"
+	Password = "netconf"
+)
+
+// Fixtures are the files, relative to the root of jresponse, of the
+// default reply to each RPC.
+var Fixtures = map[string]string{
+	"ping":                         "command/ping/ping_8.8.8.8.xml",
+	"traceroute":                   "command/traceroute/traceroute_8.8.8.8.xml",
+	"get-route-information":        "show/route/protocol/bgp/show_route_protocol_bgp.xml",
+	"get-bgp-summary-information":  "show/bgp/summary/show_bgp_summary.xml",
+	"get-bgp-neighbor-information": "show/bgp/neighbor/show_bgp_neighbor.xml",
+	"get-interface-information":    "show/interfaces/show_interfaces_terse.xml",
+}
+
+// Reply is the fake device's reply to an RPC.
+type Reply struct {
+	// Data is the XML inside the rpc-reply, such as a response.
+	Data string
+	// Errors are sent as rpc-errors ahead of Data.
+	Errors []jresponse.RPCError
+	// Delay is how long the device takes to reply.
+	Delay time.Duration
+	// Raw, when set, is sent as is in place of the rpc-reply, such as
+	// malformed XML.
+	Raw string
+	// Truncate, when positive, cuts the reply after that many bytes, and
+	// the session is closed before its framing is sent, like a device
+	// dropping the connection mid reply.
+	Truncate int
+}
+
+// RPC is an RPC received by the fake device.
+type RPC struct {
+	SessionID int
+	MessageID string
+	// Method is the name of the RPC, e.g. "traceroute".
+	Method string
+	// XML is the request, e.g. <traceroute><host>8.8.8.8</host></traceroute>.
+	XML string
+}
+
+// ReadReply returns a Reply holding the response in the file name. A file
+// holding a whole rpc-reply, as captured from a device, is replied with
+// what's inside it.
+func ReadReply(name string) (Reply, error) {
+	p, err := os.ReadFile(name)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	var envelope struct {
+		XMLName xml.Name
+		Inner   string `xml:",innerxml"`
+	}
+	if err := xml.Unmarshal(p, &envelope); err != nil {
+		return Reply{}, fmt.Errorf("netconftest: %s: %w", name, err)
+	} else if envelope.XMLName.Local == "rpc-reply" {
+		return Reply{Data: strings.TrimSpace(envelope.Inner)}, nil
+	}
+	return Reply{Data: strings.TrimSpace(string(p))}, nil
+}
+
+// DefaultReplies returns the replies read from Fixtures.
+func DefaultReplies() (map[string]Reply, error) {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return nil, errors.New("netconftest: can't locate fixtures")
+	}
+	root := filepath.Dir(filepath.Dir(file))
+
+	replies := make(map[string]Reply, len(Fixtures))
+	for method, name := range Fixtures {
+		reply, err := ReadReply(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		replies[method] = reply
+	}
+	return replies, nil
+}
+
+// syntaxError is the reply of Junos to an RPC it doesn't know.
+func syntaxError(method string) Reply {
+	return Reply{Errors: []jresponse.RPCError{{
+		Type:     "protocol",
+		Tag:      "operation-failed",
+		Severity: jresponse.SeverityError,
+		Message:  "syntax error",
+		Info:     &jresponse.ErrorInfo{BadElement: method},
+	}}}
+}
+
+var errSessionClosed = errors.New("netconftest: session closed")
+
+// Server is a fake Junos device accepting NETCONF over SSH sessions.
+type Server struct {
+	// Addr is the address the server listens on, as host:port.
+	Addr string
+
+	// Capabilities are announced in the device's hello. The sessions use
+	// chunked framing when both sides announce Base11. Changes apply to
+	// sessions opened after them.
+	Capabilities []string
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu        sync.Mutex
+	replies   map[string]Reply
+	rpcs      []RPC
+	conns     map[net.Conn]struct{}
+	sessionID int
+}
+
+// NewServer starts a Server on the loopback interface, replying with
+// DefaultReplies. It panics if it can't start, like httptest.NewServer.
+func NewServer() *Server {
+	replies, err := DefaultReplies()
+	if err != nil {
+		panic(err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("netconftest: failed to listen: %v", err))
+	}
+
+	s := &Server{
+		Addr:         listener.Addr().String(),
+		Capabilities: []string{Base10, Base11},
+		listener:     listener,
+		done:         make(chan struct{}),
+		replies:      replies,
+		conns:        make(map[net.Conn]struct{}),
+	}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == User && string(password) == Password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("netconftest: password rejected for %s", conn.User())
+		},
+	}
+	s.config.AddHostKey(signer)
+
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// ClientConfig returns the SSH configuration to log in to s with.
+func (s *Server) ClientConfig() *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:            User,
+		Auth:            []ssh.AuthMethod{ssh.Password(Password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+}
+
+// SetReply sets the reply to the RPC method, e.g. "traceroute".
+func (s *Server) SetReply(method string, reply Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies[method] = reply
+}
+
+// RPCs returns the RPCs received so far, in order.
+func (s *Server) RPCs() []RPC {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RPC(nil), s.rpcs...)
+}
+
+// Close stops s, closing the sessions still open.
+func (s *Server) Close() {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return
+	default:
+	}
+	close(s.done)
+	s.listener.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		select {
+		case <-s.done:
+			s.mu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.serveChannel(channel, requests)
+	}
+}
+
+// serveChannel runs a NETCONF session once the netconf subsystem is
+// requested on channel, as Junos does.
+func (s *Server) serveChannel(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer s.wg.Done()
+	defer channel.Close()
+
+	for req := range requests {
+		if req.Type != "subsystem" || subsystem(req.Payload) != "netconf" {
+			req.Reply(false, nil)
+			continue
+		}
+
+		req.Reply(true, nil)
+		go ssh.DiscardRequests(requests)
+		s.session(newFramer(channel))
+		return
+	}
+}
+
+// subsystem returns the name of the subsystem in the payload of a
+// subsystem request, an SSH string.
+func subsystem(payload []byte) string {
+	if len(payload) < 4 || int(binary.BigEndian.Uint32(payload)) != len(payload)-4 {
+		return ""
+	}
+	return string(payload[4:])
+}
+
+// hello is the message each side of a session starts with.
+type hello struct {
+	XMLName      xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 hello"`
+	Capabilities []string `xml:"capabilities>capability"`
+	SessionID    int      `xml:"session-id,omitempty"`
+}
+
+func hasCapability(capabilities []string, capability string) bool {
+	for _, c := range capabilities {
+		if strings.TrimSpace(c) == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// session exchanges hellos, then replies to each rpc until the session is
+// closed.
+func (s *Server) session(f *framer) {
+	s.mu.Lock()
+	s.sessionID++
+	id, capabilities := s.sessionID, s.Capabilities
+	s.mu.Unlock()
+
+	p, err := xml.Marshal(hello{Capabilities: capabilities, SessionID: id})
+	if err != nil || f.WriteMessage(p) != nil {
+		return
+	}
+
+	var peer hello
+	if p, err := f.ReadMessage(); err != nil {
+		return
+	} else if err := xml.Unmarshal(p, &peer); err != nil {
+		return
+	}
+	f.chunked = hasCapability(capabilities, Base11) && hasCapability(peer.Capabilities, Base11)
+
+	for {
+		p, err := f.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg struct {
+			XMLName   xml.Name
+			MessageID string `xml:"message-id,attr"`
+			Inner     string `xml:",innerxml"`
+			Method    struct {
+				XMLName xml.Name
+			} `xml:",any"`
+		}
+		if err := xml.Unmarshal(p, &msg); err != nil || msg.XMLName.Local != "rpc" {
+			// Junos answers what isn't an rpc with an rpc-error too
+			if s.reply(f, "", Reply{Errors: []jresponse.RPCError{{
+				Type:     "rpc",
+				Tag:      "malformed-message",
+				Severity: jresponse.SeverityError,
+				Message:  "malformed rpc",
+			}}}) != nil {
+				return
+			}
+			continue
+		}
+
+		rpc := RPC{SessionID: id, MessageID: msg.MessageID, Method: msg.Method.XMLName.Local, XML: strings.TrimSpace(msg.Inner)}
+		s.mu.Lock()
+		s.rpcs = append(s.rpcs, rpc)
+		reply, ok := s.replies[rpc.Method]
+		s.mu.Unlock()
+
+		switch {
+		case rpc.Method == "close-session":
+			s.reply(f, rpc.MessageID, Reply{Data: "<ok/>"})
+			return
+		case !ok:
+			reply = syntaxError(rpc.Method)
+		}
+
+		if s.reply(f, rpc.MessageID, reply) != nil {
+			return
+		}
+	}
+}
+
+// reply sends reply to the rpc messageID after its delay. An error ends
+// the session.
+func (s *Server) reply(f *framer, messageID string, reply Reply) error {
+	if reply.Delay > 0 {
+		select {
+		case <-time.After(reply.Delay):
+		case <-s.done:
+			return errSessionClosed
+		}
+	}
+
+	msg := []byte(reply.Raw)
+	if reply.Raw == "" {
+		var err error
+		if msg, err = rpcReply(messageID, reply); err != nil {
+			return err
+		}
+	}
+
+	if reply.Truncate > 0 && reply.Truncate < len(msg) {
+		f.w.Write(msg[:reply.Truncate])
+		return errSessionClosed
+	}
+	return f.WriteMessage(msg)
+}
+
+// rpcReply returns the rpc-reply to the rpc messageID, as Junos formats it.
+func rpcReply(messageID string, reply Reply) ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString(`<rpc-reply xmlns="` + BaseNamespace + `" xmlns:junos="` + JunosNamespace + `"`)
+	if messageID != "" {
+		buf.WriteString(` message-id="`)
+		xml.EscapeText(&buf, []byte(messageID))
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">\n")
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "    ")
+	for _, e := range reply.Errors {
+		if err := enc.EncodeElement(e, xml.StartElement{Name: xml.Name{Local: "rpc-error"}}); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	if len(reply.Errors) > 0 {
+		buf.WriteString("\n")
+	}
+
+	if reply.Data != "" {
+		buf.WriteString(reply.Data + "\n")
+	}
+	buf.WriteString("</rpc-reply>")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,311 @@
+package netconftest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/command/traceroute"
+	"github.com/Juniper/go-netconf/netconf"
+	"golang.org/x/crypto/ssh"
+)
+
+const TRACE_ROUTE_XML_FILE = "../command/traceroute/traceroute_8.8.8.8.xml"
+
+func dial(t *testing.T, s *Server) *netconf.Session {
+	session, err := netconf.DialSSH(s.Addr, s.ClientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func readEnvelope(r io.Reader) (*jresponse.RPCReply, error) {
+	reply := new(jresponse.RPCReply)
+	_, err := reply.ReadXMLFrom(r)
+	return reply, err
+}
+
+func TestDefaultReplies(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	session := dial(t, s)
+
+	if session.SessionID != 1 || len(session.ServerCapabilities) != 2 {
+		t.Errorf("got session %d with capabilities %q", session.SessionID, session.ServerCapabilities)
+	}
+
+	reply, err := session.Exec(netconf.RawMethod("<traceroute><host>8.8.8.8</host></traceroute>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := new(traceroute.TraceRoute)
+	if _, err := tr.ReadXMLFrom(strings.NewReader(reply.RawReply)); err != nil {
+		t.Fatal(err)
+	}
+	want, err := ReadReply(TRACE_ROUTE_XML_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTR := new(traceroute.TraceRoute)
+	if _, err := wantTR.ReadXMLFrom(strings.NewReader(want.Data)); err != nil {
+		t.Fatal(err)
+	}
+	if tr.TargetHost != wantTR.TargetHost || len(tr.Hops) != len(wantTR.Hops) {
+		t.Errorf("got %+v\nwant %+v", tr, wantTR)
+	}
+
+	for method := range Fixtures {
+		reply, err := session.Exec(netconf.RawMethod("<" + method + "/>"))
+		if err != nil {
+			t.Errorf("%s: %v", method, err)
+		} else if _, err := readEnvelope(strings.NewReader(reply.RawReply)); err != nil {
+			t.Errorf("%s: %v", method, err)
+		}
+	}
+
+	rpcs := s.RPCs()
+	if len(rpcs) != len(Fixtures)+1 {
+		t.Fatalf("got %d rpcs, want %d", len(rpcs), len(Fixtures)+1)
+	}
+	if rpcs[0].Method != "traceroute" || rpcs[0].XML != "<traceroute><host>8.8.8.8</host></traceroute>" || rpcs[0].MessageID == "" {
+		t.Errorf("got rpc %+v", rpcs[0])
+	}
+}
+
+func TestUnknownRPC(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+
+	_, err := dial(t, s).Exec(netconf.RawMethod("<get-software-information/>"))
+
+	var rpcErr *netconf.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Message != "syntax error" || !strings.Contains(rpcErr.Info, "<bad-element>get-software-information</bad-element>") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestRPCErrors(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	session := dial(t, s)
+
+	warning := jresponse.RPCError{Type: "protocol", Tag: "operation-failed", Severity: jresponse.SeverityWarning, Message: "statement has no effect"}
+	s.SetReply("ping", Reply{Data: "<ping-results/>", Errors: []jresponse.RPCError{warning}})
+
+	reply, err := session.Exec(netconf.RawMethod("<ping><host>8.8.8.8</host></ping>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := readEnvelope(strings.NewReader(reply.RawReply))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envelope.RPCErrors) != 1 || envelope.RPCErrors[0].Message != warning.Message || len(envelope.Payloads) != 1 {
+		t.Errorf("got %+v", envelope)
+	}
+
+	failure := jresponse.RPCError{Type: "application", Tag: "operation-failed", Severity: jresponse.SeverityError, Message: "no route to host"}
+	s.SetReply("ping", Reply{Errors: []jresponse.RPCError{warning, failure}})
+
+	_, err = session.Exec(netconf.RawMethod("<ping><host>8.8.8.8</host></ping>"))
+	var rpcErr *netconf.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Message != failure.Message {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestDelay(t *testing.T) {
+
+	s := NewServer()
+	session := dial(t, s)
+
+	s.SetReply("ping", Reply{Data: "<ping-results/>", Delay: 50 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := session.Exec(netconf.RawMethod("<ping><host>8.8.8.8</host></ping>")); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("replied after %v", elapsed)
+	}
+
+	// closing the server ends replies still being delayed
+	s.SetReply("ping", Reply{Data: "<ping-results/>", Delay: time.Hour})
+	go func() {
+		for len(s.RPCs()) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		s.Close()
+	}()
+
+	if _, err := session.Exec(netconf.RawMethod("<ping><host>8.8.8.8</host></ping>")); err == nil {
+		t.Error("expected an error from a closed server")
+	}
+}
+
+func TestMalformed(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	session := dial(t, s)
+
+	s.SetReply("traceroute", Reply{Raw: "<rpc-reply><traceroute-results></rpc-reply>"})
+	if _, err := session.Exec(netconf.RawMethod("<traceroute><host>8.8.8.8</host></traceroute>")); err == nil {
+		t.Error("expected an error decoding a malformed reply")
+	}
+
+	// the session survives a malformed reply
+	s.SetReply("traceroute", Reply{Data: "<traceroute-results/>"})
+	if _, err := session.Exec(netconf.RawMethod("<traceroute><host>8.8.8.8</host></traceroute>")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	session := dial(t, s)
+
+	s.SetReply("traceroute", Reply{Data: "<traceroute-results/>", Truncate: 20})
+	if _, err := session.Exec(netconf.RawMethod("<traceroute><host>8.8.8.8</host></traceroute>")); err == nil {
+		t.Error("expected an error reading a truncated reply")
+	}
+}
+
+// TestChunked runs a session as a NETCONF 1.1 client would.
+func TestChunked(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+
+	client, err := ssh.Dial("tcp", s.Addr, s.ClientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.RequestSubsystem("netconf"); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFramer(struct {
+		io.Reader
+		io.Writer
+	}{r, w})
+
+	p, err := f.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var serverHello hello
+	if err := xml.Unmarshal(p, &serverHello); err != nil {
+		t.Fatal(err)
+	}
+	if !hasCapability(serverHello.Capabilities, Base11) {
+		t.Fatalf("got capabilities %q", serverHello.Capabilities)
+	}
+
+	if p, err = xml.Marshal(hello{Capabilities: []string{Base10, Base11}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.WriteMessage(p); err != nil {
+		t.Fatal(err)
+	}
+	f.chunked = true
+
+	rpc := `<rpc xmlns="` + BaseNamespace + `" message-id="101"><traceroute><host>8.8.8.8</host></traceroute></rpc>`
+	if err := f.WriteMessage([]byte(rpc)); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = f.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	envelope, err := readEnvelope(bytes.NewReader(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope.MessageID != "101" || len(envelope.Payloads) != 1 || envelope.Payloads[0].Name.Local != "traceroute-results" {
+		t.Errorf("got %+v", envelope)
+	}
+}
+
+func TestFraming(t *testing.T) {
+
+	tests := []struct {
+		chunked bool
+		input   string
+		want    []string
+		err     bool
+	}{
+		{false, "<hello/>]]>]]><rpc/>]]>]]>", []string{"<hello/>", "<rpc/>"}, false},
+		{false, "<rpc/>]]>", nil, true},
+		{true, "\n#4\n<rpc\n#3\n/>\n\n##\n", []string{"<rpc/>\n"}, false},
+		{true, "\n#6\n<rpc/>\n##\n\n#2\nok\n##\n", []string{"<rpc/>", "ok"}, false},
+		{true, "\n#0\n\n##\n", nil, true},
+		{true, "\n#x\n", nil, true},
+		{true, "\n#9\n<rpc/>", nil, true},
+		{true, "<rpc/>]]>]]>", nil, true},
+	}
+
+	for _, test := range tests {
+		f := newFramer(bytes.NewBufferString(test.input))
+		f.chunked = test.chunked
+
+		var got []string
+		var err error
+		for {
+			var p []byte
+			if p, err = f.ReadMessage(); err != nil {
+				break
+			}
+			got = append(got, string(p))
+		}
+
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("%q: got %q, want %q", test.input, got, test.want)
+		}
+		if failed := err.Error() != "EOF"; failed != test.err {
+			t.Errorf("%q: got error %v", test.input, err)
+		}
+	}
+
+	for _, chunked := range []bool{false, true} {
+		buf := new(bytes.Buffer)
+		f := newFramer(buf)
+		f.chunked = chunked
+
+		for _, msg := range []string{"<rpc/>", ""} {
+			if err := f.WriteMessage([]byte(msg)); err != nil {
+				t.Fatal(err)
+			}
+			if p, err := f.ReadMessage(); err != nil || string(p) != msg {
+				t.Errorf("chunked %v: got %q, %v, want %q", chunked, p, err, msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package jresponse
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrUnregisteredResponse is returned by ReadXML when no response type is
+// registered for the root element of a reply.
+var ErrUnregisteredResponse = errors.New("jresponse: no response registered")
+
+// junosVersionRegexp matches the release embedded in Junos namespaces, e.g.
+// the "12.3R6" of "http://xml.juniper.net/junos/12.3R6/junos-routing".
+var junosVersionRegexp = regexp.MustCompile(`^(http://xml\.juniper\.net/junos/)[^/]+(/.*)$`)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[xml.Name]func() ResponseReaderWriter{}
+	matchers   = map[xml.Name][]matcher{}
+)
+
+// matcher is a response type registered with RegisterMatch.
+type matcher struct {
+	match       func(p []byte) bool
+	newResponse func() ResponseReaderWriter
+}
+
+// JunosNamespace returns the namespace Junos uses for a schema in any
+// release, e.g. JunosNamespace("junos-routing") is
+// "http://xml.juniper.net/junos/*/junos-routing". Responses registered
+// under it match replies from every Junos version.
+func JunosNamespace(schema string) string {
+	return "http://xml.juniper.net/junos/*/" + schema
+}
+
+// normalizeName replaces the release in a Junos namespace with a wildcard.
+func normalizeName(name xml.Name) xml.Name {
+	name.Space = junosVersionRegexp.ReplaceAllString(name.Space, "${1}*${2}")
+	return name
+}
+
+// Register makes a response type available to ReadXML for replies whose
+// root element is name. An empty name.Space matches any namespace. It is
+// meant to be called from the init function of each response package, and
+// panics if name is registered twice.
+func Register(name xml.Name, newResponse func() ResponseReaderWriter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name = normalizeName(name)
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("jresponse: Register called twice for %s %s", name.Space, name.Local))
+	}
+	registry[name] = newResponse
+}
+
+// RegisterMatch is like Register, for response types that share their root
+// element with others, such as the bgp-information of both "show bgp
+// summary" and "show bgp neighbor". ReadXML calls match with the reply and
+// decodes it into the first type registered for name whose match returns
+// true, falling back to the type registered with Register.
+func RegisterMatch(name xml.Name, match func(p []byte) bool, newResponse func() ResponseReaderWriter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name = normalizeName(name)
+	matchers[name] = append(matchers[name], matcher{match, newResponse})
+}
+
+// newMatching returns a new, empty response for the reply p, whose root
+// element is name.
+func newMatching(name xml.Name, p []byte) (ResponseReaderWriter, error) {
+	registryMu.RLock()
+	candidates := append(append([]matcher{}, matchers[normalizeName(name)]...), matchers[xml.Name{Local: name.Local}]...)
+	registryMu.RUnlock()
+
+	for _, m := range candidates {
+		if m.match(p) {
+			return m.newResponse(), nil
+		}
+	}
+
+	return New(name)
+}
+
+// New returns a new, empty response registered for the root element name.
+func New(name xml.Name) (ResponseReaderWriter, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if newResponse, ok := registry[normalizeName(name)]; ok {
+		return newResponse(), nil
+	} else if newResponse, ok := registry[xml.Name{Local: name.Local}]; ok {
+		return newResponse(), nil
+	}
+
+	return nil, fmt.Errorf("%w for <%s xmlns=%q>", ErrUnregisteredResponse, name.Local, name.Space)
+}
+
+// RootElement returns the name of the first element in the XML document p.
+func RootElement(p []byte) (xml.Name, error) {
+	d := xml.NewDecoder(bytes.NewReader(p))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return xml.Name{}, errors.New("jresponse: no root element found")
+		} else if err != nil {
+			return xml.Name{}, err
+		}
+
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+// RootHasChild reports whether the root element of the response in p, or
+// of the first payload of an rpc-reply envelope, has a child element at
+// path. The path is the name of a child, or the names of its descendants
+// separated by slashes, e.g. "bgp-peer/peer-type". It is meant for the
+// match functions given to RegisterMatch.
+func RootHasChild(p []byte, path string) bool {
+	names := strings.Split(path, "/")
+	d := xml.NewDecoder(bytes.NewReader(p))
+	depth, rootDepth := 0, 1
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return false
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 1 && t.Name.Local == "rpc-reply":
+				rootDepth = 2
+			case depth == rootDepth && t.Name.Local == "rpc-error":
+				// not a payload
+				if d.Skip() != nil {
+					return false
+				}
+				depth--
+			case depth > rootDepth && t.Name.Local != names[depth-rootDepth-1]:
+				// not on the path
+				if d.Skip() != nil {
+					return false
+				}
+				depth--
+			case depth == rootDepth+len(names):
+				return true
+			}
+		case xml.EndElement:
+			// the root or first payload ended without the path
+			if depth <= rootDepth {
+				return false
+			}
+			depth--
+		}
+	}
+}
+
+// ReadXML decodes an RPC reply from r into the response type registered for
+// its root element, or for the first payload of an rpc-reply envelope.
+// Response packages register themselves when imported, so callers must
+// import each package they expect to receive, e.g.
+//
+//	import _ "github.com/JReyLBC/jresponse/command/traceroute"
+//
+// When the device reported rpc-errors the decoded response is returned
+// along with an RPCErrors.
+func ReadXML(r io.Reader) (ResponseReaderWriter, error) {
+
+	buf := bytes.Buffer{}
+
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	name, err := RootElement(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	if name.Local == "rpc-reply" {
+		reply := new(RPCReply)
+		if err := reply.unmarshal(buf.Bytes()); err != nil {
+			return nil, err
+		} else if len(reply.Payloads) == 0 {
+			if err := reply.Err(); err != nil {
+				return nil, err
+			}
+			return nil, errNoPayload
+		}
+		name = reply.Payloads[0].Name
+	}
+
+	resp, err := newMatching(name, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = resp.ReadXMLFrom(&buf)
+	return resp, err
+}
@@ -0,0 +1,156 @@
+package jresponse_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/command/ping"
+	"github.com/JReyLBC/jresponse/command/traceroute"
+	"github.com/JReyLBC/jresponse/show/bgp/neighbor"
+	"github.com/JReyLBC/jresponse/show/bgp/summary"
+	"github.com/JReyLBC/jresponse/show/interfaces"
+	"github.com/JReyLBC/jresponse/show/route"
+	"github.com/JReyLBC/jresponse/show/route/protocol/bgp"
+)
+
+const (
+	TRACE_ROUTE_XML_FILE  = "command/traceroute/traceroute_8.8.8.8.xml"
+	BGP_XML_FILE          = "show/route/protocol/bgp/show_route_protocol_bgp.xml"
+	BGP_SUMMARY_XML_FILE  = "show/bgp/summary/show_bgp_summary.xml"
+	BGP_NEIGHBOR_XML_FILE = "show/bgp/neighbor/show_bgp_neighbor.xml"
+	INTERFACES_XML_FILE   = "show/interfaces/show_interfaces_extensive.xml"
+	ROUTE_XML_FILE        = "show/route/show_route.xml"
+)
+
+func TestReadXML(t *testing.T) {
+	files := map[string]func(jresponse.ResponseReaderWriter) bool{
+		PING_XML_FILE: func(resp jresponse.ResponseReaderWriter) bool {
+			p, ok := resp.(*ping.Ping)
+			return ok && p.TargetHost == "8.8.8.8"
+		},
+		TRACE_ROUTE_XML_FILE: func(resp jresponse.ResponseReaderWriter) bool {
+			tr, ok := resp.(*traceroute.TraceRoute)
+			return ok && len(tr.Hops) == 1
+		},
+		BGP_XML_FILE: func(resp jresponse.ResponseReaderWriter) bool {
+			b, ok := resp.(*bgproute.BGPRoute)
+			return ok && len(b.RouteTables) == 1 && b.RouteTables[0].TableName == "inet.0"
+		},
+		BGP_SUMMARY_XML_FILE: func(resp jresponse.ResponseReaderWriter) bool {
+			s, ok := resp.(*bgpsummary.BGPSummary)
+			return ok && s.PeerCount == 4 && len(s.BGPPeer) == 4
+		},
+		BGP_NEIGHBOR_XML_FILE: func(resp jresponse.ResponseReaderWriter) bool {
+			n, ok := resp.(*bgpneighbor.BGPNeighbor)
+			return ok && len(n.BGPPeer) == 2 && n.BGPPeer[0].PeerGroup == "IX-PEERS"
+		},
+		INTERFACES_XML_FILE: func(resp jresponse.ResponseReaderWriter) bool {
+			ifs, ok := resp.(*interfaces.Interfaces)
+			return ok && ifs.CLIStyle() == interfaces.StyleExtensive
+		},
+		ROUTE_XML_FILE: func(resp jresponse.ResponseReaderWriter) bool {
+			r, ok := resp.(*route.Route)
+			return ok && len(r.RouteTables) == 2 && r.RouteTables[1].TableName == "inet.3"
+		},
+	}
+
+	for name, check := range files {
+		if file, err := os.Open(name); err != nil {
+			t.Error(err)
+		} else if resp, err := jresponse.ReadXML(file); err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if !check(resp) {
+			t.Errorf("%s: decoded unexpected response %T", name, resp)
+		}
+	}
+}
+
+func TestReadXMLBGPInformationError(t *testing.T) {
+	// a failed "show bgp summary" has no peer counts, nor any peers
+	reply := `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+    <bgp-information xmlns="http://xml.juniper.net/junos/15.1R5/junos-routing">
+        <rpc-error>
+            <error-severity>error</error-severity>
+            <error-message>BGP is not running</error-message>
+        </rpc-error>
+    </bgp-information>
+</rpc-reply>`
+
+	var rpcErr *jresponse.RPCError
+	if resp, err := jresponse.ReadXML(bytes.NewBufferString(reply)); !errors.As(err, &rpcErr) {
+		t.Errorf("expected an *RPCError, got %v", err)
+	} else if _, ok := resp.(*bgpsummary.BGPSummary); !ok {
+		t.Errorf("decoded unexpected response %T", resp)
+	}
+}
+
+func TestReadXMLUnregistered(t *testing.T) {
+	inputs := map[string]error{
+		`<?xml version="1.0"?><interface-information/>`:                             jresponse.ErrUnregisteredResponse,
+		`<ping-results xmlns="http://xml.juniper.net/junos/12.3R7/junos-routing"/>`: jresponse.ErrUnregisteredResponse,
+		``: nil,
+	}
+
+	for input, want := range inputs {
+		if _, err := jresponse.ReadXML(bytes.NewBufferString(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		} else if want != nil && !errors.Is(err, want) {
+			t.Errorf("%q: expected %v, got %v", input, want, err)
+		}
+	}
+}
+
+func TestRootHasChild(t *testing.T) {
+	inputs := map[string]bool{
+		`<bgp-information><peer-count>1</peer-count></bgp-information>`:                             true,
+		`<bgp-information><bgp-peer><peer-count/></bgp-peer></bgp-information>`:                     false,
+		`<rpc-reply><bgp-information><peer-count>1</peer-count></bgp-information></rpc-reply>`:      true,
+		`<rpc-reply><rpc-error><peer-count/></rpc-error><bgp-information/></rpc-reply>`:             false,
+		`<bgp-information/><peer-count/>`:                                                           false,
+		`<rpc-reply><bgp-information/><bgp-information><peer-count/></bgp-information></rpc-reply>`: false,
+		``: false,
+	}
+
+	for input, want := range inputs {
+		if has := jresponse.RootHasChild([]byte(input), "peer-count"); has != want {
+			t.Errorf("%q: RootHasChild() = %v, should be %v", input, has, want)
+		}
+	}
+
+	paths := map[string]bool{
+		`<bgp-information><bgp-peer><peer-type>External</peer-type></bgp-peer></bgp-information>`:  true,
+		`<bgp-information><bgp-peer/><bgp-peer><peer-type/></bgp-peer></bgp-information>`:          true,
+		`<bgp-information><peer-type/><bgp-peer><peer-as>1</peer-as></bgp-peer></bgp-information>`: false,
+		`<bgp-information><bgp-rib><bgp-peer><peer-type/></bgp-peer></bgp-rib></bgp-information>`:  false,
+	}
+
+	for input, want := range paths {
+		if has := jresponse.RootHasChild([]byte(input), "bgp-peer/peer-type"); has != want {
+			t.Errorf("%q: RootHasChild() = %v, should be %v", input, has, want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	name := xml.Name{Space: "http://xml.juniper.net/junos/15.1R5/junos-probe-tests", Local: "traceroute-results"}
+	if resp, err := jresponse.New(name); err != nil {
+		t.Error(err)
+	} else if _, ok := resp.(*traceroute.TraceRoute); !ok {
+		t.Errorf("New returned %T", resp)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering ping-results twice should panic")
+		}
+	}()
+
+	jresponse.Register(xml.Name{Space: jresponse.JunosNamespace("junos-probe-tests"), Local: "ping-results"},
+		func() jresponse.ResponseReaderWriter { return new(ping.Ping) })
+}
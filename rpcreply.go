@@ -0,0 +1,328 @@
+package jresponse
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// ErrorInfo holds the protocol or data model specific details of an
+// rpc-error.
+type ErrorInfo struct {
+	BadElement   string `xml:"bad-element,omitempty"   json:"bad-element,omitempty"`
+	BadMessage   string `xml:"bad-message,omitempty"   json:"bad-message,omitempty"`
+	BadAttribute string `xml:"bad-attribute,omitempty" json:"bad-attribute,omitempty"`
+	BadNamespace string `xml:"bad-namespace,omitempty" json:"bad-namespace,omitempty"`
+	SessionID    string `xml:"session-id,omitempty"    json:"session-id,omitempty"`
+}
+
+// RPCError is an rpc-error reported by a device, either in the rpc-reply
+// envelope or inside a response.
+type RPCError struct {
+	Type     string     `xml:"error-type"           json:"error-type"`
+	Tag      string     `xml:"error-tag"            json:"error-tag"`
+	Severity string     `xml:"error-severity"       json:"error-severity"`
+	Path     string     `xml:"error-path,omitempty" json:"error-path,omitempty"`
+	Message  string     `xml:"error-message"        json:"error-message"`
+	Info     *ErrorInfo `xml:"error-info,omitempty" json:"error-info,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	msg := strings.TrimSpace(e.Message)
+	if msg == "" {
+		msg = strings.TrimSpace(e.Tag)
+	}
+
+	s := fmt.Sprintf("rpc %s: %s", e.severity(), msg)
+	if e.Info != nil {
+		if bad := strings.TrimSpace(e.Info.BadElement); bad != "" {
+			s += fmt.Sprintf(" (bad element: %s)", bad)
+		}
+		if bad := strings.TrimSpace(e.Info.BadMessage); bad != "" {
+			s += fmt.Sprintf(" (bad message: %s)", bad)
+		}
+	}
+	return s
+}
+
+func (e *RPCError) severity() string {
+	if s := strings.TrimSpace(e.Severity); s != "" {
+		return s
+	}
+	return SeverityError
+}
+
+// IsWarning reports whether the device reported e with a warning severity.
+func (e *RPCError) IsWarning() bool {
+	return e.severity() == SeverityWarning
+}
+
+// RPCErrors is a list of rpc-errors that together make up a failed reply.
+type RPCErrors []RPCError
+
+func (errs RPCErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i := range errs {
+		msgs[i] = errs[i].Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap allows errors.As to find each *RPCError.
+func (errs RPCErrors) Unwrap() []error {
+	wrapped := make([]error, len(errs))
+	for i := range errs {
+		wrapped[i] = &errs[i]
+	}
+	return wrapped
+}
+
+// CheckErrors returns the rpc-errors in errs that are not warnings as an
+// RPCErrors, or nil if there are none.
+func CheckErrors(errs []RPCError) error {
+	var failed RPCErrors
+	for _, e := range errs {
+		if !e.IsWarning() {
+			failed = append(failed, e)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	return failed
+}
+
+// Payload is a child element of an rpc-reply other than rpc-error or ok,
+// kept as the raw XML the device sent. The namespace prefixes declared on
+// the rpc-reply, such as xmlns:junos, are declared again on the payload's
+// root element, so Raw decodes on its own.
+type Payload struct {
+	Name xml.Name
+	Raw  []byte
+}
+
+// RPCReply is the NETCONF rpc-reply envelope wrapping a response.
+type RPCReply struct {
+	MessageID string
+	Ok        bool
+	RPCErrors []RPCError
+	Payloads  []Payload
+}
+
+// Errors returns the rpc-errors in the reply that are not warnings.
+func (reply *RPCReply) Errors() []RPCError {
+	var errs []RPCError
+	for _, e := range reply.RPCErrors {
+		if !e.IsWarning() {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// Warnings returns the rpc-errors in the reply with a warning severity.
+func (reply *RPCReply) Warnings() []RPCError {
+	var warnings []RPCError
+	for _, e := range reply.RPCErrors {
+		if e.IsWarning() {
+			warnings = append(warnings, e)
+		}
+	}
+	return warnings
+}
+
+// Err returns the reply's errors, excluding warnings, as an RPCErrors, or
+// nil if the device reported none.
+func (reply *RPCReply) Err() error {
+	return CheckErrors(reply.RPCErrors)
+}
+
+// ReadXMLFrom decodes an rpc-reply envelope from r.
+func (reply *RPCReply) ReadXMLFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	}
+
+	return n, reply.unmarshal(buf.Bytes())
+}
+
+func (reply *RPCReply) unmarshal(p []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(p))
+
+	var (
+		depth   int
+		start   int64
+		decls   []xml.Attr
+		inherit []xml.Attr
+	)
+
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			if depth != 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+
+			switch {
+			case depth == 1:
+				if tok.Name.Local != "rpc-reply" {
+					return fmt.Errorf("jresponse: expected <rpc-reply>, found <%s>", tok.Name.Local)
+				}
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "message-id" {
+						reply.MessageID = attr.Value
+					} else if attr.Name.Space == "xmlns" {
+						decls = append(decls, attr)
+					}
+				}
+
+			case depth == 2 && tok.Name.Local == "ok":
+				reply.Ok = true
+
+			case depth == 2 && tok.Name.Local == "rpc-error":
+				var e RPCError
+				if err := d.Skip(); err != nil {
+					return err
+				} else if err := Unmarshal(p[offset:d.InputOffset()], &e); err != nil {
+					return err
+				}
+				reply.RPCErrors = append(reply.RPCErrors, e)
+				depth--
+
+			case depth == 2:
+				start = offset
+				inherit = undeclared(decls, tok.Attr)
+				reply.Payloads = append(reply.Payloads, Payload{Name: tok.Name})
+			}
+
+		case xml.EndElement:
+			if depth == 2 && tok.Name.Local != "ok" && len(reply.Payloads) > 0 {
+				payload := &reply.Payloads[len(reply.Payloads)-1]
+				if payload.Raw == nil && payload.Name == tok.Name {
+					payload.Raw = declare(bytes.TrimSpace(p[start:d.InputOffset()]), inherit)
+				}
+			}
+			depth--
+		}
+	}
+}
+
+// undeclared returns the namespace declarations in decls that attrs, the
+// attributes of a child element, don't declare again.
+func undeclared(decls, attrs []xml.Attr) []xml.Attr {
+	var missing []xml.Attr
+	for _, decl := range decls {
+		redeclared := false
+		for _, attr := range attrs {
+			if attr.Name == decl.Name {
+				redeclared = true
+				break
+			}
+		}
+		if !redeclared {
+			missing = append(missing, decl)
+		}
+	}
+	return missing
+}
+
+// declare adds the namespace declarations decls to the root element of the
+// raw XML element p.
+func declare(p []byte, decls []xml.Attr) []byte {
+	if len(decls) == 0 {
+		return p
+	}
+
+	end := bytes.IndexAny(p, " \t\r\n/>")
+	if end < 0 {
+		return p
+	}
+
+	buf := bytes.Buffer{}
+	buf.Write(p[:end])
+	for _, decl := range decls {
+		fmt.Fprintf(&buf, ` xmlns:%s="`, decl.Name.Local)
+		xml.EscapeText(&buf, []byte(decl.Value))
+		buf.WriteByte('"')
+	}
+	buf.Write(p[end:])
+	return buf.Bytes()
+}
+
+// IsRPCReply reports whether p is an XML document whose root element is an
+// rpc-reply envelope.
+func IsRPCReply(p []byte) bool {
+	name, err := RootElement(p)
+	return err == nil && name.Local == "rpc-reply"
+}
+
+// UnmarshalReply decodes the XML document p into the response v. When p is
+// a full rpc-reply envelope, the first payload that decodes into v is used,
+// and any rpc-errors in the envelope are appended to errs. A payload that
+// fails to decode leaves v as it was. Text is trimmed as by NewDecoder.
+func UnmarshalReply(p []byte, v interface{}, errs *[]RPCError) error {
+	if !IsRPCReply(p) {
+		return Unmarshal(p, v)
+	}
+
+	reply := new(RPCReply)
+	if err := reply.unmarshal(p); err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, payload := range reply.Payloads {
+		// each payload is decoded into a copy of v, so one that fails
+		// partway doesn't leave its fields behind for the next
+		dst, attempt := reflect.ValueOf(v), v
+		copied := dst.Kind() == reflect.Ptr && !dst.IsNil()
+		if copied {
+			c := reflect.New(dst.Elem().Type())
+			c.Elem().Set(dst.Elem())
+			attempt = c.Interface()
+		}
+
+		err := Unmarshal(payload.Raw, attempt)
+		if err == nil {
+			if copied {
+				dst.Elem().Set(reflect.ValueOf(attempt).Elem())
+			}
+			firstErr = nil
+			break
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	*errs = append(*errs, reply.RPCErrors...)
+
+	if firstErr != nil && len(reply.Errors()) == 0 {
+		return firstErr
+	}
+	return nil
+}
+
+// errNoPayload is returned by ReadXML for an rpc-reply without a response.
+var errNoPayload = errors.New("jresponse: rpc-reply contains no response")
@@ -0,0 +1,246 @@
+package jresponse_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/command/ping"
+	"github.com/JReyLBC/jresponse/command/traceroute"
+)
+
+const rpcReplyXML = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:junos="http://xml.juniper.net/junos/12.3R7/junos" message-id="101">
+    <rpc-error>
+        <error-type>protocol</error-type>
+        <error-tag>operation-failed</error-tag>
+        <error-severity>warning</error-severity>
+        <error-message>statement has no effect</error-message>
+    </rpc-error>
+    <ping-results xmlns="http://xml.juniper.net/junos/12.3R7/junos-probe-tests">
+        <target-host>8.8.8.8</target-host>
+        <target-ip>8.8.8.8</target-ip>
+        <packet-size>56</packet-size>
+    </ping-results>
+    <output>extra</output>
+</rpc-reply>`
+
+const rpcErrorXML = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="102">
+    <rpc-error>
+        <error-type>protocol</error-type>
+        <error-tag>operation-failed</error-tag>
+        <error-severity>error</error-severity>
+        <error-message>syntax error, expecting &lt;host&gt;</error-message>
+        <error-info>
+            <bad-element>hots</bad-element>
+        </error-info>
+    </rpc-error>
+</rpc-reply>`
+
+func TestRPCReplyReadXMLFrom(t *testing.T) {
+
+	reply := new(jresponse.RPCReply)
+	if _, err := reply.ReadXMLFrom(bytes.NewBufferString(rpcReplyXML)); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply.MessageID != "101" {
+		t.Errorf("unexpected message-id %q", reply.MessageID)
+	} else if reply.Ok {
+		t.Error("reply without <ok/> reported ok")
+	} else if len(reply.Warnings()) != 1 || len(reply.Errors()) != 0 || reply.Err() != nil {
+		t.Errorf("expected a single warning, got %v", reply.RPCErrors)
+	}
+
+	names := []string{}
+	for _, payload := range reply.Payloads {
+		names = append(names, payload.Name.Local)
+	}
+	if !reflect.DeepEqual(names, []string{"ping-results", "output"}) {
+		t.Errorf("unexpected payloads %v", names)
+	} else if !bytes.HasPrefix(reply.Payloads[0].Raw, []byte("<ping-results")) ||
+		!bytes.HasSuffix(reply.Payloads[0].Raw, []byte("</ping-results>")) {
+		t.Errorf("unexpected raw payload %q", reply.Payloads[0].Raw)
+	} else if !bytes.HasPrefix(reply.Payloads[1].Raw,
+		[]byte(`<output xmlns:junos="http://xml.juniper.net/junos/12.3R7/junos">`)) {
+		t.Errorf("payload does not declare the rpc-reply's prefixes: %q", reply.Payloads[1].Raw)
+	}
+
+	ok := new(jresponse.RPCReply)
+	if _, err := ok.ReadXMLFrom(bytes.NewBufferString(`<rpc-reply message-id="7"><ok/></rpc-reply>`)); err != nil {
+		t.Error(err)
+	} else if !ok.Ok || len(ok.Payloads) != 0 {
+		t.Errorf("unexpected reply %+v", ok)
+	}
+
+	if _, err := new(jresponse.RPCReply).ReadXMLFrom(bytes.NewBufferString(`<ping-results/>`)); err == nil {
+		t.Error("expected an error for a document without an rpc-reply")
+	}
+}
+
+func TestReadXMLFromEnvelope(t *testing.T) {
+
+	p := new(ping.Ping)
+	if _, err := p.ReadXMLFrom(bytes.NewBufferString(rpcReplyXML)); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.TargetHost != "8.8.8.8" || p.PacketSize != 56 {
+		t.Errorf("payload not decoded: %+v", p)
+	} else if len(p.Errors) != 1 || !p.Errors[0].IsWarning() {
+		t.Errorf("envelope warning not kept: %+v", p.Errors)
+	}
+}
+
+func TestReadXMLFromRPCError(t *testing.T) {
+
+	tr := new(traceroute.TraceRoute)
+	_, err := tr.ReadXMLFrom(bytes.NewBufferString(rpcErrorXML))
+
+	var rpcErr *jresponse.RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("expected an *RPCError, got %v", err)
+	}
+
+	if rpcErr.Info == nil || strings.TrimSpace(rpcErr.Info.BadElement) != "hots" {
+		t.Errorf("error-info not decoded: %+v", rpcErr.Info)
+	} else if msg := err.Error(); msg != "rpc error: syntax error, expecting <host> (bad element: hots)" {
+		t.Errorf("unexpected error message %q", msg)
+	} else if len(tr.Errors) != 1 {
+		t.Errorf("rpc-error not added to response: %+v", tr.Errors)
+	}
+
+	if _, err := jresponse.ReadXML(bytes.NewBufferString(rpcErrorXML)); !errors.As(err, &rpcErr) {
+		t.Errorf("ReadXML: expected an *RPCError, got %v", err)
+	}
+}
+
+func TestReadXMLFromRPCErrorAndMalformedAddr(t *testing.T) {
+	reply := `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="104">
+    <ping-results xmlns="http://xml.juniper.net/junos/12.3R7/junos-probe-tests">
+        <target-host>8.8.8.300</target-host>
+        <target-ip>8.8.8.300</target-ip>
+    </ping-results>
+    <rpc-error>
+        <error-type>application</error-type>
+        <error-tag>operation-failed</error-tag>
+        <error-severity>error</error-severity>
+        <error-message>ping: cannot resolve 8.8.8.300: Unknown host</error-message>
+    </rpc-error>
+</rpc-reply>`
+
+	// the device's error is returned, not the address it couldn't resolve
+	var rpcErr *jresponse.RPCError
+	if _, err := new(ping.Ping).ReadXMLFrom(bytes.NewBufferString(reply)); !errors.As(err, &rpcErr) {
+		t.Errorf("expected an *RPCError, got %v", err)
+	}
+}
+
+// rpcReplyPaddedXML is a reply with the newline padded text of some Junos
+// releases, a multi-line error message and CRLF line endings.
+var rpcReplyPaddedXML = strings.ReplaceAll(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="103">
+    <rpc-error>
+        <error-type>protocol</error-type>
+        <error-tag>operation-failed</error-tag>
+        <error-severity>
+warning
+</error-severity>
+        <error-message>
+statement has no effect:
+    wait 2
+</error-message>
+    </rpc-error>
+    <traceroute-results xmlns="http://xml.juniper.net/junos/12.1X46/junos-probe-tests">
+        <target-host>
+dns.google
+</target-host>
+        <target-ip>8.8.8.8</target-ip>
+        <hop>
+            <ttl-value>1</ttl-value>
+            <last-ip-address>
+69.73.0.2
+</last-ip-address>
+            <last-host-name>
+xe-0-0-0.edge1.ashburn.example.net
+</last-host-name>
+        </hop>
+    </traceroute-results>
+</rpc-reply>`, "\n", "\r\n")
+
+func TestReadXMLFromPartialPayload(t *testing.T) {
+	reply := `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="105">
+    <ping-results xmlns="http://xml.juniper.net/junos/12.3R7/junos-probe-tests">
+        <target-host>www.example.com</target-host>
+        <probe-result><probe-index>1</probe-index><rtt>lost</rtt></probe-result>
+    </ping-results>
+    <ping-results xmlns="http://xml.juniper.net/junos/12.3R7/junos-probe-tests">
+        <target-ip>8.8.8.8</target-ip>
+    </ping-results>
+</rpc-reply>`
+
+	// nothing the first payload decoded before failing is kept
+	p := new(ping.Ping)
+	if _, err := p.ReadXMLFrom(bytes.NewBufferString(reply)); err != nil {
+		t.Fatal(err)
+	} else if p.TargetHost != "" || len(p.ProbeResult) != 0 || p.TargetIP != "8.8.8.8" {
+		t.Errorf("decoded %+v", p)
+	}
+}
+
+func TestReadXMLFromPadded(t *testing.T) {
+
+	tr := new(traceroute.TraceRoute)
+	if _, err := tr.ReadXMLFrom(bytes.NewBufferString(rpcReplyPaddedXML)); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.TargetHost != "dns.google" || len(tr.Hops) != 1 {
+		t.Errorf("payload not decoded: %+v", tr)
+	} else if hop := tr.Hops[0]; hop.LastIPAddr != "69.73.0.2" || hop.LastHostName != "xe-0-0-0.edge1.ashburn.example.net" {
+		t.Errorf("hop not trimmed: %+v", hop)
+	}
+
+	// the lines of the message are kept apart, without the padding
+	if len(tr.Errors) != 1 || !tr.Errors[0].IsWarning() {
+		t.Errorf("envelope warning not kept: %+v", tr.Errors)
+	} else if msg := tr.Errors[0].Message; msg != "statement has no effect:\n    wait 2" {
+		t.Errorf("unexpected error message %q", msg)
+	}
+}
+
+func TestReadXMLEnvelope(t *testing.T) {
+	if resp, err := jresponse.ReadXML(bytes.NewBufferString(rpcReplyXML)); err != nil {
+		t.Error(err)
+	} else if p, ok := resp.(*ping.Ping); !ok || p.TargetHost != "8.8.8.8" {
+		t.Errorf("unexpected response %#v", resp)
+	}
+
+	// fixtures without an envelope still decode without errors
+	if file, err := os.Open(PING_XML_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := new(ping.Ping).ReadXMLFrom(file); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	errs := []jresponse.RPCError{
+		{Severity: "warning", Message: "ignored"},
+		{Severity: "error", Message: "first"},
+		{Message: "second"},
+	}
+
+	err := jresponse.CheckErrors(errs)
+	if rpcErrs, ok := err.(jresponse.RPCErrors); !ok || len(rpcErrs) != 2 {
+		t.Fatalf("unexpected errors %v", err)
+	} else if err.Error() != "rpc error: first; rpc error: second" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+
+	if err := jresponse.CheckErrors(errs[:1]); err != nil {
+		t.Errorf("warnings should not be errors: %v", err)
+	}
+}
@@ -0,0 +1,546 @@
+package bgpneighbor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	tmpl "text/template"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	bgpNeighborTmpl *tmpl.Template
+)
+
+func init() {
+	var err error
+	if bgpNeighborTmpl, err = tmpl.New("bgpNeighborTmpl").Parse(bgpNeighborTmplStr); err != nil {
+		log.Fatalln(err)
+	}
+
+	// "show bgp summary" replies with a bgp-information too, whose peers
+	// have no type or local address
+	jresponse.RegisterMatch(xml.Name{Space: jresponse.JunosNamespace("junos-routing"), Local: "bgp-information"},
+		func(p []byte) bool {
+			return jresponse.RootHasChild(p, "bgp-peer/peer-type") || jresponse.RootHasChild(p, "bgp-peer/local-address")
+		},
+		func() jresponse.ResponseReaderWriter { return new(BGPNeighbor) })
+}
+
+const bgpNeighborTmplStr = "{{range $i, $peer := .BGPPeer}}{{if $i}}\n{{end}}" +
+	"{{printf \"Peer: %s AS %-10d Local: %s AS %d\" .PeerAddress .PeerAS .LocalAddress .LocalAS}}\n" +
+	"{{with .Description}}  Description: {{.}}\n{{end}}" +
+	"{{with .PeerGroup}}{{printf \"  Group: %-24s Routing-Instance: %s\" . $peer.PeerCfgRTI}}\n{{end}}" +
+	"{{printf \"  Type: %-10s State: %-12s Flags: <%s>\" .PeerType .PeerState .PeerFlags}}\n" +
+	"{{printf \"  Last State: %-12s Last Event: %s\" .LastState .LastEvent}}\n" +
+	"  Last Error: {{.LastError}}\n" +
+
+	"{{with .BGPOptionInformation}}" +
+	"{{if or .ExportPolicy .ImportPolicy}}  Export: [ {{.ExportPolicy}} ] Import: [ {{.ImportPolicy}} ]\n{{end}}" +
+	"  Options: <{{.BGPOptions}}>\n" +
+	"  {{with .LocalAddress}}Local Address: {{.}} {{end}}Holdtime: {{.Holdtime}} Preference: {{.Preference}}\n" +
+	"{{end}}" +
+
+	"  Number of flaps: {{.FlapCount}}\n" +
+	"{{with .LastFlapEvent}}  Last flap event: {{.}}\n{{end}}" +
+	"{{range .BGPError}}  Error: '{{.Name}}' Sent: {{.SendCount}} Recv: {{.ReceiveCount}}\n{{end}}" +
+
+	"{{if .PeerID}}" +
+	"{{printf \"  Peer ID: %-16s Local ID: %-16s Active Holdtime: %d\" .PeerID .LocalID .ActiveHoldtime}}\n" +
+	"{{printf \"  Keepalive Interval: %-5d Group index: %-5d Peer index: %d\" " +
+	".KeepaliveInterval .GroupIndex .PeerIndex}}\n" +
+	"{{end}}" +
+
+	"{{with .LocalInterfaceName}}  Local Interface: {{.}}\n{{end}}" +
+	"{{with .NLRITypePeer}}  NLRI advertised by peer: {{.}}\n{{end}}" +
+	"{{with .NLRITypeSession}}  NLRI for this session: {{.}}\n{{end}}" +
+
+	"{{range .BGPRIB}}  Table {{.Name}} Bit: {{.RIBBit}}\n" +
+	"{{with .BGPRIBState}}    RIB State: {{.}}\n{{end}}" +
+	"{{with .SendState}}    Send state: {{.}}\n{{end}}" +
+	"{{printf \"    Active prefixes:              %d\" .ActivePrefixCount}}\n" +
+	"{{printf \"    Received prefixes:            %d\" .ReceivedPrefixCount}}\n" +
+	"{{printf \"    Accepted prefixes:            %d\" .AcceptedPrefixCount}}\n" +
+	"{{printf \"    Suppressed due to damping:    %d\" .SuppressedPrefixCount}}\n" +
+	"{{printf \"    Advertised prefixes:          %d\" .AdvertisedPrefixCount}}\n" +
+	"{{end}}" +
+
+	"{{if .PeerID}}" +
+	"{{printf \"  Last traffic (seconds): Received %-6d Sent %-6d Checked %d\" .LastReceived .LastSent .LastChecked}}\n" +
+	"{{end}}" +
+	"{{printf \"  Input messages:  Total %-10d Updates %-8d Refreshes %-5d Octets %d\" " +
+	".InputMessages .InputUpdates .InputRefreshes .InputOctets}}\n" +
+	"{{printf \"  Output messages: Total %-10d Updates %-8d Refreshes %-5d Octets %d\" " +
+	".OutputMessages .OutputUpdates .OutputRefreshes .OutputOctets}}\n" +
+	"{{range .BGPOutputQueue}}  Output Queue[{{.Number}}]: {{.Count}}{{with .TableName}} ({{.}}){{end}}\n{{end}}" +
+	"{{end}}"
+
+type BGPOptionInformation struct {
+	ExportPolicy string `xml:"export-policy,omitempty" json:"export-policy,omitempty"`
+	ImportPolicy string `xml:"import-policy,omitempty" json:"import-policy,omitempty"`
+	BGPOptions   string `xml:"bgp-options"             json:"bgp-options"`
+	LocalAddress string `xml:"local-address,omitempty" json:"local-address,omitempty"`
+	Holdtime     uint   `xml:"holdtime"                json:"holdtime"`
+	Preference   uint   `xml:"preference"              json:"preference"`
+}
+
+// Options returns the configured options, such as "Preference" and
+// "HoldTime".
+func (opts *BGPOptionInformation) Options() []string {
+	return strings.Fields(opts.BGPOptions)
+}
+
+// BGPError counts the NOTIFICATION messages of one kind sent to and
+// received from the peer.
+type BGPError struct {
+	Name         string `xml:"name"          json:"name"`
+	SendCount    uint   `xml:"send-count"    json:"send-count"`
+	ReceiveCount uint   `xml:"receive-count" json:"receive-count"`
+}
+
+type BGPRIB struct {
+	Name                  string `xml:"name"                    json:"name"`
+	RIBBit                string `xml:"rib-bit"                 json:"rib-bit"`
+	BGPRIBState           string `xml:"bgp-rib-state,omitempty" json:"bgp-rib-state,omitempty"`
+	SendState             string `xml:"send-state,omitempty"    json:"send-state,omitempty"`
+	ActivePrefixCount     int    `xml:"active-prefix-count"     json:"active-prefix-count"`
+	ReceivedPrefixCount   int    `xml:"received-prefix-count"   json:"received-prefix-count"`
+	AcceptedPrefixCount   int    `xml:"accepted-prefix-count"   json:"accepted-prefix-count"`
+	SuppressedPrefixCount int    `xml:"suppressed-prefix-count" json:"suppressed-prefix-count"`
+	AdvertisedPrefixCount int    `xml:"advertised-prefix-count" json:"advertised-prefix-count"`
+}
+
+type BGPOutputQueue struct {
+	Number    uint   `xml:"number"               json:"number"`
+	Count     uint   `xml:"count"                json:"count"`
+	TableName string `xml:"table-name,omitempty" json:"table-name,omitempty"`
+}
+
+type BGPPeer struct {
+	PeerAddress          string                `xml:"peer-address"                     json:"peer-address"`
+	PeerAS               uint                  `xml:"peer-as"                          json:"peer-as"`
+	LocalAddress         string                `xml:"local-address"                    json:"local-address"`
+	LocalAS              uint                  `xml:"local-as"                         json:"local-as"`
+	Description          string                `xml:"description,omitempty"            json:"description,omitempty"`
+	PeerGroup            string                `xml:"peer-group,omitempty"             json:"peer-group,omitempty"`
+	PeerCfgRTI           string                `xml:"peer-cfg-rti,omitempty"           json:"peer-cfg-rti,omitempty"`
+	PeerType             string                `xml:"peer-type"                        json:"peer-type"`
+	PeerState            string                `xml:"peer-state"                       json:"peer-state"`
+	PeerFlags            string                `xml:"peer-flags"                       json:"peer-flags"`
+	LastState            string                `xml:"last-state"                       json:"last-state"`
+	LastEvent            string                `xml:"last-event"                       json:"last-event"`
+	LastError            string                `xml:"last-error"                       json:"last-error"`
+	BGPOptionInformation *BGPOptionInformation `xml:"bgp-option-information,omitempty" json:"bgp-option-information,omitempty"`
+	FlapCount            uint                  `xml:"flap-count"                       json:"flap-count"`
+	LastFlapEvent        string                `xml:"last-flap-event,omitempty"        json:"last-flap-event,omitempty"`
+	BGPError             []BGPError            `xml:"bgp-error,omitempty"              json:"bgp-error,omitempty"`
+	PeerID               string                `xml:"peer-id,omitempty"                json:"peer-id,omitempty"`
+	LocalID              string                `xml:"local-id,omitempty"               json:"local-id,omitempty"`
+	ActiveHoldtime       uint                  `xml:"active-holdtime,omitempty"        json:"active-holdtime,omitempty"`
+	KeepaliveInterval    uint                  `xml:"keepalive-interval,omitempty"     json:"keepalive-interval,omitempty"`
+	GroupIndex           uint                  `xml:"group-index,omitempty"            json:"group-index,omitempty"`
+	PeerIndex            uint                  `xml:"peer-index,omitempty"             json:"peer-index,omitempty"`
+	LocalInterfaceName   string                `xml:"local-interface-name,omitempty"   json:"local-interface-name,omitempty"`
+	NLRITypePeer         string                `xml:"nlri-type-peer,omitempty"         json:"nlri-type-peer,omitempty"`
+	NLRITypeSession      string                `xml:"nlri-type-session,omitempty"      json:"nlri-type-session,omitempty"`
+	BGPRIB               []BGPRIB              `xml:"bgp-rib,omitempty"                json:"bgp-rib,omitempty"`
+	LastReceived         uint                  `xml:"last-received,omitempty"          json:"last-received,omitempty"`
+	LastSent             uint                  `xml:"last-sent,omitempty"              json:"last-sent,omitempty"`
+	LastChecked          uint                  `xml:"last-checked,omitempty"           json:"last-checked,omitempty"`
+	InputMessages        uint                  `xml:"input-messages"                   json:"input-messages"`
+	InputUpdates         uint                  `xml:"input-updates"                    json:"input-updates"`
+	InputRefreshes       uint                  `xml:"input-refreshes"                  json:"input-refreshes"`
+	InputOctets          uint                  `xml:"input-octets"                     json:"input-octets"`
+	OutputMessages       uint                  `xml:"output-messages"                  json:"output-messages"`
+	OutputUpdates        uint                  `xml:"output-updates"                   json:"output-updates"`
+	OutputRefreshes      uint                  `xml:"output-refreshes"                 json:"output-refreshes"`
+	OutputOctets         uint                  `xml:"output-octets"                    json:"output-octets"`
+	BGPOutputQueue       []BGPOutputQueue      `xml:"bgp-output-queue,omitempty"       json:"bgp-output-queue,omitempty"`
+}
+
+// parseAddrPort parses an address as Junos prints the ends of a session,
+// with the TCP port following a "+", e.g. "10.0.0.2+179". The port is 0
+// when the session isn't up.
+func parseAddrPort(s string) (netip.AddrPort, error) {
+	host, port := splitPort(s)
+	addr, err := jresponse.ParseAddr(host)
+	if err != nil || port == "" {
+		return netip.AddrPortFrom(addr, 0), err
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid port in %q", s)
+	}
+	return netip.AddrPortFrom(addr, uint16(p)), nil
+}
+
+// splitPort splits an address from the port Junos appends to it.
+func splitPort(s string) (host, port string) {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '+'); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// PeerAddr returns the peer's address and TCP port.
+func (peer *BGPPeer) PeerAddr() (netip.AddrPort, error) {
+	return parseAddrPort(peer.PeerAddress)
+}
+
+// LocalAddr returns the local address and TCP port of the session.
+func (peer *BGPPeer) LocalAddr() (netip.AddrPort, error) {
+	return parseAddrPort(peer.LocalAddress)
+}
+
+// Established reports whether the session is up.
+func (peer *BGPPeer) Established() bool {
+	return peer.PeerState == "Established"
+}
+
+// NegotiatedFamilies returns the address families negotiated for the
+// session, such as "inet-unicast".
+func (peer *BGPPeer) NegotiatedFamilies() []string {
+	return strings.Fields(peer.NLRITypeSession)
+}
+
+type RPCError = jresponse.RPCError
+
+// Represents the BGP neighbor XML structure returned by
+// get-bgp-neighbor-information, and is used to convert it from XML to JSON.
+type BGPNeighbor struct {
+	XMLName    xml.Name        `xml:"bgp-information"           json:"-"`
+	JunosNS    jresponse.XMLNS `xml:"xmlns junos,attr,omitempty" json:"-"`
+	BGPPeer    []BGPPeer       `xml:"bgp-peer"                  json:"bgp-peer"`
+	Errors     []RPCError      `xml:"rpc-error,omitempty"       json:"rpc-error,omitempty"`
+	OriginHost string          `xml:"-"                         json:"originhost,omitempty"`
+	OriginIP   string          `xml:"-"                         json:"originip,omitempty"`
+}
+
+// Validate checks the addresses in the response, returning a
+// *jresponse.AddrError for each one that is malformed.
+func (neighbor *BGPNeighbor) Validate() error {
+	v := jresponse.AddrValidator{}
+	for i, peer := range neighbor.BGPPeer {
+		path := fmt.Sprintf("bgp-information/bgp-peer[%d]/", i+1)
+		host, _ := splitPort(peer.PeerAddress)
+		v.Addr(path+"peer-address", host)
+		host, _ = splitPort(peer.LocalAddress)
+		v.Addr(path+"local-address", host)
+		if peer.BGPOptionInformation != nil {
+			v.Addr(path+"bgp-option-information/local-address", peer.BGPOptionInformation.LocalAddress)
+		}
+	}
+	return v.Err()
+}
+
+func (neighbor *BGPNeighbor) WriteXMLTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteXML(w, neighbor, neighbor.XMLName)
+}
+
+func (neighbor *BGPNeighbor) WriteJSONTo(w io.Writer) (n int64, err error) {
+	if s, err := json.Marshal(neighbor); err != nil {
+		return 0, err
+	} else {
+		return bytes.NewBuffer(s).WriteTo(w)
+	}
+}
+
+var bgpNeighborJSONTimeFields = jresponse.JSONTimeFields{
+	Durations: map[string]time.Duration{
+		"holdtime":           time.Second,
+		"active-holdtime":    time.Second,
+		"keepalive-interval": time.Second,
+		"last-received":      time.Second,
+		"last-sent":          time.Second,
+		"last-checked":       time.Second,
+	},
+}
+
+// WriteJSONToWithOptions writes the same JSON as WriteJSONTo, with timers
+// encoded as selected by opts.
+func (neighbor *BGPNeighbor) WriteJSONToWithOptions(w io.Writer, opts jresponse.JSONOptions) (n int64, err error) {
+	return jresponse.WriteJSONWithOptions(w, neighbor, bgpNeighborJSONTimeFields, opts)
+}
+
+// WriteJunosJSONTo writes the BGP neighbors in the JSON Junos encodes them
+// as with "| display json".
+func (neighbor *BGPNeighbor) WriteJunosJSONTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteJunosJSON(w, neighbor)
+}
+
+func (neighbor *BGPNeighbor) WriteCLITo(w io.Writer) error {
+	return bgpNeighborTmpl.Execute(w, neighbor)
+}
+
+func (neighbor *BGPNeighbor) ReadXMLFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	}
+
+	if err := jresponse.UnmarshalReply(buf.Bytes(), neighbor, &neighbor.Errors); err != nil {
+		return n, err
+	}
+
+	if err := jresponse.CheckErrors(neighbor.Errors); err != nil {
+		return n, err
+	}
+
+	return n, neighbor.Validate()
+}
+
+func (neighbor *BGPNeighbor) ReadJSONFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	} else if err = json.Unmarshal(buf.Bytes(), neighbor); err != nil {
+		return n, err
+	} else {
+		return n, neighbor.Validate()
+	}
+}
+
+// ReadJunosJSONFrom reads the BGP neighbors from the JSON Junos encodes them
+// as, captured with "| display json" or from its REST API.
+func (neighbor *BGPNeighbor) ReadJunosJSONFrom(r io.Reader) (n int64, err error) {
+	return jresponse.ReadJunosJSONFrom(r, neighbor)
+}
+
+var errNoRIB = errors.New("prefix counts outside of a table")
+
+// parseUints parses each of strs into the corresponding element of dst.
+func parseUints(strs []string, dst ...*uint) error {
+	for i, str := range strs {
+		v, err := strconv.ParseUint(str, 10, 0)
+		if err != nil {
+			return err
+		}
+		*dst[i] = uint(v)
+	}
+	return nil
+}
+
+// lastRIB returns the table whose prefix counts are being parsed.
+func (peer *BGPPeer) lastRIB() (*BGPRIB, error) {
+	if len(peer.BGPRIB) == 0 {
+		return nil, errNoRIB
+	}
+	return &peer.BGPRIB[len(peer.BGPRIB)-1], nil
+}
+
+// options returns the peer's options, creating them for the first option
+// line of the CLI.
+func (peer *BGPPeer) options() *BGPOptionInformation {
+	if peer.BGPOptionInformation == nil {
+		peer.BGPOptionInformation = new(BGPOptionInformation)
+	}
+	return peer.BGPOptionInformation
+}
+
+// peerLineParsers parse each line of a peer's CLI output, other than the
+// first, into the peer.
+var peerLineParsers = []struct {
+	re    *regexp.Regexp
+	parse func(peer *BGPPeer, m []string) error
+}{
+	{regexp.MustCompile(`^  Description: (.*)$`), func(peer *BGPPeer, m []string) error {
+		peer.Description = m[1]
+		return nil
+	}},
+	{regexp.MustCompile(`^  Group: (\S+)\s+Routing-Instance: (\S+)$`), func(peer *BGPPeer, m []string) error {
+		peer.PeerGroup, peer.PeerCfgRTI = m[1], m[2]
+		return nil
+	}},
+	{regexp.MustCompile(`^  Type: (\S+)\s+State: (\S+)\s+Flags: <(.*)>$`), func(peer *BGPPeer, m []string) error {
+		peer.PeerType, peer.PeerState, peer.PeerFlags = m[1], m[2], m[3]
+		return nil
+	}},
+	{regexp.MustCompile(`^  Last State: (\S+)\s+Last Event: (\S+)$`), func(peer *BGPPeer, m []string) error {
+		peer.LastState, peer.LastEvent = m[1], m[2]
+		return nil
+	}},
+	{regexp.MustCompile(`^  Last Error: (.*)$`), func(peer *BGPPeer, m []string) error {
+		peer.LastError = m[1]
+		return nil
+	}},
+	{regexp.MustCompile(`^  Export: \[ (.*) \] Import: \[ (.*) \]$`), func(peer *BGPPeer, m []string) error {
+		peer.options().ExportPolicy, peer.options().ImportPolicy = m[1], m[2]
+		return nil
+	}},
+	{regexp.MustCompile(`^  Options: <(.*)>$`), func(peer *BGPPeer, m []string) error {
+		peer.options().BGPOptions = m[1]
+		return nil
+	}},
+	{regexp.MustCompile(`^  (?:Local Address: (\S+) )?Holdtime: (\d+) Preference: (\d+)$`), func(peer *BGPPeer, m []string) error {
+		opts := peer.options()
+		opts.LocalAddress = m[1]
+		return parseUints(m[2:], &opts.Holdtime, &opts.Preference)
+	}},
+	{regexp.MustCompile(`^  Number of flaps: (\d+)$`), func(peer *BGPPeer, m []string) error {
+		return parseUints(m[1:], &peer.FlapCount)
+	}},
+	{regexp.MustCompile(`^  Last flap event: (\S+)$`), func(peer *BGPPeer, m []string) error {
+		peer.LastFlapEvent = m[1]
+		return nil
+	}},
+	{regexp.MustCompile(`^  Error: '(.*)' Sent: (\d+) Recv: (\d+)$`), func(peer *BGPPeer, m []string) error {
+		bgpErr := BGPError{Name: m[1]}
+		if err := parseUints(m[2:], &bgpErr.SendCount, &bgpErr.ReceiveCount); err != nil {
+			return err
+		}
+		peer.BGPError = append(peer.BGPError, bgpErr)
+		return nil
+	}},
+	{regexp.MustCompile(`^  Peer ID: (\S+)\s+Local ID: (\S+)\s+Active Holdtime: (\d+)$`), func(peer *BGPPeer, m []string) error {
+		peer.PeerID, peer.LocalID = m[1], m[2]
+		return parseUints(m[3:], &peer.ActiveHoldtime)
+	}},
+	{regexp.MustCompile(`^  Keepalive Interval: (\d+)\s+Group index: (\d+)\s+Peer index: (\d+)$`), func(peer *BGPPeer, m []string) error {
+		return parseUints(m[1:], &peer.KeepaliveInterval, &peer.GroupIndex, &peer.PeerIndex)
+	}},
+	{regexp.MustCompile(`^  Local Interface: (\S+)$`), func(peer *BGPPeer, m []string) error {
+		peer.LocalInterfaceName = m[1]
+		return nil
+	}},
+	{regexp.MustCompile(`^  NLRI advertised by peer: (.*)$`), func(peer *BGPPeer, m []string) error {
+		peer.NLRITypePeer = m[1]
+		return nil
+	}},
+	{regexp.MustCompile(`^  NLRI for this session: (.*)$`), func(peer *BGPPeer, m []string) error {
+		peer.NLRITypeSession = m[1]
+		return nil
+	}},
+	{regexp.MustCompile(`^  Table (\S+) Bit: (\S+)$`), func(peer *BGPPeer, m []string) error {
+		peer.BGPRIB = append(peer.BGPRIB, BGPRIB{Name: m[1], RIBBit: m[2]})
+		return nil
+	}},
+	{regexp.MustCompile(`^    RIB State: (.*)$`), func(peer *BGPPeer, m []string) error {
+		rib, err := peer.lastRIB()
+		if err == nil {
+			rib.BGPRIBState = m[1]
+		}
+		return err
+	}},
+	{regexp.MustCompile(`^    Send state: (.*)$`), func(peer *BGPPeer, m []string) error {
+		rib, err := peer.lastRIB()
+		if err == nil {
+			rib.SendState = m[1]
+		}
+		return err
+	}},
+	{regexp.MustCompile(`^    (Active|Received|Accepted|Advertised) prefixes:\s+(\d+)$` +
+		`|^    (Suppressed) due to damping:\s+(\d+)$`), func(peer *BGPPeer, m []string) error {
+		rib, err := peer.lastRIB()
+		if err != nil {
+			return err
+		}
+
+		kind, count := m[1]+m[3], m[2]+m[4]
+		v, err := strconv.Atoi(count)
+		switch kind {
+		case "Active":
+			rib.ActivePrefixCount = v
+		case "Received":
+			rib.ReceivedPrefixCount = v
+		case "Accepted":
+			rib.AcceptedPrefixCount = v
+		case "Suppressed":
+			rib.SuppressedPrefixCount = v
+		case "Advertised":
+			rib.AdvertisedPrefixCount = v
+		}
+		return err
+	}},
+	{regexp.MustCompile(`^  Last traffic \(seconds\): Received (\d+)\s+Sent (\d+)\s+Checked (\d+)$`), func(peer *BGPPeer, m []string) error {
+		return parseUints(m[1:], &peer.LastReceived, &peer.LastSent, &peer.LastChecked)
+	}},
+	{regexp.MustCompile(`^  Input messages:\s+Total (\d+)\s+Updates (\d+)\s+Refreshes (\d+)\s+Octets (\d+)$`), func(peer *BGPPeer, m []string) error {
+		return parseUints(m[1:], &peer.InputMessages, &peer.InputUpdates, &peer.InputRefreshes, &peer.InputOctets)
+	}},
+	{regexp.MustCompile(`^  Output messages:\s+Total (\d+)\s+Updates (\d+)\s+Refreshes (\d+)\s+Octets (\d+)$`), func(peer *BGPPeer, m []string) error {
+		return parseUints(m[1:], &peer.OutputMessages, &peer.OutputUpdates, &peer.OutputRefreshes, &peer.OutputOctets)
+	}},
+	{regexp.MustCompile(`^  Output Queue\[(\d+)\]: (\d+)(?: \((.*)\))?$`), func(peer *BGPPeer, m []string) error {
+		queue := BGPOutputQueue{TableName: m[3]}
+		if err := parseUints(m[1:3], &queue.Number, &queue.Count); err != nil {
+			return err
+		}
+		peer.BGPOutputQueue = append(peer.BGPOutputQueue, queue)
+		return nil
+	}},
+}
+
+var peerRegexp = regexp.MustCompile(`^Peer: (\S+)\s+AS (\d+)\s+Local: (\S+)\s+AS (\d+)$`)
+
+// ReadCLIFrom parses the text output of "show bgp neighbor", in the format
+// produced by WriteCLITo, into neighbor.
+func (neighbor *BGPNeighbor) ReadCLIFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	}
+
+	var peer *BGPPeer
+
+	scanner := bufio.NewScanner(&buf)
+
+lines:
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), " \r")
+
+		if line == "" {
+			continue
+		}
+
+		if m := peerRegexp.FindStringSubmatch(line); m != nil {
+			neighbor.BGPPeer = append(neighbor.BGPPeer, BGPPeer{PeerAddress: m[1], LocalAddress: m[3]})
+			peer = &neighbor.BGPPeer[len(neighbor.BGPPeer)-1]
+			if err := parseUints([]string{m[2], m[4]}, &peer.PeerAS, &peer.LocalAS); err != nil {
+				return n, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			continue
+		} else if peer == nil {
+			return n, fmt.Errorf("line %d: output before the first peer", lineNum)
+		}
+
+		for _, p := range peerLineParsers {
+			if m := p.re.FindStringSubmatch(line); m != nil {
+				if err := p.parse(peer, m); err != nil {
+					return n, fmt.Errorf("line %d: %v", lineNum, err)
+				}
+				continue lines
+			}
+		}
+
+		return n, fmt.Errorf("line %d: unrecognized bgp neighbor output %q", lineNum, line)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return n, err
+	}
+
+	if len(neighbor.BGPPeer) == 0 {
+		return n, errors.New("no bgp peers found")
+	}
+
+	return n, neighbor.Validate()
+}
@@ -0,0 +1,326 @@
+package bgpneighbor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/netip"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+var (
+	bgpNeighborXMLModel, bgpNeighborJSONModel *BGPNeighbor
+)
+
+const (
+	BGP_NEIGHBOR_XML_FILE        = "show_bgp_neighbor.xml"
+	BGP_NEIGHBOR_JSON_FILE       = "show_bgp_neighbor.json"
+	BGP_NEIGHBOR_CLI_FILE        = "show_bgp_neighbor.cli"
+	BGP_NEIGHBOR_JUNOS_JSON_FILE = "show_bgp_neighbor_junos_synthetic.json"
+)
+
+func initBGPNeighborModel() {
+	bgpNeighborXMLModel = &BGPNeighbor{
+		XMLName: xml.Name{Space: "http://xml.juniper.net/junos/12.3R6/junos-routing", Local: "bgp-information"},
+		BGPPeer: []BGPPeer{
+			{
+				PeerAddress:  "206.126.239.251+179",
+				PeerAS:       15169,
+				LocalAddress: "206.126.236.21+61532",
+				LocalAS:      7922,
+				Description:  "Google via Equinix Ashburn",
+				PeerGroup:    "IX-PEERS",
+				PeerCfgRTI:   "master",
+				PeerType:     "External",
+				PeerState:    "Established",
+				PeerFlags:    "Sync",
+				LastState:    "OpenConfirm",
+				LastEvent:    "RecvKeepAlive",
+				LastError:    "Hold Timer Expired Error",
+				BGPOptionInformation: &BGPOptionInformation{
+					ExportPolicy: "IX-OUT",
+					ImportPolicy: "IX-IN",
+					BGPOptions:   "Preference LocalAddress HoldTime LogUpDown PeerAS Refresh",
+					LocalAddress: "206.126.236.21",
+					Holdtime:     90,
+					Preference:   170,
+				},
+				FlapCount:     2,
+				LastFlapEvent: "HoldTime",
+				BGPError: []BGPError{
+					{Name: "Hold Timer Expired Error", SendCount: 2},
+					{Name: "Cease", ReceiveCount: 1},
+				},
+				PeerID:             "72.14.236.1",
+				LocalID:            "69.73.0.1",
+				ActiveHoldtime:     90,
+				KeepaliveInterval:  30,
+				GroupIndex:         4,
+				PeerIndex:          12,
+				LocalInterfaceName: "ae0.0",
+				NLRITypePeer:       "inet-unicast",
+				NLRITypeSession:    "inet-unicast",
+				BGPRIB: []BGPRIB{{
+					Name:                  "inet.0",
+					RIBBit:                "10000",
+					BGPRIBState:           "BGP restart is complete",
+					SendState:             "in sync",
+					ActivePrefixCount:     565520,
+					ReceivedPrefixCount:   565525,
+					AcceptedPrefixCount:   565520,
+					AdvertisedPrefixCount: 1204,
+				}},
+				LastReceived:   12,
+				LastSent:       5,
+				LastChecked:    20,
+				InputMessages:  1234567,
+				InputUpdates:   1180342,
+				InputOctets:    231405932,
+				OutputMessages: 98765,
+				OutputUpdates:  1432,
+				OutputOctets:   1976432,
+				BGPOutputQueue: []BGPOutputQueue{{TableName: "inet.0"}},
+			},
+			{
+				PeerAddress:  "2001:504:0:2:0:1:5169:1",
+				PeerAS:       15169,
+				LocalAddress: "2001:504:0:2:0:0:7922:1",
+				LocalAS:      7922,
+				PeerGroup:    "IX-PEERS-V6",
+				PeerCfgRTI:   "master",
+				PeerType:     "External",
+				PeerState:    "Active",
+				LastState:    "Idle",
+				LastEvent:    "Start",
+				LastError:    "Cease",
+				BGPOptionInformation: &BGPOptionInformation{
+					ExportPolicy: "IX-OUT-V6",
+					ImportPolicy: "IX-IN-V6",
+					BGPOptions:   "Preference HoldTime LogUpDown PeerAS Refresh",
+					Holdtime:     90,
+					Preference:   170,
+				},
+				FlapCount:      5,
+				LastFlapEvent:  "RecvNotify",
+				BGPError:       []BGPError{{Name: "Cease", ReceiveCount: 5}},
+				InputMessages:  45012,
+				InputUpdates:   40011,
+				InputOctets:    3401932,
+				OutputMessages: 40110,
+				OutputUpdates:  12,
+				OutputOctets:   764120,
+			},
+		},
+	}
+
+	model := *bgpNeighborXMLModel
+	model.XMLName = xml.Name{}
+	bgpNeighborJSONModel = &model
+}
+
+func TestMain(m *testing.M) {
+	initBGPNeighborModel()
+	os.Exit(m.Run())
+}
+
+func TestReadXMLFrom(t *testing.T) {
+
+	b := new(BGPNeighbor)
+
+	if file, err := os.Open(BGP_NEIGHBOR_XML_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := b.ReadXMLFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(b, bgpNeighborXMLModel) {
+		t.Log(bgpNeighborXMLModel)
+		t.Log(b)
+		t.Error("unmarshalled XML does not match BGP neighbor model")
+	}
+}
+
+func TestReadJSONFrom(t *testing.T) {
+
+	b := new(BGPNeighbor)
+
+	if file, err := os.Open(BGP_NEIGHBOR_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := b.ReadJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(b, bgpNeighborJSONModel) {
+		t.Log(bgpNeighborJSONModel)
+		t.Log(b)
+		t.Error("unmarshalled JSON does not match BGP neighbor model")
+	}
+}
+
+func TestReadCLIFrom(t *testing.T) {
+
+	b := new(BGPNeighbor)
+
+	// the CLI output carries no namespace, everything else should match
+	if file, err := os.Open(BGP_NEIGHBOR_CLI_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := b.ReadCLIFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(b, bgpNeighborJSONModel) {
+		t.Log(bgpNeighborJSONModel)
+		t.Log(b)
+		t.Error("parsed CLI does not match BGP neighbor model")
+	}
+}
+
+func TestReadCLIFromInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"  Type: External   State: Active       Flags: <>\n",
+		"Peer: 10.0.0.2+179 AS 65002 Local: 10.0.0.1+179 AS 65001\n" +
+			"    Active prefixes:              1\n",
+		"Peer: 10.0.0.2+179 AS 65002 Local: 10.0.0.1+179 AS 65001\n" +
+			"  Number of flaps: many\n",
+		"Peer: 10.0.0.300+179 AS 65002 Local: 10.0.0.1+179 AS 65001\n",
+	}
+
+	for i, input := range inputs {
+		if _, err := new(BGPNeighbor).ReadCLIFrom(bytes.NewBufferString(input)); err == nil {
+			t.Errorf("input %d: expected an error", i)
+		}
+	}
+}
+
+func TestWriteCLITo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+
+	if err := bgpNeighborXMLModel.WriteCLITo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(BGP_NEIGHBOR_CLI_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(modelBuf.String())
+		t.Error("CLI output does not match BGP neighbor model")
+	}
+}
+
+func TestWriteXMLTo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+	if _, err := bgpNeighborXMLModel.WriteXMLTo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(BGP_NEIGHBOR_XML_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	b := new(BGPNeighbor)
+	b.ReadXMLFrom(&fileBuf)
+
+	fileBuf.Reset()
+	b.WriteXMLTo(&fileBuf)
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(b)
+		t.Log(bgpNeighborXMLModel)
+		t.Error("XML bytes not equal")
+	}
+}
+
+func TestWriteJSONTo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+	if _, err := bgpNeighborJSONModel.WriteJSONTo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(BGP_NEIGHBOR_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	b := new(BGPNeighbor)
+	b.ReadJSONFrom(&fileBuf)
+	fileBuf.Reset()
+	b.WriteJSONTo(&fileBuf)
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(b)
+		t.Log(bgpNeighborJSONModel)
+		t.Error("JSON bytes not equal")
+	}
+
+	buf := bytes.Buffer{}
+	if _, err := bgpNeighborJSONModel.WriteJSONToWithOptions(&buf, jresponse.JSONOptions{MillisecondDurations: true}); err != nil {
+		t.Error(err)
+	} else if !bytes.Contains(buf.Bytes(), []byte(`"holdtime-ms":90000`)) ||
+		!bytes.Contains(buf.Bytes(), []byte(`"last-received-ms":12000`)) {
+		t.Errorf("timers not in milliseconds: %s", buf.String())
+	}
+}
+
+func TestPeerAccessors(t *testing.T) {
+	up, down := bgpNeighborXMLModel.BGPPeer[0], bgpNeighborXMLModel.BGPPeer[1]
+
+	if addr, err := up.PeerAddr(); err != nil || addr != netip.MustParseAddrPort("206.126.239.251:179") {
+		t.Errorf("PeerAddr() = %v, %v", addr, err)
+	} else if addr, err := up.LocalAddr(); err != nil || addr.Port() != 61532 {
+		t.Errorf("LocalAddr() = %v, %v", addr, err)
+	} else if addr, err := down.PeerAddr(); err != nil || !addr.Addr().Is6() || addr.Port() != 0 {
+		t.Errorf("PeerAddr() = %v, %v", addr, err)
+	}
+
+	if !up.Established() || down.Established() {
+		t.Error("Established() returned unexpected results")
+	}
+
+	if f := up.NegotiatedFamilies(); !reflect.DeepEqual(f, []string{"inet-unicast"}) {
+		t.Errorf("NegotiatedFamilies() = %v", f)
+	} else if f := down.NegotiatedFamilies(); len(f) != 0 {
+		t.Errorf("NegotiatedFamilies() = %v, should be empty for a session that is down", f)
+	}
+
+	if opts := up.BGPOptionInformation.Options(); len(opts) != 6 || opts[2] != "HoldTime" {
+		t.Errorf("Options() = %v", opts)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	b := BGPNeighbor{BGPPeer: []BGPPeer{{PeerAddress: "10.0.0.2+179", LocalAddress: "10.0.0.300+61532"}}}
+
+	var addrErr *jresponse.AddrError
+	if err := b.Validate(); !errors.As(err, &addrErr) {
+		t.Errorf("expected an AddrError, got %v", err)
+	} else if addrErr.Path != "bgp-information/bgp-peer[1]/local-address" {
+		t.Errorf("unexpected path %s", addrErr.Path)
+	}
+}
+
+func TestReadJunosJSONFrom(t *testing.T) {
+
+	b := new(BGPNeighbor)
+
+	if file, err := os.Open(BGP_NEIGHBOR_JUNOS_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := b.ReadJunosJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(b, bgpNeighborXMLModel) {
+		t.Log(bgpNeighborXMLModel)
+		t.Log(b)
+		t.Error("unmarshalled Junos JSON does not match the BGP neighbor xml model")
+	}
+}
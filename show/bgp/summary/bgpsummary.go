@@ -0,0 +1,369 @@
+package bgpsummary
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	tmpl "text/template"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	bgpSummaryTmpl *tmpl.Template
+)
+
+func init() {
+	var err error
+	if bgpSummaryTmpl, err = tmpl.New("bgpSummaryTmpl").Parse(bgpSummaryTmplStr); err != nil {
+		log.Fatalln(err)
+	}
+
+	jresponse.Register(xml.Name{Space: jresponse.JunosNamespace("junos-routing"), Local: "bgp-information"},
+		func() jresponse.ResponseReaderWriter { return new(BGPSummary) })
+}
+
+const (
+	ribHeader  = "Table          Tot Paths  Act Paths Suppressed    History Damp State    Pending"
+	peerHeader = "Peer                     AS      InPkt     OutPkt    OutQ   Flaps Last Up/Dwn " +
+		"State|#Active/Received/Accepted/Damped..."
+)
+
+const bgpSummaryTmplStr = "Groups: {{.GroupCount}} Peers: {{.PeerCount}} Down peers: {{.DownPeerCount}}\n" +
+
+	"{{if .BGPRIB}}" + ribHeader + "\n" +
+	"{{range .BGPRIB}}{{.Name}}\n" +
+	"{{printf \"%24d%11d%11d%11d%11d%11d\" .TotalPrefixCount .ActivePrefixCount .SuppressedPrefixCount " +
+	".HistoryPrefixCount .DampedPrefixCount .PendingPrefixCount}}\n" +
+	"{{end}}{{end}}" +
+
+	"{{if .BGPPeer}}" + peerHeader + "\n" +
+	"{{range .BGPPeer}}" +
+	"{{printf \"%-20s %6d %10d %10d %7d %7d %11s\" .PeerAddress .PeerAS .InputMessages .OutputMessages " +
+	".RouteQueueCount .FlapCount .ElapsedTime.ElapsedTime}} {{.PeerState.CLIState}}\n" +
+	"{{range .BGPRIB}}  {{.Name}}: {{.ActivePrefixCount}}/{{.ReceivedPrefixCount}}/" +
+	"{{.AcceptedPrefixCount}}/{{.SuppressedPrefixCount}}\n{{end}}" +
+	"{{end}}{{end}}"
+
+// BGPRIB holds the prefix counts of a routing table, either for all peers
+// or, within a BGPPeer, for a single peer. Only the active, received,
+// accepted and suppressed counts are reported per peer.
+type BGPRIB struct {
+	Name                          string `xml:"name"                                       json:"name"`
+	TotalPrefixCount              int    `xml:"total-prefix-count,omitempty"               json:"total-prefix-count,omitempty"`
+	ReceivedPrefixCount           int    `xml:"received-prefix-count"                      json:"received-prefix-count,omitempty"`
+	AcceptedPrefixCount           int    `xml:"accepted-prefix-count"                      json:"accepted-prefix-count,omitempty"`
+	ActivePrefixCount             int    `xml:"active-prefix-count"                        json:"active-prefix-count,omitempty"`
+	SuppressedPrefixCount         int    `xml:"suppressed-prefix-count"                    json:"suppressed-prefix-count,omitempty"`
+	HistoryPrefixCount            int    `xml:"history-prefix-count,omitempty"             json:"history-prefix-count,omitempty"`
+	DampedPrefixCount             int    `xml:"damped-prefix-count,omitempty"              json:"damped-prefix-count,omitempty"`
+	TotalExternalPrefixCount      int    `xml:"total-external-prefix-count,omitempty"      json:"total-external-prefix-count,omitempty"`
+	ActiveExternalPrefixCount     int    `xml:"active-external-prefix-count,omitempty"     json:"active-external-prefix-count,omitempty"`
+	AcceptedExternalPrefixCount   int    `xml:"accepted-external-prefix-count,omitempty"   json:"accepted-external-prefix-count,omitempty"`
+	SuppressedExternalPrefixCount int    `xml:"suppressed-external-prefix-count,omitempty" json:"suppressed-external-prefix-count,omitempty"`
+	TotalInternalPrefixCount      int    `xml:"total-internal-prefix-count,omitempty"      json:"total-internal-prefix-count,omitempty"`
+	ActiveInternalPrefixCount     int    `xml:"active-internal-prefix-count,omitempty"     json:"active-internal-prefix-count,omitempty"`
+	AcceptedInternalPrefixCount   int    `xml:"accepted-internal-prefix-count,omitempty"   json:"accepted-internal-prefix-count,omitempty"`
+	SuppressedInternalPrefixCount int    `xml:"suppressed-internal-prefix-count,omitempty" json:"suppressed-internal-prefix-count,omitempty"`
+	PendingPrefixCount            int    `xml:"pending-prefix-count,omitempty"             json:"pending-prefix-count,omitempty"`
+	BGPRIBState                   string `xml:"bgp-rib-state,omitempty"                    json:"bgp-rib-state,omitempty"`
+}
+
+// ElapsedTime is the time since the session last went up or down.
+type ElapsedTime struct {
+	ElapsedSecs jresponse.JunosAttr `xml:"seconds,attr" json:"elapsed-seconds,omitempty"`
+	ElapsedTime string              `xml:",chardata"    json:"elapsed-time,omitempty"`
+}
+
+// Duration returns the elapsed time, from the junos:seconds attribute when
+// present and otherwise from the elapsed time text.
+func (elapsed *ElapsedTime) Duration() (time.Duration, error) {
+	if elapsed.ElapsedSecs != "" {
+		secs, err := strconv.ParseInt(strings.TrimSpace(string(elapsed.ElapsedSecs)), 10, 64)
+		return time.Duration(secs) * time.Second, err
+	}
+	return jresponse.ParseJunosDuration(strings.TrimSpace(elapsed.ElapsedTime))
+}
+
+// PeerState is the state of the BGP session. Junos abbreviates the
+// Established state to the junos:format attribute in the CLI.
+type PeerState struct {
+	Format jresponse.JunosAttr `xml:"format,attr,omitempty" json:"format,omitempty"`
+	State  string              `xml:",chardata"            json:"state,omitempty"`
+}
+
+const established = "Established"
+
+// CLIState returns the state as printed by the CLI.
+func (state *PeerState) CLIState() string {
+	if state.Format != "" {
+		return string(state.Format)
+	}
+	return state.State
+}
+
+// Established reports whether the session is up.
+func (state *PeerState) Established() bool {
+	return state.State == established
+}
+
+type BGPPeer struct {
+	PeerAddress     string      `xml:"peer-address"              json:"peer-address"`
+	PeerAS          uint        `xml:"peer-as"                   json:"peer-as"`
+	Description     string      `xml:"description,omitempty"     json:"description,omitempty"`
+	InputMessages   uint        `xml:"input-messages"            json:"input-messages"`
+	OutputMessages  uint        `xml:"output-messages"           json:"output-messages"`
+	RouteQueueCount uint        `xml:"route-queue-count"         json:"route-queue-count"`
+	FlapCount       uint        `xml:"flap-count"                json:"flap-count"`
+	ElapsedTime     ElapsedTime `xml:"elapsed-time"              json:"elapsed-time"`
+	PeerState       PeerState   `xml:"peer-state"                json:"peer-state"`
+	BGPRIB          []BGPRIB    `xml:"bgp-rib,omitempty"         json:"bgp-rib,omitempty"`
+}
+
+// PeerAddr returns the peer's address.
+func (peer *BGPPeer) PeerAddr() (netip.Addr, error) {
+	return jresponse.ParseAddr(peer.PeerAddress)
+}
+
+type RPCError = jresponse.RPCError
+
+// Represents the BGP summary XML structure returned by
+// get-bgp-summary-information, and is used to convert it from XML to JSON.
+type BGPSummary struct {
+	XMLName       xml.Name        `xml:"bgp-information"            json:"-"`
+	JunosNS       jresponse.XMLNS `xml:"xmlns junos,attr,omitempty" json:"-"`
+	GroupCount    uint            `xml:"group-count"                json:"group-count"`
+	PeerCount     uint            `xml:"peer-count"                 json:"peer-count"`
+	DownPeerCount uint            `xml:"down-peer-count"            json:"down-peer-count"`
+	BGPRIB        []BGPRIB        `xml:"bgp-rib,omitempty"          json:"bgp-rib,omitempty"`
+	BGPPeer       []BGPPeer       `xml:"bgp-peer,omitempty"         json:"bgp-peer,omitempty"`
+	Errors        []RPCError      `xml:"rpc-error,omitempty"        json:"rpc-error,omitempty"`
+	OriginHost    string          `xml:"-"                          json:"originhost,omitempty"`
+	OriginIP      string          `xml:"-"                          json:"originip,omitempty"`
+}
+
+// Validate checks the peer addresses in the response, returning a
+// *jresponse.AddrError for each one that is malformed.
+func (summary *BGPSummary) Validate() error {
+	v := jresponse.AddrValidator{}
+	for i, peer := range summary.BGPPeer {
+		v.Addr(fmt.Sprintf("bgp-information/bgp-peer[%d]/peer-address", i+1), peer.PeerAddress)
+	}
+	return v.Err()
+}
+
+func (summary *BGPSummary) WriteXMLTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteXML(w, summary, summary.XMLName)
+}
+
+func (summary *BGPSummary) WriteJSONTo(w io.Writer) (n int64, err error) {
+	if s, err := json.Marshal(summary); err != nil {
+		return 0, err
+	} else {
+		return bytes.NewBuffer(s).WriteTo(w)
+	}
+}
+
+var bgpSummaryJSONTimeFields = jresponse.JSONTimeFields{
+	Durations: map[string]time.Duration{"elapsed-seconds": time.Second},
+}
+
+// WriteJSONToWithOptions writes the same JSON as WriteJSONTo, with session
+// uptimes encoded as selected by opts.
+func (summary *BGPSummary) WriteJSONToWithOptions(w io.Writer, opts jresponse.JSONOptions) (n int64, err error) {
+	return jresponse.WriteJSONWithOptions(w, summary, bgpSummaryJSONTimeFields, opts)
+}
+
+// WriteJunosJSONTo writes the BGP summary in the JSON Junos encodes it as
+// with "| display json".
+func (summary *BGPSummary) WriteJunosJSONTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteJunosJSON(w, summary)
+}
+
+func (summary *BGPSummary) WriteCLITo(w io.Writer) error {
+	return bgpSummaryTmpl.Execute(w, summary)
+}
+
+func (summary *BGPSummary) ReadXMLFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	}
+
+	if err := jresponse.UnmarshalReply(buf.Bytes(), summary, &summary.Errors); err != nil {
+		return n, err
+	}
+
+	if err := jresponse.CheckErrors(summary.Errors); err != nil {
+		return n, err
+	}
+
+	return n, summary.Validate()
+}
+
+func (summary *BGPSummary) ReadJSONFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	} else if err = json.Unmarshal(buf.Bytes(), summary); err != nil {
+		return n, err
+	} else {
+		return n, summary.Validate()
+	}
+}
+
+// ReadJunosJSONFrom reads the BGP summary from the JSON Junos encodes it
+// as, captured with "| display json" or from its REST API.
+func (summary *BGPSummary) ReadJunosJSONFrom(r io.Reader) (n int64, err error) {
+	return jresponse.ReadJunosJSONFrom(r, summary)
+}
+
+var (
+	countsRegexp   = regexp.MustCompile(`^Groups: (\d+) Peers: (\d+) Down peers: (\d+)$`)
+	ribTotalRegexp = regexp.MustCompile(`^\s+(\d+)\s+(\d+)\s+(\d+)\s+(\d+)\s+(\d+)\s+(\d+)$`)
+	peerRegexp     = regexp.MustCompile(`^(\S+)\s+(\d+)\s+(\d+)\s+(\d+)\s+(\d+)\s+(\d+)\s+` +
+		`((?:\d+w)?(?:\d+d)? ?[\d:]+)\s+(\S+)$`)
+	peerRIBRegexp = regexp.MustCompile(`^\s+(\S+): (\d+)/(\d+)/(\d+)/(\d+)$`)
+)
+
+// parseInts parses each of strs into the corresponding element of dst.
+func parseInts(strs []string, dst ...*int) error {
+	for i, str := range strs {
+		v, err := strconv.Atoi(str)
+		if err != nil {
+			return err
+		}
+		*dst[i] = v
+	}
+	return nil
+}
+
+// parseUints parses each of strs into the corresponding element of dst.
+func parseUints(strs []string, dst ...*uint) error {
+	for i, str := range strs {
+		v, err := strconv.ParseUint(str, 10, 0)
+		if err != nil {
+			return err
+		}
+		*dst[i] = uint(v)
+	}
+	return nil
+}
+
+// parsePeer parses a peer line of the summary, as matched by peerRegexp.
+func parsePeer(m []string) (*BGPPeer, error) {
+	peer := &BGPPeer{PeerAddress: m[1]}
+
+	if err := parseUints(m[2:7], &peer.PeerAS, &peer.InputMessages, &peer.OutputMessages,
+		&peer.RouteQueueCount, &peer.FlapCount); err != nil {
+		return nil, err
+	}
+
+	peer.ElapsedTime.ElapsedTime = m[7]
+	d, err := jresponse.ParseJunosDuration(m[7])
+	if err != nil {
+		return nil, err
+	}
+	peer.ElapsedTime.ElapsedSecs = jresponse.JunosAttr(strconv.FormatInt(int64(d/time.Second), 10))
+
+	if m[8] == "Establ" {
+		peer.PeerState = PeerState{Format: jresponse.JunosAttr(m[8]), State: established}
+	} else {
+		peer.PeerState = PeerState{State: m[8]}
+	}
+
+	return peer, nil
+}
+
+// ReadCLIFrom parses the text output of "show bgp summary", in the format
+// produced by WriteCLITo, into summary.
+func (summary *BGPSummary) ReadCLIFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	}
+
+	var (
+		countsSeen bool
+		rib        *BGPRIB
+		peer       *BGPPeer
+	)
+
+	scanner := bufio.NewScanner(&buf)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), " \r")
+
+		if m := countsRegexp.FindStringSubmatch(line); m != nil {
+			countsSeen = true
+			err = parseUints(m[1:], &summary.GroupCount, &summary.PeerCount, &summary.DownPeerCount)
+
+		} else if line == "" || line == ribHeader || strings.HasPrefix(line, "Peer  ") {
+			continue
+
+		} else if !countsSeen {
+			return n, fmt.Errorf("line %d: output before the group and peer counts", lineNum)
+
+		} else if m := ribTotalRegexp.FindStringSubmatch(line); m != nil {
+			if rib == nil {
+				return n, fmt.Errorf("line %d: table totals without a table name", lineNum)
+			}
+			err = parseInts(m[1:], &rib.TotalPrefixCount, &rib.ActivePrefixCount, &rib.SuppressedPrefixCount,
+				&rib.HistoryPrefixCount, &rib.DampedPrefixCount, &rib.PendingPrefixCount)
+			rib = nil
+
+		} else if m := peerRIBRegexp.FindStringSubmatch(line); m != nil {
+			if peer == nil {
+				return n, fmt.Errorf("line %d: peer table counts without a peer", lineNum)
+			}
+			peerRIB := BGPRIB{Name: m[1]}
+			err = parseInts(m[2:], &peerRIB.ActivePrefixCount, &peerRIB.ReceivedPrefixCount,
+				&peerRIB.AcceptedPrefixCount, &peerRIB.SuppressedPrefixCount)
+			peer.BGPRIB = append(peer.BGPRIB, peerRIB)
+
+		} else if m := peerRegexp.FindStringSubmatch(line); m != nil {
+			var p *BGPPeer
+			if p, err = parsePeer(m); err == nil {
+				summary.BGPPeer = append(summary.BGPPeer, *p)
+				peer = &summary.BGPPeer[len(summary.BGPPeer)-1]
+			}
+
+		} else if !strings.ContainsAny(line, " \t") && peer == nil {
+			// a table name, followed by its totals on the next line
+			summary.BGPRIB = append(summary.BGPRIB, BGPRIB{Name: line})
+			rib = &summary.BGPRIB[len(summary.BGPRIB)-1]
+
+		} else {
+			return n, fmt.Errorf("line %d: unrecognized bgp summary output %q", lineNum, line)
+		}
+
+		if err != nil {
+			return n, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return n, err
+	}
+
+	if !countsSeen {
+		return n, fmt.Errorf("no group and peer counts found")
+	}
+
+	return n, summary.Validate()
+}
@@ -0,0 +1,335 @@
+package bgpsummary
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+var (
+	bgpSummaryXMLModel, bgpSummaryJSONModel *BGPSummary
+)
+
+const (
+	BGP_SUMMARY_XML_FILE        = "show_bgp_summary.xml"
+	BGP_SUMMARY_JSON_FILE       = "show_bgp_summary.json"
+	BGP_SUMMARY_CLI_FILE        = "show_bgp_summary.cli"
+	BGP_SUMMARY_JUNOS_JSON_FILE = "show_bgp_summary_junos_synthetic.json"
+)
+
+func initBGPSummaryModel() {
+	bgpSummaryXMLModel = &BGPSummary{
+		XMLName:       xml.Name{Space: "http://xml.juniper.net/junos/12.3R6/junos-routing", Local: "bgp-information"},
+		GroupCount:    3,
+		PeerCount:     4,
+		DownPeerCount: 1,
+		BGPRIB: []BGPRIB{
+			{
+				Name:                        "inet.0",
+				TotalPrefixCount:            1131045,
+				ReceivedPrefixCount:         1131045,
+				AcceptedPrefixCount:         1131040,
+				ActivePrefixCount:           565520,
+				TotalExternalPrefixCount:    1131045,
+				ActiveExternalPrefixCount:   565520,
+				AcceptedExternalPrefixCount: 1131040,
+				BGPRIBState:                 "BGP restart is complete",
+			},
+			{
+				Name:                        "inet6.0",
+				TotalPrefixCount:            48213,
+				ReceivedPrefixCount:         48213,
+				AcceptedPrefixCount:         48213,
+				ActivePrefixCount:           48210,
+				DampedPrefixCount:           3,
+				TotalExternalPrefixCount:    48213,
+				ActiveExternalPrefixCount:   48210,
+				AcceptedExternalPrefixCount: 48213,
+				BGPRIBState:                 "BGP restart is complete",
+			},
+		},
+		BGPPeer: []BGPPeer{
+			{
+				PeerAddress:    "206.126.239.251",
+				PeerAS:         15169,
+				InputMessages:  1234567,
+				OutputMessages: 98765,
+				FlapCount:      2,
+				ElapsedTime:    ElapsedTime{ElapsedSecs: "585128", ElapsedTime: "6d 18:32:08"},
+				PeerState:      PeerState{Format: "Establ", State: "Established"},
+				BGPRIB: []BGPRIB{{
+					Name:                "inet.0",
+					ActivePrefixCount:   565520,
+					ReceivedPrefixCount: 565525,
+					AcceptedPrefixCount: 565520,
+				}},
+			},
+			{
+				PeerAddress:    "206.126.239.252",
+				PeerAS:         15169,
+				InputMessages:  1198456,
+				OutputMessages: 98760,
+				FlapCount:      1,
+				ElapsedTime:    ElapsedTime{ElapsedSecs: "762247", ElapsedTime: "1w1d 19:44:07"},
+				PeerState:      PeerState{Format: "Establ", State: "Established"},
+				BGPRIB: []BGPRIB{{
+					Name:                "inet.0",
+					ReceivedPrefixCount: 565520,
+					AcceptedPrefixCount: 565520,
+				}},
+			},
+			{
+				PeerAddress:    "2001:504:0:2:0:1:5169:1",
+				PeerAS:         15169,
+				InputMessages:  45012,
+				OutputMessages: 40110,
+				ElapsedTime:    ElapsedTime{ElapsedSecs: "3723", ElapsedTime: "1:02:03"},
+				PeerState:      PeerState{Format: "Establ", State: "Established"},
+				BGPRIB: []BGPRIB{{
+					Name:                "inet6.0",
+					ActivePrefixCount:   48210,
+					ReceivedPrefixCount: 48213,
+					AcceptedPrefixCount: 48213,
+				}},
+			},
+			{
+				PeerAddress: "76.73.165.1",
+				PeerAS:      7922,
+				FlapCount:   5,
+				ElapsedTime: ElapsedTime{ElapsedSecs: "2652", ElapsedTime: "44:12"},
+				PeerState:   PeerState{State: "Active"},
+			},
+		},
+	}
+
+	model := *bgpSummaryXMLModel
+	model.XMLName = xml.Name{}
+	bgpSummaryJSONModel = &model
+}
+
+func TestMain(m *testing.M) {
+	initBGPSummaryModel()
+	os.Exit(m.Run())
+}
+
+func TestReadXMLFrom(t *testing.T) {
+
+	s := new(BGPSummary)
+
+	if file, err := os.Open(BGP_SUMMARY_XML_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := s.ReadXMLFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(s, bgpSummaryXMLModel) {
+		t.Log(bgpSummaryXMLModel)
+		t.Log(s)
+		t.Error("unmarshalled XML does not match BGP summary model")
+	}
+}
+
+func TestReadJSONFrom(t *testing.T) {
+
+	s := new(BGPSummary)
+
+	if file, err := os.Open(BGP_SUMMARY_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := s.ReadJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(s, bgpSummaryJSONModel) {
+		t.Log(bgpSummaryJSONModel)
+		t.Log(s)
+		t.Error("unmarshalled JSON does not match BGP summary model")
+	}
+}
+
+func TestReadCLIFrom(t *testing.T) {
+
+	// the CLI only prints some of the table counts
+	cliModel := *bgpSummaryJSONModel
+	cliModel.BGPRIB = nil
+	for _, rib := range bgpSummaryJSONModel.BGPRIB {
+		cliModel.BGPRIB = append(cliModel.BGPRIB, BGPRIB{
+			Name:                  rib.Name,
+			TotalPrefixCount:      rib.TotalPrefixCount,
+			ActivePrefixCount:     rib.ActivePrefixCount,
+			SuppressedPrefixCount: rib.SuppressedPrefixCount,
+			HistoryPrefixCount:    rib.HistoryPrefixCount,
+			DampedPrefixCount:     rib.DampedPrefixCount,
+			PendingPrefixCount:    rib.PendingPrefixCount,
+		})
+	}
+
+	s := new(BGPSummary)
+
+	if file, err := os.Open(BGP_SUMMARY_CLI_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := s.ReadCLIFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(s, &cliModel) {
+		t.Log(&cliModel)
+		t.Log(s)
+		t.Error("parsed CLI does not match BGP summary model")
+	}
+}
+
+func TestReadCLIFromInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"inet.0\n",
+		"Groups: 1 Peers: 1 Down peers: 0\n" +
+			"                       1          1          0          0          0          0\n",
+		"Groups: 1 Peers: 1 Down peers: 0\n" +
+			"  inet.0: 1/1/1/0\n",
+		"Groups: 1 Peers: 1 Down peers: 0\n" +
+			"10.0.0.1               65000         10         10       0       0    yesterday Establ\n",
+		"Groups: 1 Peers: 1 Down peers: 0\n" +
+			"10.0.0.300             65000         10         10       0       0       44:12 Establ\n",
+	}
+
+	for i, input := range inputs {
+		if _, err := new(BGPSummary).ReadCLIFrom(bytes.NewBufferString(input)); err == nil {
+			t.Errorf("input %d: expected an error", i)
+		}
+	}
+}
+
+func TestWriteCLITo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+
+	if err := bgpSummaryXMLModel.WriteCLITo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(BGP_SUMMARY_CLI_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(modelBuf.String())
+		t.Error("CLI output does not match BGP summary model")
+	}
+}
+
+func TestWriteXMLTo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+	if _, err := bgpSummaryXMLModel.WriteXMLTo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(BGP_SUMMARY_XML_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	s := new(BGPSummary)
+	s.ReadXMLFrom(&fileBuf)
+
+	fileBuf.Reset()
+	s.WriteXMLTo(&fileBuf)
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(s)
+		t.Log(bgpSummaryXMLModel)
+		t.Error("XML bytes not equal")
+	}
+}
+
+func TestWriteJSONTo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+	if _, err := bgpSummaryJSONModel.WriteJSONTo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(BGP_SUMMARY_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	s := new(BGPSummary)
+	s.ReadJSONFrom(&fileBuf)
+	fileBuf.Reset()
+	s.WriteJSONTo(&fileBuf)
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(s)
+		t.Log(bgpSummaryJSONModel)
+		t.Error("JSON bytes not equal")
+	}
+}
+
+func TestElapsedDuration(t *testing.T) {
+	for _, peer := range bgpSummaryXMLModel.BGPPeer {
+		fromSecs, err := peer.ElapsedTime.Duration()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		fromText, err := (&ElapsedTime{ElapsedTime: peer.ElapsedTime.ElapsedTime}).Duration()
+		if err != nil {
+			t.Error(err)
+		} else if fromText != fromSecs {
+			t.Errorf("%s: Duration() = %v from the text, should be %v", peer.PeerAddress, fromText, fromSecs)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	if _, err := bgpSummaryJSONModel.WriteJSONToWithOptions(&buf, jresponse.JSONOptions{MillisecondDurations: true}); err != nil {
+		t.Error(err)
+	} else if !bytes.Contains(buf.Bytes(), []byte(`"elapsed-ms":3723000`)) {
+		t.Errorf("elapsed time not in milliseconds: %s", buf.String())
+	}
+
+	if d, err := (&ElapsedTime{ElapsedTime: "44:12"}).Duration(); err != nil || d != 44*time.Minute+12*time.Second {
+		t.Errorf("Duration() = %v, %v", d, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if !bgpSummaryXMLModel.BGPPeer[0].PeerState.Established() || bgpSummaryXMLModel.BGPPeer[3].PeerState.Established() {
+		t.Error("Established() returned unexpected results")
+	}
+
+	if addr, err := bgpSummaryXMLModel.BGPPeer[2].PeerAddr(); err != nil || !addr.Is6() {
+		t.Errorf("PeerAddr() = %v, %v", addr, err)
+	}
+
+	s := BGPSummary{BGPPeer: []BGPPeer{{PeerAddress: "10.0.0.1"}, {PeerAddress: "10.0.0.300"}}}
+	var addrErr *jresponse.AddrError
+	if err := s.Validate(); !errors.As(err, &addrErr) {
+		t.Errorf("expected an AddrError, got %v", err)
+	} else if addrErr.Path != "bgp-information/bgp-peer[2]/peer-address" {
+		t.Errorf("unexpected path %s", addrErr.Path)
+	}
+}
+
+func TestReadJunosJSONFrom(t *testing.T) {
+
+	s := new(BGPSummary)
+
+	if file, err := os.Open(BGP_SUMMARY_JUNOS_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := s.ReadJunosJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(s, bgpSummaryXMLModel) {
+		t.Log(bgpSummaryXMLModel)
+		t.Log(s)
+		t.Error("unmarshalled Junos JSON does not match the BGP summary xml model")
+	}
+}
@@ -0,0 +1,994 @@
+package interfaces
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	tmpl "text/template"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	interfacesTmpl *tmpl.Template
+)
+
+func init() {
+	fmtFuncMap := tmpl.FuncMap{
+		"terseLines":   terseLines,
+		"adminStatus":  adminStatus,
+		"operStatus":   operStatus,
+		"traffic":      formatTraffic,
+		"counters":     formatCounters,
+		"inputErrors":  inputErrorCounters,
+		"outputErrors": outputErrorCounters,
+		"macRows":      formatMACRows,
+		"pcsRows":      formatPCSRows,
+		"briefFamily":  formatBriefFamily,
+	}
+
+	var err error
+	if interfacesTmpl, err = tmpl.New("interfacesTmpl").Funcs(fmtFuncMap).Parse(interfacesTmplStr); err != nil {
+		log.Fatalln(err)
+	}
+
+	jresponse.Register(xml.Name{Space: jresponse.JunosNamespace("junos-interface"), Local: "interface-information"},
+		func() jresponse.ResponseReaderWriter { return new(Interfaces) })
+}
+
+// The CLI styles, named as in the junos:style attribute of the reply.
+const (
+	StyleTerse     = "terse"
+	StyleBrief     = "brief"
+	StyleExtensive = "extensive"
+)
+
+const terseHeader = "Interface               Admin Link Proto    Local                 Remote"
+
+// interfacesTmplStr defines a template for each CLI style; WriteCLITo
+// executes the one matching the reply.
+const interfacesTmplStr = "" +
+	"{{define \"terse\"}}" + terseHeader + "\n" +
+	"{{range .PhysicalInterface}}{{range terseLines .}}{{.}}\n{{end}}{{end}}" +
+	"{{end}}" +
+
+	"{{define \"physical\"}}" +
+	"Physical interface: {{.Name}}, {{adminStatus .AdminStatus}}, Physical link is {{operStatus .OperStatus}}\n" +
+	"{{end}}" +
+
+	"{{define \"link\"}}" +
+	"{{with .Description}}  Description: {{.}}\n{{end}}" +
+	"  {{with .IfType}}Type: {{.}}, {{end}}Link-level type: {{.LinkLevelType}}, MTU: {{.MTU}}" +
+	"{{with .Speed}}, Speed: {{.}}{{end}}\n" +
+	"{{if .CurrentPhysicalAddress}}" +
+	"  Current address: {{.CurrentPhysicalAddress}}, Hardware address: {{.HardwarePhysicalAddress}}\n" +
+	"{{end}}" +
+	"{{end}}" +
+
+	"{{define \"brief\"}}" +
+	"{{range $i, $phy := .PhysicalInterface}}{{if $i}}\n{{end}}" +
+	"{{template \"physical\" .}}{{template \"link\" .}}" +
+	"{{range .LogicalInterface}}\n  Logical interface {{.Name}}\n" +
+	"{{with .Description}}    Description: {{.}}\n{{end}}" +
+	"{{with .Encapsulation}}    Encapsulation: {{.}}\n{{end}}" +
+	"{{range .AddressFamily}}{{briefFamily .}}{{end}}" +
+	"{{end}}{{end}}" +
+	"{{end}}" +
+
+	"{{define \"extensive\"}}" +
+	"{{range $i, $phy := .PhysicalInterface}}{{if $i}}\n{{end}}" +
+	"{{template \"physical\" .}}" +
+	"  Interface index: {{.LocalIndex}}, SNMP ifIndex: {{.SNMPIndex}}\n" +
+	"{{template \"link\" .}}" +
+	"{{with .InterfaceFlapped}}  Last flapped   : {{.Value}}\n{{end}}" +
+	"{{with .TrafficStatistics}}  Traffic statistics:\n{{traffic \"   \" .}}{{end}}" +
+	"{{with .InputErrorList}}  Input errors:\n    {{counters (inputErrors .)}}\n{{end}}" +
+	"{{with .OutputErrorList}}  Output errors:\n    {{counters (outputErrors .)}}\n{{end}}" +
+	"{{with .EthernetMACStatistics}}  " + macHeader + "\n{{macRows .}}{{end}}" +
+	"{{with .EthernetPCSStatistics}}  " + pcsHeader + "\n{{pcsRows .}}{{end}}" +
+
+	"{{range .LogicalInterface}}\n" +
+	"  Logical interface {{.Name}} (Index {{.LocalIndex}}) (SNMP ifIndex {{.SNMPIndex}})\n" +
+	"{{with .Description}}    Description: {{.}}\n{{end}}" +
+	"{{with .Encapsulation}}    Encapsulation: {{.}}\n{{end}}" +
+	"{{with .TrafficStatistics}}    Traffic statistics:\n{{traffic \"     \" .}}{{end}}" +
+	"{{range .AddressFamily}}    Protocol {{.AddressFamilyName}}{{with .MTU}}, MTU: {{.}}{{end}}\n" +
+	"{{range .InterfaceAddress}}      Addresses\n        " +
+	"{{with .IfaDestination}}Destination: {{.}}, {{end}}Local: {{.IfaLocal}}" +
+	"{{with .IfaBroadcast}}, Broadcast: {{.}}{{end}}\n" +
+	"{{end}}{{end}}" +
+	"{{end}}{{end}}" +
+	"{{end}}"
+
+// InterfaceFlapped is the time of the last change of the link state.
+type InterfaceFlapped struct {
+	Seconds jresponse.JunosAttr `xml:"seconds,attr" json:"flapped-seconds,omitempty"`
+	Value   string              `xml:",chardata"    json:"flapped,omitempty"`
+}
+
+// Duration returns the time since the link last changed state, from the
+// junos:seconds attribute.
+func (flapped *InterfaceFlapped) Duration() (time.Duration, error) {
+	secs, err := strconv.ParseInt(strings.TrimSpace(string(flapped.Seconds)), 10, 64)
+	return time.Duration(secs) * time.Second, err
+}
+
+type TrafficStatistics struct {
+	InputBytes    uint64 `xml:"input-bytes"    json:"input-bytes"`
+	InputBPS      uint64 `xml:"input-bps"      json:"input-bps"`
+	OutputBytes   uint64 `xml:"output-bytes"   json:"output-bytes"`
+	OutputBPS     uint64 `xml:"output-bps"     json:"output-bps"`
+	InputPackets  uint64 `xml:"input-packets"  json:"input-packets"`
+	InputPPS      uint64 `xml:"input-pps"      json:"input-pps"`
+	OutputPackets uint64 `xml:"output-packets" json:"output-packets"`
+	OutputPPS     uint64 `xml:"output-pps"     json:"output-pps"`
+}
+
+type InputErrorList struct {
+	InputErrors             uint64 `xml:"input-errors"               json:"input-errors"`
+	InputDrops              uint64 `xml:"input-drops"                json:"input-drops"`
+	FramingErrors           uint64 `xml:"framing-errors"             json:"framing-errors"`
+	InputRunts              uint64 `xml:"input-runts"                json:"input-runts"`
+	InputDiscards           uint64 `xml:"input-discards"             json:"input-discards"`
+	InputL3Incompletes      uint64 `xml:"input-l3-incompletes"       json:"input-l3-incompletes"`
+	InputL2ChannelErrors    uint64 `xml:"input-l2-channel-errors"    json:"input-l2-channel-errors"`
+	InputL2MismatchTimeouts uint64 `xml:"input-l2-mismatch-timeouts" json:"input-l2-mismatch-timeouts"`
+	InputFIFOErrors         uint64 `xml:"input-fifo-errors"          json:"input-fifo-errors"`
+	InputResourceErrors     uint64 `xml:"input-resource-errors"      json:"input-resource-errors"`
+}
+
+type OutputErrorList struct {
+	CarrierTransitions   uint64 `xml:"carrier-transitions"    json:"carrier-transitions"`
+	OutputErrors         uint64 `xml:"output-errors"          json:"output-errors"`
+	OutputDrops          uint64 `xml:"output-drops"           json:"output-drops"`
+	OutputCollisions     uint64 `xml:"output-collisions"      json:"output-collisions"`
+	AgedPackets          uint64 `xml:"aged-packets"           json:"aged-packets"`
+	OutputFIFOErrors     uint64 `xml:"output-fifo-errors"     json:"output-fifo-errors"`
+	HSLinkCRCErrors      uint64 `xml:"hs-link-crc-errors"     json:"hs-link-crc-errors"`
+	MTUErrors            uint64 `xml:"mtu-errors"             json:"mtu-errors"`
+	OutputResourceErrors uint64 `xml:"output-resource-errors" json:"output-resource-errors"`
+}
+
+type EthernetMACStatistics struct {
+	InputBytes             uint64 `xml:"input-bytes"               json:"input-bytes"`
+	OutputBytes            uint64 `xml:"output-bytes"              json:"output-bytes"`
+	InputPackets           uint64 `xml:"input-packets"             json:"input-packets"`
+	OutputPackets          uint64 `xml:"output-packets"            json:"output-packets"`
+	InputUnicasts          uint64 `xml:"input-unicasts"            json:"input-unicasts"`
+	OutputUnicasts         uint64 `xml:"output-unicasts"           json:"output-unicasts"`
+	InputBroadcasts        uint64 `xml:"input-broadcasts"          json:"input-broadcasts"`
+	OutputBroadcasts       uint64 `xml:"output-broadcasts"         json:"output-broadcasts"`
+	InputMulticasts        uint64 `xml:"input-multicasts"          json:"input-multicasts"`
+	OutputMulticasts       uint64 `xml:"output-multicasts"         json:"output-multicasts"`
+	InputCRCErrors         uint64 `xml:"input-crc-errors"          json:"input-crc-errors"`
+	OutputCRCErrors        uint64 `xml:"output-crc-errors"         json:"output-crc-errors"`
+	InputFIFOErrors        uint64 `xml:"input-fifo-errors"         json:"input-fifo-errors"`
+	OutputFIFOErrors       uint64 `xml:"output-fifo-errors"        json:"output-fifo-errors"`
+	InputMACControlFrames  uint64 `xml:"input-mac-control-frames"  json:"input-mac-control-frames"`
+	OutputMACControlFrames uint64 `xml:"output-mac-control-frames" json:"output-mac-control-frames"`
+	InputMACPauseFrames    uint64 `xml:"input-mac-pause-frames"    json:"input-mac-pause-frames"`
+	OutputMACPauseFrames   uint64 `xml:"output-mac-pause-frames"   json:"output-mac-pause-frames"`
+	InputOversizedFrames   uint64 `xml:"input-oversized-frames"    json:"input-oversized-frames"`
+	InputJabberFrames      uint64 `xml:"input-jabber-frames"       json:"input-jabber-frames"`
+	InputFragmentFrames    uint64 `xml:"input-fragment-frames"     json:"input-fragment-frames"`
+	InputVLANTaggedFrames  uint64 `xml:"input-vlan-tagged-frames"  json:"input-vlan-tagged-frames"`
+	InputCodeViolations    uint64 `xml:"input-code-violations"     json:"input-code-violations"`
+}
+
+type EthernetPCSStatistics struct {
+	BitErrorSeconds      uint64 `xml:"bit-error-seconds"      json:"bit-error-seconds"`
+	ErroredBlocksSeconds uint64 `xml:"errored-blocks-seconds" json:"errored-blocks-seconds"`
+}
+
+type InterfaceAddress struct {
+	IfaDestination string `xml:"ifa-destination,omitempty" json:"ifa-destination,omitempty"`
+	IfaLocal       string `xml:"ifa-local"                 json:"ifa-local"`
+	IfaBroadcast   string `xml:"ifa-broadcast,omitempty"   json:"ifa-broadcast,omitempty"`
+}
+
+// Prefix returns the address with the length of its subnet, keeping the
+// host bits. The terse and brief styles report the local address as a
+// prefix; the others report the subnet as the destination.
+func (ifa *InterfaceAddress) Prefix() (netip.Prefix, error) {
+	if strings.Contains(ifa.IfaLocal, "/") {
+		return jresponse.ParsePrefix(ifa.IfaLocal)
+	}
+
+	addr, err := jresponse.ParseAddr(ifa.IfaLocal)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	bits := addr.BitLen()
+	if ifa.IfaDestination != "" {
+		dest, err := jresponse.ParsePrefix(ifa.IfaDestination)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		bits = dest.Bits()
+	}
+	return netip.PrefixFrom(addr, bits), nil
+}
+
+type AddressFamily struct {
+	AddressFamilyName string             `xml:"address-family-name"         json:"address-family-name"`
+	MTU               string             `xml:"mtu,omitempty"               json:"mtu,omitempty"`
+	InterfaceAddress  []InterfaceAddress `xml:"interface-address,omitempty" json:"interface-address,omitempty"`
+}
+
+type LogicalInterface struct {
+	Name              string             `xml:"name"                         json:"name"`
+	AdminStatus       string             `xml:"admin-status,omitempty"       json:"admin-status,omitempty"`
+	OperStatus        string             `xml:"oper-status,omitempty"        json:"oper-status,omitempty"`
+	LocalIndex        uint               `xml:"local-index,omitempty"        json:"local-index,omitempty"`
+	SNMPIndex         uint               `xml:"snmp-index,omitempty"         json:"snmp-index,omitempty"`
+	Description       string             `xml:"description,omitempty"        json:"description,omitempty"`
+	Encapsulation     string             `xml:"encapsulation,omitempty"      json:"encapsulation,omitempty"`
+	TrafficStatistics *TrafficStatistics `xml:"traffic-statistics,omitempty" json:"traffic-statistics,omitempty"`
+	AddressFamily     []AddressFamily    `xml:"address-family,omitempty"     json:"address-family,omitempty"`
+}
+
+type PhysicalInterface struct {
+	Name                    string                 `xml:"name"                              json:"name"`
+	AdminStatus             string                 `xml:"admin-status"                      json:"admin-status"`
+	OperStatus              string                 `xml:"oper-status"                       json:"oper-status"`
+	LocalIndex              uint                   `xml:"local-index,omitempty"             json:"local-index,omitempty"`
+	SNMPIndex               uint                   `xml:"snmp-index,omitempty"              json:"snmp-index,omitempty"`
+	Description             string                 `xml:"description,omitempty"             json:"description,omitempty"`
+	IfType                  string                 `xml:"if-type,omitempty"                 json:"if-type,omitempty"`
+	LinkLevelType           string                 `xml:"link-level-type,omitempty"         json:"link-level-type,omitempty"`
+	MTU                     string                 `xml:"mtu,omitempty"                     json:"mtu,omitempty"`
+	Speed                   string                 `xml:"speed,omitempty"                   json:"speed,omitempty"`
+	CurrentPhysicalAddress  string                 `xml:"current-physical-address,omitempty"  json:"current-physical-address,omitempty"`
+	HardwarePhysicalAddress string                 `xml:"hardware-physical-address,omitempty" json:"hardware-physical-address,omitempty"`
+	InterfaceFlapped        *InterfaceFlapped      `xml:"interface-flapped,omitempty"       json:"interface-flapped,omitempty"`
+	TrafficStatistics       *TrafficStatistics     `xml:"traffic-statistics,omitempty"      json:"traffic-statistics,omitempty"`
+	InputErrorList          *InputErrorList        `xml:"input-error-list,omitempty"        json:"input-error-list,omitempty"`
+	OutputErrorList         *OutputErrorList       `xml:"output-error-list,omitempty"       json:"output-error-list,omitempty"`
+	EthernetMACStatistics   *EthernetMACStatistics `xml:"ethernet-mac-statistics,omitempty" json:"ethernet-mac-statistics,omitempty"`
+	EthernetPCSStatistics   *EthernetPCSStatistics `xml:"ethernet-pcs-statistics,omitempty" json:"ethernet-pcs-statistics,omitempty"`
+	LogicalInterface        []LogicalInterface     `xml:"logical-interface,omitempty"       json:"logical-interface,omitempty"`
+}
+
+// Up reports whether the interface is enabled and its link is up.
+func (phy *PhysicalInterface) Up() bool {
+	return phy.AdminStatus == "up" && phy.OperStatus == "up"
+}
+
+// HardwareAddr returns the current MAC address of the interface.
+func (phy *PhysicalInterface) HardwareAddr() (net.HardwareAddr, error) {
+	return net.ParseMAC(phy.CurrentPhysicalAddress)
+}
+
+var speedRegexp = regexp.MustCompile(`^(\d+)([kmgt]?)bps$`)
+
+// SpeedBps returns the speed of the interface in bits per second, e.g.
+// 10000000000 for "10Gbps".
+func (phy *PhysicalInterface) SpeedBps() (uint64, error) {
+	m := speedRegexp.FindStringSubmatch(strings.ToLower(strings.TrimSpace(phy.Speed)))
+	if m == nil {
+		return 0, fmt.Errorf("invalid interface speed %q", phy.Speed)
+	}
+
+	speed, err := strconv.ParseUint(m[1], 10, 64)
+	for _, unit := range "kmgt" {
+		if m[2] == "" {
+			break
+		}
+		speed *= 1000
+		if m[2] == string(unit) {
+			break
+		}
+	}
+	return speed, err
+}
+
+type RPCError = jresponse.RPCError
+
+// Represents the interface XML structure returned by
+// get-interface-information, and is used to convert it from XML to JSON.
+type Interfaces struct {
+	XMLName           xml.Name            `xml:"interface-information"      json:"-"`
+	JunosNS           jresponse.XMLNS     `xml:"xmlns junos,attr,omitempty" json:"-"`
+	Style             jresponse.JunosAttr `xml:"style,attr,omitempty"       json:"style,omitempty"`
+	PhysicalInterface []PhysicalInterface `xml:"physical-interface"         json:"physical-interface"`
+	Errors            []RPCError          `xml:"rpc-error,omitempty"        json:"rpc-error,omitempty"`
+	OriginHost        string              `xml:"-"                          json:"originhost,omitempty"`
+	OriginIP          string              `xml:"-"                          json:"originip,omitempty"`
+}
+
+// CLIStyle returns the style WriteCLITo renders: the junos:style of the
+// reply, or else the most detailed style the reply has data for.
+func (ifs *Interfaces) CLIStyle() string {
+	switch ifs.Style {
+	case StyleTerse, StyleBrief, StyleExtensive:
+		return string(ifs.Style)
+	case "detail":
+		return StyleExtensive
+	case "normal":
+		return StyleBrief
+	}
+
+	style := StyleTerse
+	for _, phy := range ifs.PhysicalInterface {
+		if phy.TrafficStatistics != nil || phy.InputErrorList != nil || phy.EthernetMACStatistics != nil {
+			return StyleExtensive
+		} else if phy.LinkLevelType != "" || phy.MTU != "" {
+			style = StyleBrief
+		}
+	}
+	return style
+}
+
+// Validate checks the local and broadcast inet and inet6 addresses in the
+// response, returning a *jresponse.AddrError for each one that is
+// malformed. Destinations aren't checked, as Junos reports some as "0/0" or
+// "Unspecified".
+func (ifs *Interfaces) Validate() error {
+	v := jresponse.AddrValidator{}
+	for i, phy := range ifs.PhysicalInterface {
+		for j, ifl := range phy.LogicalInterface {
+			for k, family := range ifl.AddressFamily {
+				if family.AddressFamilyName != string(jresponse.FamilyInet) &&
+					family.AddressFamilyName != string(jresponse.FamilyInet6) {
+					continue
+				}
+
+				for l, ifa := range family.InterfaceAddress {
+					path := fmt.Sprintf("interface-information/physical-interface[%d]/logical-interface[%d]/"+
+						"address-family[%d]/interface-address[%d]/", i+1, j+1, k+1, l+1)
+					if strings.Contains(ifa.IfaLocal, "/") {
+						v.Prefix(path+"ifa-local", ifa.IfaLocal)
+					} else {
+						v.Addr(path+"ifa-local", ifa.IfaLocal)
+					}
+					v.Addr(path+"ifa-broadcast", ifa.IfaBroadcast)
+				}
+			}
+		}
+	}
+	return v.Err()
+}
+
+func (ifs *Interfaces) WriteXMLTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteXML(w, ifs, ifs.XMLName)
+}
+
+func (ifs *Interfaces) WriteJSONTo(w io.Writer) (n int64, err error) {
+	if s, err := json.Marshal(ifs); err != nil {
+		return 0, err
+	} else {
+		return bytes.NewBuffer(s).WriteTo(w)
+	}
+}
+
+var interfacesJSONTimeFields = jresponse.JSONTimeFields{
+	Durations: map[string]time.Duration{"flapped-seconds": time.Second},
+}
+
+// WriteJSONToWithOptions writes the same JSON as WriteJSONTo, with the time
+// since the last flap encoded as selected by opts.
+func (ifs *Interfaces) WriteJSONToWithOptions(w io.Writer, opts jresponse.JSONOptions) (n int64, err error) {
+	return jresponse.WriteJSONWithOptions(w, ifs, interfacesJSONTimeFields, opts)
+}
+
+// WriteJunosJSONTo writes the interfaces in the JSON Junos encodes them as
+// with "| display json".
+func (ifs *Interfaces) WriteJunosJSONTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteJunosJSON(w, ifs)
+}
+
+func (ifs *Interfaces) WriteCLITo(w io.Writer) error {
+	return interfacesTmpl.ExecuteTemplate(w, ifs.CLIStyle(), ifs)
+}
+
+func (ifs *Interfaces) ReadXMLFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	}
+
+	if err := jresponse.UnmarshalReply(buf.Bytes(), ifs, &ifs.Errors); err != nil {
+		return n, err
+	}
+
+	if err := jresponse.CheckErrors(ifs.Errors); err != nil {
+		return n, err
+	}
+
+	return n, ifs.Validate()
+}
+
+func (ifs *Interfaces) ReadJSONFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	} else if err = json.Unmarshal(buf.Bytes(), ifs); err != nil {
+		return n, err
+	} else {
+		return n, ifs.Validate()
+	}
+}
+
+// ReadJunosJSONFrom reads the interfaces from the JSON Junos encodes them
+// as, captured with "| display json" or from its REST API.
+func (ifs *Interfaces) ReadJunosJSONFrom(r io.Reader) (n int64, err error) {
+	return jresponse.ReadJunosJSONFrom(r, ifs)
+}
+
+// terseLine formats a row of the terse table.
+func terseLine(name, admin, link, proto, local, remote string) string {
+	if remote != "" {
+		remote = "--> " + remote
+	}
+	line := fmt.Sprintf("%-23s %-5s %-4s %-8s %-21s %s", name, admin, link, proto, local, remote)
+	return strings.TrimRight(line, " ")
+}
+
+// terseLines renders a physical interface and its logical interfaces as rows
+// of the terse table. Only the first row of a logical interface is named,
+// and only the first address of a family names the family.
+func terseLines(phy PhysicalInterface) []string {
+	lines := []string{terseLine(phy.Name, phy.AdminStatus, phy.OperStatus, "", "", "")}
+
+	for _, ifl := range phy.LogicalInterface {
+		name, admin, link := ifl.Name, ifl.AdminStatus, ifl.OperStatus
+		if len(ifl.AddressFamily) == 0 {
+			lines = append(lines, terseLine(name, admin, link, "", "", ""))
+		}
+
+		for _, family := range ifl.AddressFamily {
+			proto := family.AddressFamilyName
+			if len(family.InterfaceAddress) == 0 {
+				lines = append(lines, terseLine(name, admin, link, proto, "", ""))
+				name, admin, link = "", "", ""
+			}
+
+			for _, ifa := range family.InterfaceAddress {
+				lines = append(lines, terseLine(name, admin, link, proto, ifa.IfaLocal, ifa.IfaDestination))
+				name, admin, link, proto = "", "", "", ""
+			}
+		}
+	}
+
+	return lines
+}
+
+var adminStatuses = map[string]string{
+	"up":   "Enabled",
+	"down": "Administratively down",
+}
+
+// adminStatus returns the administrative status as the CLI prints it.
+func adminStatus(status string) string {
+	if s, ok := adminStatuses[status]; ok {
+		return s
+	}
+	return status
+}
+
+// operStatus returns the link status as the CLI prints it, capitalized.
+func operStatus(status string) string {
+	if status == "" {
+		return status
+	}
+	return strings.ToUpper(status[:1]) + status[1:]
+}
+
+// formatTraffic renders traffic statistics, each line indented by indent.
+func formatTraffic(indent string, ts *TrafficStatistics) string {
+	return fmt.Sprintf("%[1]sInput  bytes  : %18[2]d %18[3]d bps\n"+
+		"%[1]sOutput bytes  : %18[4]d %18[5]d bps\n"+
+		"%[1]sInput  packets: %18[6]d %18[7]d pps\n"+
+		"%[1]sOutput packets: %18[8]d %18[9]d pps\n",
+		indent, ts.InputBytes, ts.InputBPS, ts.OutputBytes, ts.OutputBPS,
+		ts.InputPackets, ts.InputPPS, ts.OutputPackets, ts.OutputPPS)
+}
+
+// counter is a named counter of an error list, as the CLI names it.
+type counter struct {
+	name  string
+	value *uint64
+}
+
+func inputErrorCounters(l *InputErrorList) []counter {
+	return []counter{
+		{"Errors", &l.InputErrors},
+		{"Drops", &l.InputDrops},
+		{"Framing errors", &l.FramingErrors},
+		{"Runts", &l.InputRunts},
+		{"Policed discards", &l.InputDiscards},
+		{"L3 incompletes", &l.InputL3Incompletes},
+		{"L2 channel errors", &l.InputL2ChannelErrors},
+		{"L2 mismatch timeouts", &l.InputL2MismatchTimeouts},
+		{"FIFO errors", &l.InputFIFOErrors},
+		{"Resource errors", &l.InputResourceErrors},
+	}
+}
+
+func outputErrorCounters(l *OutputErrorList) []counter {
+	return []counter{
+		{"Carrier transitions", &l.CarrierTransitions},
+		{"Errors", &l.OutputErrors},
+		{"Drops", &l.OutputDrops},
+		{"Collisions", &l.OutputCollisions},
+		{"Aged packets", &l.AgedPackets},
+		{"FIFO errors", &l.OutputFIFOErrors},
+		{"HS link CRC errors", &l.HSLinkCRCErrors},
+		{"MTU errors", &l.MTUErrors},
+		{"Resource errors", &l.OutputResourceErrors},
+	}
+}
+
+// formatCounters renders counters on one line, e.g. "Errors: 0, Drops: 0".
+func formatCounters(counters []counter) string {
+	fields := make([]string, len(counters))
+	for i, c := range counters {
+		fields[i] = fmt.Sprintf("%s: %d", c.name, *c.value)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// parseCounters parses a line rendered by formatCounters into counters.
+func parseCounters(line string, counters []counter) error {
+	fields := strings.Split(strings.TrimSpace(line), ", ")
+	if len(fields) != len(counters) {
+		return fmt.Errorf("expected %d counters, found %d", len(counters), len(fields))
+	}
+
+	for i, field := range fields {
+		value, ok := strings.CutPrefix(field, counters[i].name+": ")
+		if !ok {
+			return fmt.Errorf("expected counter %q, found %q", counters[i].name, field)
+		}
+
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*counters[i].value = v
+	}
+	return nil
+}
+
+const (
+	macHeader = "MAC statistics:                      Receive         Transmit"
+	pcsHeader = "PCS statistics                      Seconds"
+)
+
+// macRow is a row of the MAC statistics table; rx-only rows have a nil tx.
+type macRow struct {
+	name   string
+	rx, tx *uint64
+}
+
+func macRows(s *EthernetMACStatistics) []macRow {
+	return []macRow{
+		{"Total octets", &s.InputBytes, &s.OutputBytes},
+		{"Total packets", &s.InputPackets, &s.OutputPackets},
+		{"Unicast packets", &s.InputUnicasts, &s.OutputUnicasts},
+		{"Broadcast packets", &s.InputBroadcasts, &s.OutputBroadcasts},
+		{"Multicast packets", &s.InputMulticasts, &s.OutputMulticasts},
+		{"CRC/Align errors", &s.InputCRCErrors, &s.OutputCRCErrors},
+		{"FIFO errors", &s.InputFIFOErrors, &s.OutputFIFOErrors},
+		{"MAC control frames", &s.InputMACControlFrames, &s.OutputMACControlFrames},
+		{"MAC pause frames", &s.InputMACPauseFrames, &s.OutputMACPauseFrames},
+		{"Oversized frames", &s.InputOversizedFrames, nil},
+		{"Jabber frames", &s.InputJabberFrames, nil},
+		{"Fragment frames", &s.InputFragmentFrames, nil},
+		{"VLAN tagged frames", &s.InputVLANTaggedFrames, nil},
+		{"Code violations", &s.InputCodeViolations, nil},
+	}
+}
+
+func pcsRows(s *EthernetPCSStatistics) []macRow {
+	return []macRow{
+		{"Bit errors", &s.BitErrorSeconds, nil},
+		{"Errored blocks", &s.ErroredBlocksSeconds, nil},
+	}
+}
+
+func formatRows(rows []macRow) string {
+	buf := bytes.Buffer{}
+	for _, row := range rows {
+		fmt.Fprintf(&buf, "    %-28s %16d", row.name, *row.rx)
+		if row.tx != nil {
+			fmt.Fprintf(&buf, " %16d", *row.tx)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func formatMACRows(s *EthernetMACStatistics) string {
+	return formatRows(macRows(s))
+}
+
+func formatPCSRows(s *EthernetPCSStatistics) string {
+	return formatRows(pcsRows(s))
+}
+
+// formatBriefFamily renders an address family as the brief style lists it:
+// the family name followed by its first address, with any other addresses
+// on lines of their own.
+func formatBriefFamily(family AddressFamily) string {
+	if len(family.InterfaceAddress) == 0 {
+		return fmt.Sprintf("    %s\n", family.AddressFamilyName)
+	}
+
+	buf := bytes.Buffer{}
+	for i, ifa := range family.InterfaceAddress {
+		if i == 0 {
+			fmt.Fprintf(&buf, "    %-5s %s\n", family.AddressFamilyName, ifa.IfaLocal)
+		} else {
+			fmt.Fprintf(&buf, "          %s\n", ifa.IfaLocal)
+		}
+	}
+	return buf.String()
+}
+
+var (
+	physicalRegexp = regexp.MustCompile(`^Physical interface: (\S+), (Enabled|Administratively down|\S+), ` +
+		`Physical link is (\S+)$`)
+	indexRegexp       = regexp.MustCompile(`^  Interface index: (\d+), SNMP ifIndex: (\d+)$`)
+	linkRegexp        = regexp.MustCompile(`^  (?:Type: (.*?), )?Link-level type: (.*?), MTU: (\S+?)(?:, Speed: (\S+))?$`)
+	macRegexp         = regexp.MustCompile(`^  Current address: (\S+), Hardware address: (\S+)$`)
+	logicalRegexp     = regexp.MustCompile(`^  Logical interface (\S+)(?: \(Index (\d+)\) \(SNMP ifIndex (\d+)\))?$`)
+	trafficRegexp     = regexp.MustCompile(`^\s+(Input |Output)\s(bytes  |packets):\s+(\d+)\s+(\d+) [bp]ps$`)
+	rowRegexp         = regexp.MustCompile(`^    (\S.*?)\s+(\d+)(?:\s+(\d+))?$`)
+	protocolRegexp    = regexp.MustCompile(`^    Protocol (\S+?)(?:, MTU: (\S+))?$`)
+	addressRegexp     = regexp.MustCompile(`^        (?:Destination: (\S+), )?Local: (\S+?)(?:, Broadcast: (\S+))?$`)
+	briefFamilyRegexp = regexp.MustCompile(`^    (\S+)(?: +(\S+))?$`)
+	briefAddrRegexp   = regexp.MustCompile(`^          (\S+)$`)
+)
+
+// column returns the trimmed text of line between from and to, where to < 0
+// means the end of the line.
+func column(line string, from, to int) string {
+	if from >= len(line) {
+		return ""
+	}
+	if to < 0 || to > len(line) {
+		to = len(line)
+	}
+	return strings.TrimSpace(line[from:to])
+}
+
+// readTerse parses the rows of the terse table. Columns are located by
+// their offsets in the header, so names must fit their columns, as they do
+// in the Junos CLI.
+func (ifs *Interfaces) readTerse(scanner *bufio.Scanner) error {
+	var (
+		phy    *PhysicalInterface
+		ifl    *LogicalInterface
+		family *AddressFamily
+	)
+
+	for lineNum := 2; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		if line == "" {
+			continue
+		}
+
+		name, admin, link := column(line, 0, 24), column(line, 24, 30), column(line, 30, 35)
+		proto := column(line, 35, 44)
+		addrs := strings.Fields(column(line, 44, -1))
+
+		switch {
+		case name != "" && phy != nil && strings.HasPrefix(name, phy.Name+"."):
+			phy.LogicalInterface = append(phy.LogicalInterface, LogicalInterface{
+				Name: name, AdminStatus: admin, OperStatus: link})
+			ifl, family = &phy.LogicalInterface[len(phy.LogicalInterface)-1], nil
+
+		case name != "":
+			if proto != "" || len(addrs) > 0 {
+				return fmt.Errorf("line %d: physical interface %s with addresses", lineNum, name)
+			}
+			ifs.PhysicalInterface = append(ifs.PhysicalInterface, PhysicalInterface{
+				Name: name, AdminStatus: admin, OperStatus: link})
+			phy, ifl, family = &ifs.PhysicalInterface[len(ifs.PhysicalInterface)-1], nil, nil
+			continue
+
+		case ifl == nil:
+			return fmt.Errorf("line %d: addresses without a logical interface", lineNum)
+		}
+
+		if proto != "" {
+			ifl.AddressFamily = append(ifl.AddressFamily, AddressFamily{AddressFamilyName: proto})
+			family = &ifl.AddressFamily[len(ifl.AddressFamily)-1]
+		}
+
+		if len(addrs) > 0 {
+			if family == nil || (len(addrs) != 1 && (len(addrs) != 3 || addrs[1] != "-->")) {
+				return fmt.Errorf("line %d: unrecognized interface output %q", lineNum, line)
+			}
+			ifa := InterfaceAddress{IfaLocal: addrs[0]}
+			if len(addrs) == 3 {
+				ifa.IfaDestination = addrs[2]
+			}
+			family.InterfaceAddress = append(family.InterfaceAddress, ifa)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// cliReader holds the state of parsing the brief and extensive styles.
+type cliReader struct {
+	ifs     *Interfaces
+	phy     *PhysicalInterface
+	ifl     *LogicalInterface
+	family  *AddressFamily
+	section string
+}
+
+var errNoInterface = errors.New("output outside of an interface")
+
+func parseUint(s string) uint {
+	v, _ := strconv.ParseUint(s, 10, 0)
+	return uint(v)
+}
+
+// statuses maps the CLI's administrative and link statuses back to those of
+// the XML.
+func statuses(admin, link string) (string, string) {
+	for status, s := range adminStatuses {
+		if s == admin {
+			admin = status
+		}
+	}
+	return admin, strings.ToLower(link)
+}
+
+// readLine parses one line of the brief or extensive styles.
+func (cr *cliReader) readLine(line string) error {
+	if m := physicalRegexp.FindStringSubmatch(line); m != nil {
+		phy := PhysicalInterface{Name: m[1]}
+		phy.AdminStatus, phy.OperStatus = statuses(m[2], m[3])
+		cr.ifs.PhysicalInterface = append(cr.ifs.PhysicalInterface, phy)
+		cr.phy, cr.ifl, cr.family, cr.section = &cr.ifs.PhysicalInterface[len(cr.ifs.PhysicalInterface)-1], nil, nil, ""
+		return nil
+	} else if cr.phy == nil {
+		return errNoInterface
+	}
+
+	if m := logicalRegexp.FindStringSubmatch(line); m != nil {
+		cr.phy.LogicalInterface = append(cr.phy.LogicalInterface,
+			LogicalInterface{Name: m[1], LocalIndex: parseUint(m[2]), SNMPIndex: parseUint(m[3])})
+		cr.ifl, cr.family, cr.section = &cr.phy.LogicalInterface[len(cr.phy.LogicalInterface)-1], nil, ""
+		return nil
+	}
+
+	if cr.ifl != nil {
+		return cr.readLogicalLine(line)
+	}
+
+	phy := cr.phy
+	switch {
+	case strings.HasPrefix(line, "  Description: "):
+		phy.Description = strings.TrimPrefix(line, "  Description: ")
+	case strings.HasPrefix(line, "  Last flapped   : "):
+		phy.InterfaceFlapped = &InterfaceFlapped{Value: strings.TrimPrefix(line, "  Last flapped   : ")}
+		return cr.flappedSeconds()
+
+	case line == "  Traffic statistics:":
+		phy.TrafficStatistics, cr.section = new(TrafficStatistics), "traffic"
+	case line == "  Input errors:":
+		phy.InputErrorList, cr.section = new(InputErrorList), "input"
+	case line == "  Output errors:":
+		phy.OutputErrorList, cr.section = new(OutputErrorList), "output"
+	case line == "  "+macHeader:
+		phy.EthernetMACStatistics, cr.section = new(EthernetMACStatistics), "mac"
+	case line == "  "+pcsHeader:
+		phy.EthernetPCSStatistics, cr.section = new(EthernetPCSStatistics), "pcs"
+
+	case cr.section == "traffic":
+		return readTraffic(line, phy.TrafficStatistics)
+	case cr.section == "input":
+		return parseCounters(line, inputErrorCounters(phy.InputErrorList))
+	case cr.section == "output":
+		return parseCounters(line, outputErrorCounters(phy.OutputErrorList))
+	case cr.section == "mac":
+		return readRow(line, macRows(phy.EthernetMACStatistics))
+	case cr.section == "pcs":
+		return readRow(line, pcsRows(phy.EthernetPCSStatistics))
+
+	default:
+		if m := indexRegexp.FindStringSubmatch(line); m != nil {
+			phy.LocalIndex, phy.SNMPIndex = parseUint(m[1]), parseUint(m[2])
+		} else if m := linkRegexp.FindStringSubmatch(line); m != nil {
+			phy.IfType, phy.LinkLevelType, phy.MTU, phy.Speed = m[1], m[2], m[3], m[4]
+		} else if m := macRegexp.FindStringSubmatch(line); m != nil {
+			phy.CurrentPhysicalAddress, phy.HardwarePhysicalAddress = m[1], m[2]
+		} else {
+			return fmt.Errorf("unrecognized interface output %q", line)
+		}
+	}
+	return nil
+}
+
+// flappedSeconds sets the seconds of the last flap from the age Junos
+// prints after its time, e.g. "2016-08-10 11:12:13 UTC (1w2d 03:04:05 ago)".
+func (cr *cliReader) flappedSeconds() error {
+	flapped := cr.phy.InterfaceFlapped
+	start, end := strings.LastIndexByte(flapped.Value, '('), strings.LastIndex(flapped.Value, " ago)")
+	if start < 0 || end < start {
+		return nil
+	}
+
+	d, err := jresponse.ParseJunosDuration(flapped.Value[start+1 : end])
+	if err != nil {
+		return err
+	}
+	flapped.Seconds = jresponse.JunosAttr(strconv.FormatInt(int64(d/time.Second), 10))
+	return nil
+}
+
+// readLogicalLine parses a line belonging to a logical interface.
+func (cr *cliReader) readLogicalLine(line string) error {
+	ifl := cr.ifl
+	switch {
+	case strings.HasPrefix(line, "    Description: "):
+		ifl.Description = strings.TrimPrefix(line, "    Description: ")
+	case strings.HasPrefix(line, "    Encapsulation: "):
+		ifl.Encapsulation = strings.TrimPrefix(line, "    Encapsulation: ")
+	case line == "    Traffic statistics:":
+		ifl.TrafficStatistics, cr.section = new(TrafficStatistics), "traffic"
+	case line == "      Addresses":
+
+	case cr.section == "traffic" && trafficRegexp.MatchString(line):
+		return readTraffic(line, ifl.TrafficStatistics)
+
+	default:
+		if m := protocolRegexp.FindStringSubmatch(line); m != nil {
+			ifl.AddressFamily = append(ifl.AddressFamily, AddressFamily{AddressFamilyName: m[1], MTU: m[2]})
+		} else if m := addressRegexp.FindStringSubmatch(line); m != nil && cr.lastFamily() != nil {
+			family := cr.lastFamily()
+			family.InterfaceAddress = append(family.InterfaceAddress,
+				InterfaceAddress{IfaDestination: m[1], IfaLocal: m[2], IfaBroadcast: m[3]})
+		} else if m := briefFamilyRegexp.FindStringSubmatch(line); m != nil {
+			family := AddressFamily{AddressFamilyName: m[1]}
+			if m[2] != "" {
+				family.InterfaceAddress = []InterfaceAddress{{IfaLocal: m[2]}}
+			}
+			ifl.AddressFamily = append(ifl.AddressFamily, family)
+		} else if m := briefAddrRegexp.FindStringSubmatch(line); m != nil && cr.lastFamily() != nil {
+			family := cr.lastFamily()
+			family.InterfaceAddress = append(family.InterfaceAddress, InterfaceAddress{IfaLocal: m[1]})
+		} else {
+			return fmt.Errorf("unrecognized interface output %q", line)
+		}
+		cr.section = ""
+	}
+	return nil
+}
+
+func (cr *cliReader) lastFamily() *AddressFamily {
+	if len(cr.ifl.AddressFamily) == 0 {
+		return nil
+	}
+	return &cr.ifl.AddressFamily[len(cr.ifl.AddressFamily)-1]
+}
+
+// readTraffic parses a line rendered by formatTraffic.
+func readTraffic(line string, ts *TrafficStatistics) error {
+	m := trafficRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("unrecognized traffic statistics %q", line)
+	}
+
+	var count, rate *uint64
+	switch m[1] + m[2] {
+	case "Input bytes  ":
+		count, rate = &ts.InputBytes, &ts.InputBPS
+	case "Outputbytes  ":
+		count, rate = &ts.OutputBytes, &ts.OutputBPS
+	case "Input packets":
+		count, rate = &ts.InputPackets, &ts.InputPPS
+	case "Outputpackets":
+		count, rate = &ts.OutputPackets, &ts.OutputPPS
+	}
+
+	var err error
+	if *count, err = strconv.ParseUint(m[3], 10, 64); err != nil {
+		return err
+	}
+	*rate, err = strconv.ParseUint(m[4], 10, 64)
+	return err
+}
+
+// readRow parses a row rendered by formatRows into the matching row.
+func readRow(line string, rows []macRow) error {
+	m := rowRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("unrecognized statistics %q", line)
+	}
+
+	for _, row := range rows {
+		if row.name != m[1] {
+			continue
+		} else if (row.tx == nil) != (m[3] == "") {
+			return fmt.Errorf("unexpected columns for %s", row.name)
+		}
+
+		var err error
+		if *row.rx, err = strconv.ParseUint(m[2], 10, 64); err != nil || row.tx == nil {
+			return err
+		}
+		*row.tx, err = strconv.ParseUint(m[3], 10, 64)
+		return err
+	}
+	return fmt.Errorf("unknown statistic %q", m[1])
+}
+
+// ReadCLIFrom parses the text output of "show interfaces" in the terse,
+// brief or extensive style, as produced by WriteCLITo, into ifs.
+func (ifs *Interfaces) ReadCLIFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	}
+
+	scanner := bufio.NewScanner(&buf)
+
+	if !scanner.Scan() {
+		if err = scanner.Err(); err == nil {
+			err = errors.New("no interfaces found")
+		}
+		return n, err
+	}
+
+	if first := strings.TrimRight(scanner.Text(), " \r"); first == terseHeader {
+		ifs.Style = StyleTerse
+		if err = ifs.readTerse(scanner); err != nil {
+			return n, err
+		}
+	} else {
+		cr := cliReader{ifs: ifs}
+		ifs.Style = StyleBrief
+
+		for lineNum, line := 1, first; ; lineNum++ {
+			if line != "" {
+				if err := cr.readLine(line); err != nil {
+					return n, fmt.Errorf("line %d: %v", lineNum, err)
+				}
+			}
+
+			if strings.HasPrefix(line, "  Interface index: ") {
+				ifs.Style = StyleExtensive
+			}
+
+			if !scanner.Scan() {
+				break
+			}
+			line = strings.TrimRight(scanner.Text(), " \r")
+		}
+
+		if err = scanner.Err(); err != nil {
+			return n, err
+		}
+	}
+
+	if len(ifs.PhysicalInterface) == 0 {
+		return n, errors.New("no interfaces found")
+	}
+
+	return n, ifs.Validate()
+}
@@ -0,0 +1,350 @@
+package interfaces
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/netip"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+var (
+	interfacesXMLModel, interfacesJSONModel *Interfaces
+)
+
+const (
+	TERSE_XML_FILE        = "show_interfaces_terse.xml"
+	TERSE_JSON_FILE       = "show_interfaces_terse.json"
+	TERSE_CLI_FILE        = "show_interfaces_terse.cli"
+	TERSE_JUNOS_JSON_FILE = "show_interfaces_terse_junos_synthetic.json"
+
+	BRIEF_XML_FILE = "show_interfaces_brief.xml"
+	BRIEF_CLI_FILE = "show_interfaces_brief.cli"
+
+	EXTENSIVE_XML_FILE = "show_interfaces_extensive.xml"
+	EXTENSIVE_CLI_FILE = "show_interfaces_extensive.cli"
+)
+
+func initInterfacesModel() {
+	interfacesXMLModel = &Interfaces{
+		XMLName: xml.Name{Space: "http://xml.juniper.net/junos/12.3R6/junos-interface", Local: "interface-information"},
+		Style:   "terse",
+		PhysicalInterface: []PhysicalInterface{
+			{
+				Name:        "ge-0/0/0",
+				AdminStatus: "up",
+				OperStatus:  "up",
+				LogicalInterface: []LogicalInterface{{
+					Name:        "ge-0/0/0.0",
+					AdminStatus: "up",
+					OperStatus:  "up",
+					AddressFamily: []AddressFamily{
+						{
+							AddressFamilyName: "inet",
+							InterfaceAddress:  []InterfaceAddress{{IfaLocal: "206.126.236.21/22"}},
+						},
+						{
+							AddressFamilyName: "inet6",
+							InterfaceAddress: []InterfaceAddress{
+								{IfaLocal: "2001:504:0:2:0:0:7922:1/64"},
+								{IfaLocal: "fe80::205:8600:171:1ac0/64"},
+							},
+						},
+						{
+							AddressFamilyName: "mpls",
+						},
+					},
+				}},
+			},
+			{
+				Name:        "ge-0/0/1",
+				AdminStatus: "down",
+				OperStatus:  "down",
+			},
+			{
+				Name:        "lo0",
+				AdminStatus: "up",
+				OperStatus:  "up",
+				LogicalInterface: []LogicalInterface{{
+					Name:        "lo0.0",
+					AdminStatus: "up",
+					OperStatus:  "up",
+					AddressFamily: []AddressFamily{{
+						AddressFamilyName: "inet",
+						InterfaceAddress:  []InterfaceAddress{{IfaLocal: "69.73.0.1", IfaDestination: "0/0"}},
+					}},
+				}},
+			},
+		},
+	}
+
+	model := *interfacesXMLModel
+	model.XMLName = xml.Name{}
+	interfacesJSONModel = &model
+}
+
+func TestMain(m *testing.M) {
+	initInterfacesModel()
+	os.Exit(m.Run())
+}
+
+func TestReadXMLFrom(t *testing.T) {
+
+	ifs := new(Interfaces)
+
+	if file, err := os.Open(TERSE_XML_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := ifs.ReadXMLFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(ifs, interfacesXMLModel) {
+		t.Log(interfacesXMLModel)
+		t.Log(ifs)
+		t.Error("unmarshalled XML does not match interfaces model")
+	}
+}
+
+func TestReadJSONFrom(t *testing.T) {
+
+	ifs := new(Interfaces)
+
+	if file, err := os.Open(TERSE_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := ifs.ReadJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(ifs, interfacesJSONModel) {
+		t.Log(interfacesJSONModel)
+		t.Log(ifs)
+		t.Error("unmarshalled JSON does not match interfaces model")
+	}
+}
+
+func TestReadCLIFrom(t *testing.T) {
+
+	ifs := new(Interfaces)
+
+	if file, err := os.Open(TERSE_CLI_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := ifs.ReadCLIFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(ifs, interfacesJSONModel) {
+		t.Log(interfacesJSONModel)
+		t.Log(ifs)
+		t.Error("parsed CLI does not match interfaces model")
+	}
+}
+
+func TestReadCLIFromInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"  Type: Ethernet, Link-level type: Ethernet, MTU: 1514\n",
+		terseHeader + "\n" +
+			"                                   inet     10.0.0.1/30\n",
+		terseHeader + "\n" +
+			"ge-0/0/0                up    up   inet     10.0.0.1/30\n",
+		"Physical interface: ge-0/0/0, Enabled, Physical link is Up\n" +
+			"  Input errors:\n" +
+			"    Errors: 0, Drops: 0\n",
+		"Physical interface: ge-0/0/0, Enabled, Physical link is Up\n" +
+			"\n" +
+			"  Logical interface ge-0/0/0.0\n" +
+			"    inet  10.0.0.300/30\n",
+	}
+
+	for i, input := range inputs {
+		if _, err := new(Interfaces).ReadCLIFrom(bytes.NewBufferString(input)); err == nil {
+			t.Errorf("input %d: expected an error", i)
+		}
+	}
+}
+
+func TestCLIStyles(t *testing.T) {
+	files := map[string]string{
+		TERSE_XML_FILE:     TERSE_CLI_FILE,
+		BRIEF_XML_FILE:     BRIEF_CLI_FILE,
+		EXTENSIVE_XML_FILE: EXTENSIVE_CLI_FILE,
+	}
+
+	for xmlFile, cliFile := range files {
+		xmlIfs, cliIfs := new(Interfaces), new(Interfaces)
+
+		if file, err := os.Open(xmlFile); err != nil {
+			t.Fatal(err)
+		} else if _, err := xmlIfs.ReadXMLFrom(file); err != nil {
+			t.Fatal(err)
+		}
+
+		fileBuf, modelBuf := bytes.Buffer{}, bytes.Buffer{}
+		if file, err := os.Open(cliFile); err != nil {
+			t.Fatal(err)
+		} else if _, err := fileBuf.ReadFrom(file); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := xmlIfs.WriteCLITo(&modelBuf); err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+			t.Log(modelBuf.String())
+			t.Errorf("%s: CLI output does not match %s", xmlFile, cliFile)
+		}
+
+		// the style is recovered from the CLI output
+		xmlIfs.XMLName = xml.Name{}
+		if _, err := cliIfs.ReadCLIFrom(&fileBuf); err != nil {
+			t.Errorf("%s: %v", cliFile, err)
+		} else if !reflect.DeepEqual(cliIfs, xmlIfs) {
+			t.Log(xmlIfs)
+			t.Log(cliIfs)
+			t.Errorf("parsed %s does not match %s", cliFile, xmlFile)
+		}
+
+		// without a junos:style the style follows the data
+		style := string(xmlIfs.Style)
+		if xmlIfs.Style = ""; xmlIfs.CLIStyle() != style {
+			t.Errorf("%s: CLIStyle() = %s without a junos:style, should be %s", xmlFile, xmlIfs.CLIStyle(), style)
+		}
+	}
+}
+
+func TestWriteXMLTo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+	if _, err := interfacesXMLModel.WriteXMLTo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(TERSE_XML_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	ifs := new(Interfaces)
+	ifs.ReadXMLFrom(&fileBuf)
+
+	fileBuf.Reset()
+	ifs.WriteXMLTo(&fileBuf)
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(ifs)
+		t.Log(interfacesXMLModel)
+		t.Error("XML bytes not equal")
+	}
+}
+
+func TestWriteJSONTo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+	if _, err := interfacesJSONModel.WriteJSONTo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(TERSE_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	ifs := new(Interfaces)
+	ifs.ReadJSONFrom(&fileBuf)
+	fileBuf.Reset()
+	ifs.WriteJSONTo(&fileBuf)
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(ifs)
+		t.Log(interfacesJSONModel)
+		t.Error("JSON bytes not equal")
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	ifs := new(Interfaces)
+	if file, err := os.Open(EXTENSIVE_XML_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := ifs.ReadXMLFrom(file); err != nil {
+		t.Fatal(err)
+	}
+
+	phy := ifs.PhysicalInterface[0]
+	if !phy.Up() || interfacesXMLModel.PhysicalInterface[1].Up() {
+		t.Error("Up() returned unexpected results")
+	}
+
+	if mac, err := phy.HardwareAddr(); err != nil || mac.String() != "00:05:86:71:1a:c0" {
+		t.Errorf("HardwareAddr() = %v, %v", mac, err)
+	}
+
+	if d, err := phy.InterfaceFlapped.Duration(); err != nil || d != 762247*time.Second {
+		t.Errorf("Duration() = %v, %v", d, err)
+	}
+
+	speeds := map[string]uint64{"1000mbps": 1000000000, "10Gbps": 10000000000, "100bps": 100}
+	for speed, bps := range speeds {
+		p := PhysicalInterface{Speed: speed}
+		if s, err := p.SpeedBps(); err != nil || s != bps {
+			t.Errorf("SpeedBps() = %d, %v for %s, should be %d", s, err, speed, bps)
+		}
+	}
+	if _, err := (&PhysicalInterface{Speed: "Unlimited"}).SpeedBps(); err == nil {
+		t.Error("SpeedBps() should fail for an unlimited speed")
+	}
+
+	// both ways of reporting an address give the same prefix
+	extensive := phy.LogicalInterface[0].AddressFamily[0].InterfaceAddress[0]
+	terse := interfacesXMLModel.PhysicalInterface[0].LogicalInterface[0].AddressFamily[0].InterfaceAddress[0]
+	want := netip.MustParsePrefix("206.126.236.21/22")
+	if prefix, err := extensive.Prefix(); err != nil || prefix != want {
+		t.Errorf("Prefix() = %v, %v", prefix, err)
+	} else if prefix, err := terse.Prefix(); err != nil || prefix != want {
+		t.Errorf("Prefix() = %v, %v", prefix, err)
+	}
+
+	buf := bytes.Buffer{}
+	if _, err := ifs.WriteJSONToWithOptions(&buf, jresponse.JSONOptions{MillisecondDurations: true}); err != nil {
+		t.Error(err)
+	} else if !bytes.Contains(buf.Bytes(), []byte(`"flapped-ms":762247000`)) {
+		t.Errorf("time since the last flap not in milliseconds: %s", buf.String())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ifs := Interfaces{PhysicalInterface: []PhysicalInterface{{
+		Name: "ge-0/0/0",
+		LogicalInterface: []LogicalInterface{{
+			Name: "ge-0/0/0.0",
+			AddressFamily: []AddressFamily{
+				{AddressFamilyName: "iso", InterfaceAddress: []InterfaceAddress{{IfaLocal: "49.0001.0690.7300.0001.00"}}},
+				{AddressFamilyName: "inet", InterfaceAddress: []InterfaceAddress{{IfaLocal: "10.0.0.300/30"}}},
+			},
+		}},
+	}}}
+
+	var addrErr *jresponse.AddrError
+	if err := ifs.Validate(); !errors.As(err, &addrErr) {
+		t.Errorf("expected an AddrError, got %v", err)
+	} else if addrErr.Path != "interface-information/physical-interface[1]/logical-interface[1]/"+
+		"address-family[2]/interface-address[1]/ifa-local" {
+		t.Errorf("unexpected path %s", addrErr.Path)
+	}
+}
+
+func TestReadJunosJSONFrom(t *testing.T) {
+
+	ifs := new(Interfaces)
+
+	if file, err := os.Open(TERSE_JUNOS_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := ifs.ReadJunosJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(ifs, interfacesXMLModel) {
+		t.Log(interfacesXMLModel)
+		t.Log(ifs)
+		t.Error("unmarshalled Junos JSON does not match the interfaces xml model")
+	}
+}
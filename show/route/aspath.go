@@ -0,0 +1,327 @@
+package route
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ASN is a 4-byte autonomous system number.
+type ASN uint32
+
+// ParseASN parses an AS number in asplain ("131072") or asdot ("2.0")
+// notation.
+func ParseASN(s string) (ASN, error) {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		high, err := strconv.ParseUint(s[:i], 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid AS number %q", s)
+		}
+		low, err := strconv.ParseUint(s[i+1:], 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid AS number %q", s)
+		}
+		return ASN(high<<16 | low), nil
+	}
+
+	asn, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid AS number %q", s)
+	}
+	return ASN(asn), nil
+}
+
+// String returns the AS number in asplain notation.
+func (asn ASN) String() string {
+	return strconv.FormatUint(uint64(asn), 10)
+}
+
+// ASDot returns the AS number in asdot notation, which only differs from
+// asplain for AS numbers that don't fit in 2 bytes.
+func (asn ASN) ASDot() string {
+	if asn < 1<<16 {
+		return asn.String()
+	}
+	return fmt.Sprintf("%d.%d", asn>>16, asn&0xffff)
+}
+
+type SegmentType int
+
+const (
+	ASSequence SegmentType = iota
+	ASSet
+	ASConfedSequence
+	ASConfedSet
+)
+
+func (t SegmentType) String() string {
+	switch t {
+	case ASSequence:
+		return "AS_SEQUENCE"
+	case ASSet:
+		return "AS_SET"
+	case ASConfedSequence:
+		return "AS_CONFED_SEQUENCE"
+	case ASConfedSet:
+		return "AS_CONFED_SET"
+	default:
+		return fmt.Sprintf("SegmentType(%d)", int(t))
+	}
+}
+
+type ASPathSegment struct {
+	Type SegmentType
+	ASNs []ASN
+}
+
+// Origin is the BGP origin code Junos prints at the end of an AS path.
+type Origin string
+
+const (
+	OriginIGP        Origin = "I"
+	OriginEGP        Origin = "E"
+	OriginIncomplete Origin = "?"
+)
+
+type Aggregator struct {
+	AS      ASN
+	Address string
+}
+
+// ASPath is the parsed form of an AS path as Junos prints it, e.g.
+// "65001 (65100 65101) 65002 65002 {65003 65004} I".
+type ASPath struct {
+	Raw      string
+	Segments []ASPathSegment
+	Origin   Origin
+
+	// LocalAS holds the numbers Junos prints in brackets ahead of the path
+	// when a local AS is configured; they are not part of the path itself.
+	LocalAS []ASN
+
+	Recorded        bool
+	AtomicAggregate bool
+	Aggregator      *Aggregator
+
+	// Annotations holds any other words following the origin code.
+	Annotations []string
+}
+
+// asPathTokens splits an AS path into words, with each bracket as a word
+// of its own.
+func asPathTokens(s string) []string {
+	s = strings.NewReplacer(
+		"{", " { ", "}", " } ",
+		"(", " ( ", ")", " ) ",
+		"[", " [ ", "]", " ] ",
+		",", " ",
+	).Replace(s)
+	return strings.Fields(s)
+}
+
+// ParseASPath parses an AS path as found in the as-path element or the
+// "AS path:" line of the CLI.
+func ParseASPath(s string) (*ASPath, error) {
+	path := &ASPath{Raw: s}
+
+	var (
+		segType  = ASSequence
+		inConfed bool
+		inLocal  bool
+		closer   string
+	)
+
+	tokens := asPathTokens(strings.TrimPrefix(strings.TrimSpace(s), "AS path:"))
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		if path.Origin != "" {
+			i = path.parseAnnotation(tokens, i)
+			continue
+		}
+
+		switch tok {
+		case "{":
+			if closer != "" {
+				return nil, fmt.Errorf("unexpected { in AS path %q", s)
+			}
+			segType, closer = ASSet, "}"
+			path.Segments = append(path.Segments, ASPathSegment{Type: ASSet})
+
+		case "(":
+			if closer != "" {
+				return nil, fmt.Errorf("unexpected ( in AS path %q", s)
+			}
+			segType, closer, inConfed = ASConfedSequence, ")", true
+
+		case "[":
+			if inConfed && closer == ")" {
+				segType, closer = ASConfedSet, "]"
+				path.Segments = append(path.Segments, ASPathSegment{Type: ASConfedSet})
+			} else if closer == "" && len(path.Segments) == 0 {
+				closer, inLocal = "]", true
+			} else {
+				return nil, fmt.Errorf("unexpected [ in AS path %q", s)
+			}
+
+		case "}", ")", "]":
+			if tok != closer {
+				return nil, fmt.Errorf("unbalanced %s in AS path %q", tok, s)
+			}
+
+			switch {
+			case tok == "]" && inConfed:
+				segType, closer = ASConfedSequence, ")"
+			case tok == "]":
+				inLocal, closer = false, ""
+			default:
+				segType, closer, inConfed = ASSequence, "", false
+			}
+
+		case string(OriginIGP), string(OriginEGP), string(OriginIncomplete):
+			if closer != "" {
+				return nil, fmt.Errorf("unterminated segment in AS path %q", s)
+			}
+			path.Origin = Origin(tok)
+
+		case "Recorded":
+			path.Recorded = true
+
+		default:
+			asn, err := ParseASN(tok)
+			if err != nil {
+				return nil, fmt.Errorf("%v in AS path %q", err, s)
+			}
+
+			if inLocal {
+				path.LocalAS = append(path.LocalAS, asn)
+				continue
+			}
+
+			// sets are started explicitly, sequences by their first AS
+			last := len(path.Segments) - 1
+			if last < 0 || path.Segments[last].Type != segType ||
+				(segType == ASConfedSequence && tokens[i-1] == "(") {
+				path.Segments = append(path.Segments, ASPathSegment{Type: segType})
+				last++
+			}
+			path.Segments[last].ASNs = append(path.Segments[last].ASNs, asn)
+		}
+	}
+
+	if closer != "" {
+		return nil, fmt.Errorf("unterminated segment in AS path %q", s)
+	}
+
+	return path, nil
+}
+
+// parseAnnotation handles the words following the origin code, returning
+// the index of the last token consumed.
+func (path *ASPath) parseAnnotation(tokens []string, i int) int {
+	switch tok := tokens[i]; tok {
+	case "(", ")":
+	case "Recorded":
+		path.Recorded = true
+	case "Atomic", "Atomic-Aggregate", "AtomicAggregate":
+		path.AtomicAggregate = true
+	case "Aggregator:":
+		if i+2 < len(tokens) {
+			if asn, err := ParseASN(tokens[i+1]); err == nil {
+				path.Aggregator = &Aggregator{AS: asn, Address: tokens[i+2]}
+				return i + 2
+			}
+		}
+		path.Annotations = append(path.Annotations, tok)
+	default:
+		path.Annotations = append(path.Annotations, tok)
+	}
+	return i
+}
+
+// String returns the AS path as Junos printed it.
+func (path *ASPath) String() string {
+	return path.Raw
+}
+
+// Length returns the AS path length used in best path selection: every AS
+// in a sequence counts, a set counts as one, confederation segments don't
+// count.
+func (path *ASPath) Length() int {
+	length := 0
+	for _, seg := range path.Segments {
+		switch seg.Type {
+		case ASSequence:
+			length += len(seg.ASNs)
+		case ASSet:
+			length++
+		}
+	}
+	return length
+}
+
+// OriginAS returns the AS that originated the route, the last AS of the
+// path. It returns false for locally originated routes and paths ending in
+// an AS set, whose origin is ambiguous.
+func (path *ASPath) OriginAS() (ASN, bool) {
+	for i := len(path.Segments) - 1; i >= 0; i-- {
+		switch seg := path.Segments[i]; seg.Type {
+		case ASSequence:
+			if len(seg.ASNs) > 0 {
+				return seg.ASNs[len(seg.ASNs)-1], true
+			}
+		case ASSet:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// Contains reports whether asn appears anywhere in the path, including sets
+// and confederation segments.
+func (path *ASPath) Contains(asn ASN) bool {
+	for _, seg := range path.Segments {
+		for _, a := range seg.ASNs {
+			if a == asn {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Prepend is a run of the same AS repeated in an AS sequence.
+type Prepend struct {
+	AS ASN
+	// Count is the number of times the AS was prepended, one less than
+	// the number of times it appears in the run.
+	Count int
+}
+
+// Prepends returns each run of a repeated AS in the path's sequences, in
+// path order.
+func (path *ASPath) Prepends() []Prepend {
+	var prepends []Prepend
+	for _, seg := range path.Segments {
+		if seg.Type != ASSequence {
+			continue
+		}
+
+		for i := 0; i < len(seg.ASNs); {
+			j := i + 1
+			for j < len(seg.ASNs) && seg.ASNs[j] == seg.ASNs[i] {
+				j++
+			}
+			if j-i > 1 {
+				prepends = append(prepends, Prepend{AS: seg.ASNs[i], Count: j - i - 1})
+			}
+			i = j
+		}
+	}
+	return prepends
+}
+
+// ParseASPath parses the entry's AS path.
+func (e *RTEntry) ParseASPath() (*ASPath, error) {
+	return ParseASPath(e.AsPath)
+}
@@ -0,0 +1,142 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseASN(t *testing.T) {
+	asns := map[string]ASN{
+		"15169":      15169,
+		"4200000001": 4200000001,
+		"1.10":       65546,
+		"0.65535":    65535,
+	}
+
+	for s, asn := range asns {
+		if a, err := ParseASN(s); err != nil {
+			t.Error(err)
+		} else if a != asn {
+			t.Errorf("ParseASN(%q) = %d, should be %d", s, a, asn)
+		}
+	}
+
+	for _, s := range []string{"", "AS15169", "4294967296", "65536.1", "1.65536"} {
+		if _, err := ParseASN(s); err == nil {
+			t.Errorf("ParseASN(%q) should fail", s)
+		}
+	}
+
+	if s := ASN(65546).ASDot(); s != "1.10" {
+		t.Errorf("ASDot() = %s, should be 1.10", s)
+	} else if s := ASN(15169).ASDot(); s != "15169" {
+		t.Errorf("ASDot() = %s, should be 15169", s)
+	}
+}
+
+func TestParseASPath(t *testing.T) {
+	tests := []struct {
+		raw  string
+		path *ASPath
+	}{
+		{
+			raw: "15169 I",
+			path: &ASPath{
+				Segments: []ASPathSegment{{ASSequence, []ASN{15169}}},
+				Origin:   OriginIGP,
+			},
+		},
+		{
+			raw:  "I",
+			path: &ASPath{Origin: OriginIGP},
+		},
+		{
+			raw: "3356 3356 3356 1.10 {64512 64513} ?",
+			path: &ASPath{
+				Segments: []ASPathSegment{
+					{ASSequence, []ASN{3356, 3356, 3356, 65546}},
+					{ASSet, []ASN{64512, 64513}},
+				},
+				Origin: OriginIncomplete,
+			},
+		},
+		{
+			raw: "(65100 65101 [65102 65103]) 65002 E",
+			path: &ASPath{
+				Segments: []ASPathSegment{
+					{ASConfedSequence, []ASN{65100, 65101}},
+					{ASConfedSet, []ASN{65102, 65103}},
+					{ASSequence, []ASN{65002}},
+				},
+				Origin: OriginEGP,
+			},
+		},
+		{
+			raw: "[65000] 174 I (Atomic) Aggregator: 174 10.0.0.1",
+			path: &ASPath{
+				Segments:        []ASPathSegment{{ASSequence, []ASN{174}}},
+				Origin:          OriginIGP,
+				LocalAS:         []ASN{65000},
+				AtomicAggregate: true,
+				Aggregator:      &Aggregator{AS: 174, Address: "10.0.0.1"},
+			},
+		},
+		{
+			raw: "AS path: 7018 I Recorded (Looped: 65001)",
+			path: &ASPath{
+				Segments:    []ASPathSegment{{ASSequence, []ASN{7018}}},
+				Origin:      OriginIGP,
+				Recorded:    true,
+				Annotations: []string{"Looped:", "65001"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test.path.Raw = test.raw
+		if path, err := ParseASPath(test.raw); err != nil {
+			t.Error(err)
+		} else if !reflect.DeepEqual(path, test.path) {
+			t.Errorf("ParseASPath(%q) = %+v, should be %+v", test.raw, path, test.path)
+		}
+	}
+
+	for _, raw := range []string{"15169 {1 2 I", "15169 } I", "AS15169 I", "(1 {2}) I", "1 [2] I"} {
+		if _, err := ParseASPath(raw); err == nil {
+			t.Errorf("ParseASPath(%q) should fail", raw)
+		}
+	}
+}
+
+func TestASPathHelpers(t *testing.T) {
+	path, err := ParseASPath("(65100) 6939 6939 3356 3356 3356 15169 {64512 64513} I")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := path.Length(); l != 7 {
+		t.Errorf("Length() = %d, should be 7", l)
+	}
+
+	if _, ok := path.OriginAS(); ok {
+		t.Error("a path ending in an AS set should not have an origin AS")
+	}
+
+	if !path.Contains(64513) || !path.Contains(65100) || path.Contains(174) {
+		t.Error("Contains() returned unexpected results")
+	}
+
+	prepends := []Prepend{{AS: 6939, Count: 1}, {AS: 3356, Count: 2}}
+	if p := path.Prepends(); !reflect.DeepEqual(p, prepends) {
+		t.Errorf("Prepends() = %v, should be %v", p, prepends)
+	}
+
+	entry := RTEntry{AsPath: "15169 I"}
+	if path, err := entry.ParseASPath(); err != nil {
+		t.Error(err)
+	} else if asn, ok := path.OriginAS(); !ok || asn != 15169 {
+		t.Errorf("OriginAS() = %d, %v; should be 15169", asn, ok)
+	} else if path.String() != "15169 I" {
+		t.Errorf("String() = %q", path.String())
+	}
+}
@@ -4,138 +4,124 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
-	tmpl "text/template"
+	"strings"
+	"time"
 
-	log "github.com/Sirupsen/logrus"
-)
-
-var (
-	bgpRouteTmpl *tmpl.Template
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/show/route"
 )
 
 func init() {
-	cntxLog := log.WithFields(log.Fields{
-		"func": "response.init()",
-	})
-
-	fmtFuncMap := tmpl.FuncMap{"isNextHop": isNextHop}
-
-	var err error
-	if bgpRouteTmpl, err = tmpl.
-	New("bgpRouteTmpl").
-	Funcs(fmtFuncMap).
-	Parse(bgpRouteTmplStr); err != nil {
-		cntxLog.Fatalln(err)
-	}
+	jresponse.RegisterMatch(xml.Name{Space: jresponse.JunosNamespace("junos-routing"), Local: "route-information"},
+		onlyBGP, func() jresponse.ResponseReaderWriter { return new(BGPRoute) })
 }
 
-const bgpRouteTmplStr = "{{.RouteTable.TableName}}: {{.RouteTable.DestinationCount}} destinations, " +
-"{{.RouteTable.TotalRouteCount}} routes ({{.RouteTable.ActiveRouteCount}}, " +
-"{{.RouteTable.HoldDownRouteCount}} holddown, {{.RouteTable.HiddenRouteCount}} hidden)\n" +
-"{{ if .RouteTable.RT }}" +
-"@ = Routing Use Only, # = Forwarding Use Only\n" +
-"+ = Active Route, - = Last Active, * = Both\n\n" +
-
-"{{range $_, $rt := .RouteTable.RT}}" +
-"{{$rt.RTDestination}}" +
-
-"{{range $i, $rtEntry := $rt.RTEntry}}" +
-"{{if eq $i 0}}      {{else}}                {{end}}{{$rtEntry.ActiveTag}}" +
-"[{{$rtEntry.ProtocolName}}/{{$rtEntry.Preference}}] {{$rtEntry.Age.AgeTime}}, " +
-"MED {{$rtEntry.Med}}, localpref {{$rtEntry.LocalPreference}}, " +
-"from {{$rtEntry.LearnedFrom}}\n" +
-"                  AS path: {{$rtEntry.AsPath}}, " +
-"validation-state: {{$rtEntry.ValidationState}}\n" +
-
-"{{range $_, $nh := $rtEntry.NH}}" +
-"                {{isNextHop $nh.SelectedNextHop}} to {{$nh.To}} via {{$nh.Via}}" +
-"{{if eq $nh.LSPName \"\"}}{{else}}, label-switched-path {{$nh.LSPName}}{{end}}\n" +
-
-"{{end}}{{end}}{{end}}{{end}}"
-
-func isNextHop(nextHopInd *string) string {
-	switch nextHopInd {
-	case nil:
-		return " "
-	default:
-		return ">"
+// onlyBGP reports whether the route-information reply p has no route
+// entries learned from protocols other than BGP, so that replies to "show
+// route protocol bgp" are read into a BGPRoute and any others into a
+// route.Route.
+func onlyBGP(p []byte) bool {
+	d := xml.NewDecoder(bytes.NewReader(p))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err == io.EOF
+		}
+
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "protocol-name" {
+			var name string
+			if err := d.DecodeElement(&name, &start); err != nil || strings.TrimSpace(name) != "BGP" {
+				return false
+			}
+		}
 	}
 }
 
-type NH struct {
-	// <selected-next-hop> is either present as an empty tag, or not present
-	// so we need a pointer to distinguish its presence (not nil), or lack thereof (nil)
-	SelectedNextHop *string `xml:"selected-next-hop"  json:"selected-next-hop"`
-	To              string  `xml:"to,omitempty"       json:"to,omitempty"`
-	Via             string  `xml:"via,omitempty"      json:"via,omitempty"`
-	LSPName         string  `xml:"lsp-name,omitempty" json:"lsp-name,omitempty"`
-}
-
-type Age struct {
-	AgeSecs string `xml:"seconds,attr" json:"age-seconds,omitempty"`
-	AgeTime string `xml:",chardata"    json:"age,omitempty"`
-}
-
-type RTEntry struct {
-	ActiveTag       string `xml:"active-tag,omitempty"       json:"active-date,omitempty"`
-	CurrentActive   string `xml:"current-active,omitempty"   json:"current-active,omitempty"`
-	LastActive      string `xml:"last-active,omitempty"      json:"last-active,omitempty"`
-	ProtocolName    string `xml:"protocol-name,omitempty"    json:"protocol-name,omitempty"`
-	Preference      int    `xml:"preference,omitempty"       json:"preference,omitempty"`
-	Age             Age    `xml:"age,omitempty"              json:"age,omitempty"`
-	Med             int    `xml:"med,omitempty"              json:"med,omitempty"`
-	LocalPreference int    `xml:"local-preference,omitempty" json:"local-preference,omitempty"`
-	LearnedFrom     string `xml:"learned-from,omitempty"     json:"learned-from,omitempty"`
-	AsPath          string `xml:"as-path,omitempty"          json:"as-path,omitempty"`
-	ValidationState string `xml:"validation-state,omitempty" json:"validation-state,omitempty"`
-	NH              []NH   `xml:"nh,omitempty"               json:"nh,omitempty"`
-}
-
-type RT struct {
-	RTDestination string    `xml:"rt-destination,omitempty" json:"rt-destination,omitempty"`
-	RTEntry       []RTEntry `xml:"rt-entry,omitempty"       json:"rt-entry,omitempty"`
-}
+// The route tables of a BGPRoute are those of the protocol-neutral
+// route.Route, so that BGP routes can be handled alongside any others.
+type (
+	NH         = route.NH
+	Age        = route.Age
+	RTEntry    = route.RTEntry
+	RT         = route.RT
+	RouteTable = route.RouteTable
+)
 
-type RouteTable struct {
-	TableName          string     `xml:"table-name,omitempty"           json:"table-name,omitempty"`
-	DestinationCount   int        `xml:"destination-count,omitempty"    json:"destination-count,omitempty"`
-	TotalRouteCount    int        `xml:"total-route-count,omitempty"    json:"total-route-count,omitempty"`
-	ActiveRouteCount   int        `xml:"active-route-count,omitempty"   json:"active-route-count,omitempty"`
-	HoldDownRouteCount int        `xml:"holddown-route-count"           json:"holddown-route-count"`
-	HiddenRouteCount   int        `xml:"hidden-route-count,omitempty"   json:"hidden-route-count,omitempty"`
-	RT                 []RT       `xml:"rt,omitempty"                   json:"rt,omitempty"`
+type RPCError = jresponse.RPCError
+
+// RTByFamily returns the routes of every table grouped by the address
+// family of their destination. Routes whose destination isn't a prefix
+// are left out.
+func (bgpRoute *BGPRoute) RTByFamily() map[jresponse.Family][]RT {
+	families := make(map[jresponse.Family][]RT)
+	for _, table := range bgpRoute.RouteTables {
+		for _, rt := range table.RT {
+			if prefix, err := rt.Prefix(); err == nil {
+				family := jresponse.AddrFamily(prefix.Addr())
+				families[family] = append(families[family], rt)
+			}
+		}
+	}
+	return families
 }
 
-type RPCError struct {
-	Type     string `xml:"error-type"     json:"error-type"`
-	Tag      string `xml:"error-tag"      json:"error-tag"`
-	Severity string `xml:"error-severity" json:"error-severity"`
-	Path     string `xml:"error-path"     json:"error-path"`
-	Message  string `xml:"error-message"  json:"error-message"`
-	Info     string `xml:",innerxml"      json:",string`
+// Validate checks the addresses and prefixes in the response, returning a
+// *jresponse.AddrError for each one that is malformed. Destinations are
+// only checked in inet and inet6 tables.
+func (bgpRoute *BGPRoute) Validate() error {
+	v := jresponse.AddrValidator{}
+	for i, table := range bgpRoute.RouteTables {
+		family := table.Family()
+		for j, rt := range table.RT {
+			rt.ValidateAddrs(&v, fmt.Sprintf("route-information/route-table[%d]/rt[%d]", i+1, j+1), family)
+		}
+	}
+	return v.Err()
 }
 
 // Represents the BGP route XML structure, and is used to convert it
-// from XML to JSON.
+// from XML to JSON. Junos returns one route table for each table with
+// matching routes, e.g. inet.0, inet6.0 and every VRF's inet.0.
 type BGPRoute struct {
-	XMLName    xml.Name   `xml:"route-information"     json:"-"`
-	RouteTable RouteTable `xml:"route-table,omitempty" json:"route-table,omitempty"`
-	Errors     []RPCError `xml:"rpc-error,omitempty"   json:"rpc-error,omitempty"`
-	OriginHost        string `json:"originhost,omitempty"`
-	OriginIP          string `json:"originip,omitempty"`	
+	XMLName     xml.Name        `xml:"route-information"           json:"-"`
+	JunosNS     jresponse.XMLNS `xml:"xmlns junos,attr,omitempty" json:"-"`
+	RouteTables []RouteTable    `xml:"route-table,omitempty"       json:"route-table,omitempty"`
+	Errors      []RPCError      `xml:"rpc-error,omitempty"         json:"rpc-error,omitempty"`
+	OriginHost  string          `xml:"-"                          json:"originhost,omitempty"`
+	OriginIP    string          `xml:"-"                          json:"originip,omitempty"`
 }
 
+// UnmarshalJSON reads a BGPRoute whose route-table is either an array of
+// tables, as WriteJSONTo writes it, or a single table object, as it was
+// written before multiple tables were supported.
+func (bgpRoute *BGPRoute) UnmarshalJSON(p []byte) error {
+	type bgpRouteJSON BGPRoute
+	v := struct {
+		*bgpRouteJSON
+		RouteTables json.RawMessage `json:"route-table,omitempty"`
+	}{bgpRouteJSON: (*bgpRouteJSON)(bgpRoute)}
+
+	if err := json.Unmarshal(p, &v); err != nil {
+		return err
+	}
 
-func (bgpRoute *BGPRoute) WriteXMLTo(w io.Writer) (n int64, err error) {
-	if s, err := xml.Marshal(bgpRoute); err != nil {
-		return 0, err
-	} else {
-		buf := bytes.NewBuffer(s)
-		return buf.WriteTo(w)
+	switch tables := bytes.TrimSpace(v.RouteTables); {
+	case len(tables) == 0, bytes.Equal(tables, []byte("null")):
+		return nil
+	case tables[0] == '{':
+		bgpRoute.RouteTables = make([]RouteTable, 1)
+		return json.Unmarshal(tables, &bgpRoute.RouteTables[0])
+	default:
+		return json.Unmarshal(tables, &bgpRoute.RouteTables)
 	}
 }
 
+func (bgpRoute *BGPRoute) WriteXMLTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteXML(w, bgpRoute, bgpRoute.XMLName)
+}
+
 func (bgpRoute *BGPRoute) WriteJSONTo(w io.Writer) (n int64, err error) {
 	if s, err := json.Marshal(bgpRoute); err != nil {
 		return 0, nil
@@ -145,8 +131,24 @@ func (bgpRoute *BGPRoute) WriteJSONTo(w io.Writer) (n int64, err error) {
 	}
 }
 
+var bgpRouteJSONTimeFields = jresponse.JSONTimeFields{
+	Durations: map[string]time.Duration{"age-seconds": time.Second},
+}
+
+// WriteJSONToWithOptions writes the same JSON as WriteJSONTo, with route
+// ages encoded as selected by opts.
+func (bgpRoute *BGPRoute) WriteJSONToWithOptions(w io.Writer, opts jresponse.JSONOptions) (n int64, err error) {
+	return jresponse.WriteJSONWithOptions(w, bgpRoute, bgpRouteJSONTimeFields, opts)
+}
+
+// WriteJunosJSONTo writes the routes in the JSON Junos encodes them as with
+// "| display json".
+func (bgpRoute *BGPRoute) WriteJunosJSONTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteJunosJSON(w, bgpRoute)
+}
+
 func (bgpRoute *BGPRoute) WriteCLITo(w io.Writer) error {
-	return bgpRouteTmpl.Execute(w, bgpRoute)
+	return (&route.Route{RouteTables: bgpRoute.RouteTables}).WriteCLITo(w)
 }
 
 func (bgpRoute *BGPRoute) ReadXMLFrom(r io.Reader) (n int64, err error) {
@@ -157,13 +159,15 @@ func (bgpRoute *BGPRoute) ReadXMLFrom(r io.Reader) (n int64, err error) {
 		return n, err
 	}
 
-	pNoNewlines := bytes.Replace(buf.Bytes(), []byte("\n"), []byte(""), -1)
+	if err := jresponse.UnmarshalReply(buf.Bytes(), bgpRoute, &bgpRoute.Errors); err != nil {
+		return n, err
+	}
 
-	if err := xml.Unmarshal(pNoNewlines, bgpRoute); err != nil {
+	if err := jresponse.CheckErrors(bgpRoute.Errors); err != nil {
 		return n, err
-	} else {
-		return n, nil
 	}
+
+	return n, bgpRoute.Validate()
 }
 
 func (bgpRoute *BGPRoute) ReadJSONFrom(r io.Reader) (n int64, err error) {
@@ -175,10 +179,21 @@ func (bgpRoute *BGPRoute) ReadJSONFrom(r io.Reader) (n int64, err error) {
 	} else if err = json.Unmarshal(buf.Bytes(), bgpRoute); err != nil {
 		return n, err
 	} else {
-		return n, nil
+		return n, bgpRoute.Validate()
 	}
 }
 
-// TODO:
-//func (bgpRoute *BGPRoute) ReadCLI(p []byte) (n int, err error) {
-//}
+// ReadJunosJSONFrom reads the routes from the JSON Junos encodes them as,
+// captured with "| display json" or from its REST API.
+func (bgpRoute *BGPRoute) ReadJunosJSONFrom(r io.Reader) (n int64, err error) {
+	return jresponse.ReadJunosJSONFrom(r, bgpRoute)
+}
+
+// ReadCLIFrom parses the text output of "show route protocol bgp", in the
+// format produced by WriteCLITo, into bgpRoute.
+func (bgpRoute *BGPRoute) ReadCLIFrom(r io.Reader) (n int64, err error) {
+	rt := &route.Route{RouteTables: bgpRoute.RouteTables}
+	n, err = rt.ReadCLIFrom(r)
+	bgpRoute.RouteTables = rt.RouteTables
+	return n, err
+}
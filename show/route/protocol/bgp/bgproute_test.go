@@ -3,9 +3,15 @@ package bgproute
 import (
 	"bytes"
 	"encoding/xml"
+	"errors"
+	"net/netip"
 	"os"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/show/route"
 )
 
 var (
@@ -13,15 +19,22 @@ var (
 )
 
 const (
-	BGP_XML_FILE  = "show_route_protocol_bgp.xml"
-	BGP_JSON_FILE = "show_route_protocol_bgp.json"
-	BGP_CLI_FILE  = "show_route_protocol_bgp.cli"
+	BGP_XML_FILE        = "show_route_protocol_bgp.xml"
+	BGP_JSON_FILE       = "show_route_protocol_bgp.json"
+	BGP_CLI_FILE        = "show_route_protocol_bgp.cli"
+	BGP_JUNOS_JSON_FILE = "show_route_protocol_bgp_junos_synthetic.json"
+
+	BGP_TABLES_XML_FILE = "show_route_protocol_bgp_tables.xml"
+	BGP_TABLES_CLI_FILE = "show_route_protocol_bgp_tables.cli"
+
+	BGP_DETAIL_XML_FILE = "show_route_protocol_bgp_detail.xml"
+	BGP_DETAIL_CLI_FILE = "show_route_protocol_bgp_detail.cli"
 )
 
 func initBGPRouteModel() {
 	bgpRouteXMLModel = &BGPRoute{
-		XMLName: xml.Name{"http://xml.juniper.net/junos/12.3R6/junos-routing", "route-information"},
-		RouteTable: RouteTable{
+		XMLName: xml.Name{Space: "http://xml.juniper.net/junos/12.3R6/junos-routing", Local: "route-information"},
+		RouteTables: []RouteTable{{
 			TableName:          "inet.0",
 			DestinationCount:   565525,
 			TotalRouteCount:    4400004,
@@ -30,6 +43,7 @@ func initBGPRouteModel() {
 			HiddenRouteCount:   14,
 			RT: []RT{
 				{
+					Style:         "brief-mpls-fwd",
 					RTDestination: "8.8.8.0/24",
 					RTEntry: []RTEntry{
 						{
@@ -126,12 +140,12 @@ func initBGPRouteModel() {
 					},
 				},
 			},
-		},
+		}},
 	}
 
 	bgpRouteJSONModel = &BGPRoute{
 		XMLName: xml.Name{},
-		RouteTable: RouteTable{
+		RouteTables: []RouteTable{{
 			TableName:          "inet.0",
 			DestinationCount:   565525,
 			TotalRouteCount:    4400004,
@@ -236,7 +250,7 @@ func initBGPRouteModel() {
 					},
 				},
 			},
-		},
+		}},
 	}
 }
 
@@ -275,6 +289,73 @@ func TestReadJSONFrom(t *testing.T) {
 	}
 }
 
+func TestReadCLIFrom(t *testing.T) {
+
+	b := new(BGPRoute)
+
+	// the CLI output carries no namespace and doesn't tell the brief
+	// styles apart, everything else should match
+	cliModel := *bgpRouteXMLModel
+	cliModel.XMLName = xml.Name{}
+	cliModel.RouteTables = []RouteTable{bgpRouteXMLModel.RouteTables[0]}
+	cliModel.RouteTables[0].RT = []RT{bgpRouteXMLModel.RouteTables[0].RT[0]}
+	cliModel.RouteTables[0].RT[0].Style = route.StyleBrief
+
+	if file, err := os.Open(BGP_CLI_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := b.ReadCLIFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(b, &cliModel) {
+		t.Log(&cliModel)
+		t.Log(b)
+		t.Error("parsed CLI does not match BGP route model")
+	}
+}
+
+func TestReadCLIFromInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"8.8.8.0/24      *[BGP/170] 6d 18:32:08, MED 0, localpref 130, from 206.126.239.251\n",
+		"inet.0: 1 destinations, 1 routes (1 active, 0 holddown, 0 hidden)\n" +
+			"8.8.8.0/24      *[BGP/170] 6d 18:32:08, MED 0, localpref 130, from 206.126.239.251\n" +
+			"                > to 206.126.236.21\n",
+		"inet.0: 1 destinations, 1 routes (1 active, 0 holddown, 0 hidden)\n" +
+			"8.8.8.0/24      *[BGP/170] yesterday, MED 0\n",
+		"inet.0: 1 destinations, 1 routes (1 active, 0 holddown, 0 hidden)\n" +
+			"8.8.8.0/24 (1 entry, 1 announced)\n" +
+			"                State: <Active Ext>\n",
+		"inet.0: 1 destinations, 1 routes (1 active, 0 holddown, 0 hidden)\n" +
+			"8.8.8.0/24 (1 entry, 1 announced)\n" +
+			"        *BGP    Preference: 170/-131\n" +
+			"                Local AS: 7922 Peer AS: Google\n",
+		"inet.0: 1 destinations, 1 routes (1 active, 0 holddown, 0 hidden)\n" +
+			"8.8.8.0/24 (1 entry, 1 announced)\n" +
+			"        *BGP    Preference: 170/-131\n" +
+			"                Router ID: 72.14.236.300\n",
+	}
+
+	for i, input := range inputs {
+		if _, err := new(BGPRoute).ReadCLIFrom(bytes.NewBufferString(input)); err == nil {
+			t.Errorf("input %d: expected an error", i)
+		}
+	}
+}
+
+func TestAgeDuration(t *testing.T) {
+	ages := []Age{
+		{AgeSecs: "762247", AgeTime: "1w1d 19:44:07"},
+		{AgeTime: "1w1d 19:44:07"},
+	}
+
+	for _, age := range ages {
+		if d, err := age.Duration(); err != nil {
+			t.Error(err)
+		} else if d != 762247*time.Second {
+			t.Errorf("Duration() = %v, should be %v", d, 762247*time.Second)
+		}
+	}
+}
+
 func TestWriteCLITo(t *testing.T) {
 
 	modelBuf := bytes.Buffer{}
@@ -305,12 +386,55 @@ func TestWriteCLITo(t *testing.T) {
 	}
 }
 
-func TestIsNextHop(t *testing.T) {
-	testStr := ""
-	if str := isNextHop(nil); str != " " {
-		t.Error("did not return a space when passed a nil pointer")
-	} else if str := isNextHop(&testStr); str != ">" {
-		t.Error(`did not return '>' when passing empty string`)
+func TestMultipleRouteTables(t *testing.T) {
+
+	xmlRoute, cliRoute := new(BGPRoute), new(BGPRoute)
+
+	if file, err := os.Open(BGP_TABLES_XML_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := xmlRoute.ReadXMLFrom(file); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, table := range xmlRoute.RouteTables {
+		names = append(names, table.TableName)
+	}
+	if !reflect.DeepEqual(names, []string{"inet.0", "inet6.0", "CUST-A.inet.0"}) {
+		t.Errorf("unexpected route tables %v", names)
+	}
+
+	fileBuf, modelBuf := bytes.Buffer{}, bytes.Buffer{}
+	if file, err := os.Open(BGP_TABLES_CLI_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := xmlRoute.WriteCLITo(&modelBuf); err != nil {
+		t.Error(err)
+	} else if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(modelBuf.String())
+		t.Error("CLI output for multiple route tables does not match")
+	}
+
+	xmlRoute.XMLName = xml.Name{}
+	if _, err := cliRoute.ReadCLIFrom(&fileBuf); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(cliRoute, xmlRoute) {
+		t.Log(xmlRoute)
+		t.Log(cliRoute)
+		t.Error("parsed CLI does not match the XML route tables")
+	}
+
+	jsonBuf := bytes.Buffer{}
+	jsonRoute := new(BGPRoute)
+	if _, err := xmlRoute.WriteJSONTo(&jsonBuf); err != nil {
+		t.Error(err)
+	} else if _, err := jsonRoute.ReadJSONFrom(&jsonBuf); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(jsonRoute, xmlRoute) {
+		t.Error("route tables did not survive a JSON round trip")
 	}
 }
 
@@ -366,3 +490,156 @@ func TestWriteJSONTo(t *testing.T) {
 		t.Error("JSON bytes not equal")
 	}
 }
+
+func TestDetail(t *testing.T) {
+
+	xmlRoute, cliRoute := new(BGPRoute), new(BGPRoute)
+
+	if file, err := os.Open(BGP_DETAIL_XML_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := xmlRoute.ReadXMLFrom(file); err != nil {
+		t.Fatal(err)
+	}
+
+	fileBuf, modelBuf := bytes.Buffer{}, bytes.Buffer{}
+	if file, err := os.Open(BGP_DETAIL_CLI_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := xmlRoute.WriteCLITo(&modelBuf); err != nil {
+		t.Error(err)
+	} else if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(modelBuf.String())
+		t.Error("detail CLI output does not match")
+	}
+
+	xmlRoute.XMLName, xmlRoute.JunosNS = xml.Name{}, ""
+	if _, err := cliRoute.ReadCLIFrom(&fileBuf); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(cliRoute, xmlRoute) {
+		t.Log(xmlRoute)
+		t.Log(cliRoute)
+		t.Error("parsed detail CLI does not match the XML route")
+	}
+
+	rt := xmlRoute.RouteTables[0].RT[0]
+	active, inactive := rt.RTEntry[0], rt.RTEntry[1]
+
+	if flags := active.StateFlags(); !reflect.DeepEqual(flags, []string{"Active", "Ext"}) {
+		t.Errorf("StateFlags() = %v", flags)
+	} else if inactive.InactiveReason != "Local Preference" {
+		t.Errorf("unexpected inactive reason %q", inactive.InactiveReason)
+	}
+
+	if c := active.Communities; c == nil || len(c.Community) != 2 || len(c.ExtendedCommunity) != 1 ||
+		len(c.LargeCommunity) != 1 {
+		t.Errorf("unexpected communities %+v", c)
+	} else if s := c.String(); s != "7922:1000 no-export target:7922:1 large:7922:1:2" {
+		t.Errorf("String() = %q", s)
+	}
+
+	if pnh := inactive.ProtocolNH; len(pnh) != 1 || len(pnh[0].NH) != 2 || pnh[0].NH[1].Via != "ae4.0" {
+		t.Errorf("unexpected protocol next hops %+v", pnh)
+	} else if inactive.OriginatorID != "69.73.0.140" || inactive.ClusterList != "69.73.0.1 69.73.0.2" {
+		t.Errorf("unexpected reflection attributes %+v", inactive)
+	}
+
+	// extensive replies render like detail ones
+	if rt.Style = route.StyleExtensive; rt.CLIStyle() != route.StyleDetail {
+		t.Errorf("CLIStyle() = %s for an extensive route", rt.CLIStyle())
+	} else if rt.Style = "brief-mpls-fwd"; rt.CLIStyle() != route.StyleBrief {
+		t.Errorf("CLIStyle() = %s for a brief route", rt.CLIStyle())
+	}
+
+	xmlRoute.RouteTables[0].RT[0].RTEntry[1].OriginatorID = "69.73.0.300"
+	var addrErr *jresponse.AddrError
+	if err := xmlRoute.Validate(); !errors.As(err, &addrErr) {
+		t.Errorf("expected an AddrError, got %v", err)
+	} else if addrErr.Path != "route-information/route-table[1]/rt[1]/rt-entry[2]/originator-id" {
+		t.Errorf("unexpected path %s", addrErr.Path)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	b := new(BGPRoute)
+	if file, err := os.Open(BGP_TABLES_XML_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := b.ReadXMLFrom(file); err != nil {
+		t.Fatal(err)
+	}
+
+	families := []jresponse.Family{jresponse.FamilyInet, jresponse.FamilyInet6, jresponse.FamilyInet}
+	for i, table := range b.RouteTables {
+		if f := table.Family(); f != families[i] {
+			t.Errorf("%s: Family() = %q, should be %q", table.TableName, f, families[i])
+		}
+	}
+
+	rt := b.RouteTables[1].RT[0]
+	if prefix, err := rt.Prefix(); err != nil || prefix != netip.MustParsePrefix("2001:4860::/32") {
+		t.Errorf("Prefix() = %v, %v", prefix, err)
+	} else if addr, err := rt.RTEntry[0].LearnedFromAddr(); err != nil || !addr.Is6() {
+		t.Errorf("LearnedFromAddr() = %v, %v", addr, err)
+	} else if addr, err := rt.RTEntry[0].NH[0].ToAddr(); err != nil || !addr.Is6() {
+		t.Errorf("ToAddr() = %v, %v", addr, err)
+	}
+
+	byFamily := b.RTByFamily()
+	if len(byFamily[jresponse.FamilyInet6]) != len(b.RouteTables[1].RT) ||
+		len(byFamily[jresponse.FamilyInet]) != len(b.RouteTables[0].RT)+len(b.RouteTables[2].RT) {
+		t.Errorf("unexpected routes by family %v", byFamily)
+	}
+
+	// destinations of other families aren't prefixes, and aren't checked
+	vpn := BGPRoute{RouteTables: []RouteTable{{TableName: "bgp.l3vpn.0", RT: []RT{{RTDestination: "65000:1:10.0.0.0/24"}}}}}
+	if err := vpn.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	vpn.RouteTables[0].TableName = "CUST-A.inet.0"
+	var addrErr *jresponse.AddrError
+	if err := vpn.Validate(); !errors.As(err, &addrErr) {
+		t.Errorf("expected an AddrError, got %v", err)
+	} else if addrErr.Path != "route-information/route-table[1]/rt[1]/rt-destination" {
+		t.Errorf("unexpected path %s", addrErr.Path)
+	}
+}
+
+func TestSortRT(t *testing.T) {
+	table := RouteTable{RT: []RT{
+		{RTDestination: "8.8.8.0/24"},
+		{RTDestination: "label"},
+		{RTDestination: "10.0.0.0/24"},
+		{RTDestination: "10.0.0.0/8"},
+		{RTDestination: "1.0.0.0/24"},
+	}}
+
+	table.SortRT()
+
+	var sorted []string
+	for _, rt := range table.RT {
+		sorted = append(sorted, rt.RTDestination)
+	}
+
+	expected := []string{"1.0.0.0/24", "8.8.8.0/24", "10.0.0.0/8", "10.0.0.0/24", "label"}
+	if !reflect.DeepEqual(sorted, expected) {
+		t.Errorf("sorted routes %v, should be %v", sorted, expected)
+	}
+}
+
+func TestReadJunosJSONFrom(t *testing.T) {
+
+	b := new(BGPRoute)
+
+	if file, err := os.Open(BGP_JUNOS_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := b.ReadJunosJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(b, bgpRouteXMLModel) {
+		t.Log(bgpRouteXMLModel)
+		t.Log(b)
+		t.Error("unmarshalled Junos JSON does not match the BGP route xml model")
+	}
+}
@@ -0,0 +1,356 @@
+package bgproute
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/JReyLBC/jresponse"
+	"github.com/JReyLBC/jresponse/show/route"
+)
+
+// Decoder reads a route-information reply one route at a time, so that
+// full Internet tables can be converted without holding them in memory.
+// It is used like a bufio.Scanner:
+//
+//	dec := NewDecoder(r)
+//	for dec.Next() {
+//		if rt := dec.RT(); rt != nil {
+//			// a route in dec.Table()
+//		} else {
+//			// the start of dec.Table()
+//		}
+//	}
+//	if err := dec.Err(); err != nil {
+//		...
+//	}
+type Decoder struct {
+	d       *xml.Decoder
+	table   *RouteTable
+	rt      *RT
+	inTable bool
+	tables  int
+	rts     int
+	pending *xml.StartElement
+	errors  []RPCError
+	err     error
+	done    bool
+}
+
+// NewDecoder returns a Decoder reading a route-information reply, with or
+// without an rpc-reply envelope, from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{d: jresponse.NewDecoder(r)}
+}
+
+// Next advances to the next route table or route, returning false when
+// the reply is exhausted or an error occurs.
+func (dec *Decoder) Next() bool {
+	if dec.done {
+		return false
+	}
+
+	dec.rt = nil
+
+	for {
+		var start xml.StartElement
+
+		if dec.pending != nil {
+			start, dec.pending = *dec.pending, nil
+		} else {
+			tok, err := dec.d.Token()
+			if err == io.EOF {
+				return dec.finish(jresponse.CheckErrors(dec.errors))
+			} else if err != nil {
+				return dec.finish(err)
+			}
+
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				start = tok
+			case xml.EndElement:
+				if tok.Name.Local == "route-table" && dec.inTable {
+					dec.inTable = false
+					// a table without routes is yielded when it ends
+					if dec.table.RT == nil {
+						dec.table.RT = []RT{}
+						return true
+					}
+				}
+				continue
+			default:
+				continue
+			}
+		}
+
+		switch {
+		case start.Name.Local == "rpc-reply", start.Name.Local == "route-information":
+			// descend into the envelope and response
+
+		case start.Name.Local == "rpc-error":
+			var e RPCError
+			if err := dec.d.DecodeElement(&e, &start); err != nil {
+				return dec.finish(err)
+			}
+			dec.errors = append(dec.errors, e)
+
+		case start.Name.Local == "route-table":
+			dec.table, dec.inTable = new(RouteTable), true
+			dec.tables, dec.rts = dec.tables+1, 0
+
+		case dec.inTable && start.Name.Local == "rt":
+			// the header is complete at the first route, so yield the
+			// table and decode the route on the following call
+			if dec.table.RT == nil {
+				dec.table.RT = []RT{}
+				dec.pending = &start
+				return true
+			}
+
+			rt := new(RT)
+			if err := dec.d.DecodeElement(rt, &start); err != nil {
+				return dec.finish(err)
+			}
+
+			dec.rts++
+			v := jresponse.AddrValidator{}
+			rt.ValidateAddrs(&v, fmt.Sprintf("route-information/route-table[%d]/rt[%d]", dec.tables, dec.rts), dec.table.Family())
+			if err := v.Err(); err != nil {
+				return dec.finish(err)
+			}
+			dec.rt = rt
+			return true
+
+		case dec.inTable:
+			var value string
+			if err := dec.d.DecodeElement(&value, &start); err != nil {
+				return dec.finish(err)
+			}
+			if err := setHeaderField(dec.table, start.Name.Local, value); err != nil {
+				return dec.finish(err)
+			}
+
+		default:
+			if err := dec.d.Skip(); err != nil {
+				return dec.finish(err)
+			}
+		}
+	}
+}
+
+func (dec *Decoder) finish(err error) bool {
+	dec.done, dec.err, dec.rt = true, err, nil
+	return false
+}
+
+// Table returns the route table being decoded. Its RT field is always
+// empty; routes are returned one at a time by RT.
+func (dec *Decoder) Table() *RouteTable {
+	if dec.table == nil {
+		return nil
+	}
+
+	table := *dec.table
+	table.RT = nil
+	return &table
+}
+
+// RT returns the route decoded by the last call to Next, or nil when Next
+// stopped at the start of a new route table.
+func (dec *Decoder) RT() *RT {
+	return dec.rt
+}
+
+// Err returns the first error encountered while decoding, including the
+// rpc-errors reported by the device once the reply has been read.
+func (dec *Decoder) Err() error {
+	return dec.err
+}
+
+// Errors returns the rpc-errors, including warnings, read so far.
+func (dec *Decoder) Errors() []RPCError {
+	return dec.errors
+}
+
+// setHeaderField sets the field of table for the child element name.
+func setHeaderField(table *RouteTable, name, value string) (err error) {
+	value = strings.TrimSpace(value)
+
+	var count *int
+	switch name {
+	case "table-name":
+		table.TableName = value
+	case "destination-count":
+		count = &table.DestinationCount
+	case "total-route-count":
+		count = &table.TotalRouteCount
+	case "active-route-count":
+		count = &table.ActiveRouteCount
+	case "holddown-route-count":
+		count = &table.HoldDownRouteCount
+	case "hidden-route-count":
+		count = &table.HiddenRouteCount
+	}
+
+	if count != nil {
+		*count, err = strconv.Atoi(value)
+	}
+	return err
+}
+
+// RouteWriter writes route tables and their routes as they are decoded.
+// WriteRT writes a route into the table last passed to WriteRouteTable.
+type RouteWriter interface {
+	WriteRouteTable(table *RouteTable) error
+	WriteRT(rt *RT) error
+	Close() error
+}
+
+// Copy streams every route table and route from dec to rw, and closes rw.
+// It returns the number of routes copied.
+func Copy(rw RouteWriter, dec *Decoder) (routes int, err error) {
+	for dec.Next() {
+		if rt := dec.RT(); rt != nil {
+			err = rw.WriteRT(rt)
+			routes++
+		} else {
+			err = rw.WriteRouteTable(dec.Table())
+		}
+
+		if err != nil {
+			return routes, err
+		}
+	}
+
+	if err = rw.Close(); err != nil {
+		return routes, err
+	}
+	return routes, dec.Err()
+}
+
+// CLIWriter streams routes in the format of BGPRoute.WriteCLITo.
+type CLIWriter struct {
+	w             io.Writer
+	tables        int
+	legendPending bool
+}
+
+func NewCLIWriter(w io.Writer) *CLIWriter {
+	return &CLIWriter{w: w}
+}
+
+func (cw *CLIWriter) WriteRouteTable(table *RouteTable) error {
+	if cw.tables > 0 {
+		if _, err := io.WriteString(cw.w, "\n"); err != nil {
+			return err
+		}
+	}
+	cw.tables++
+	cw.legendPending = true
+
+	return table.WriteCLIHeaderTo(cw.w)
+}
+
+func (cw *CLIWriter) WriteRT(rt *RT) error {
+	// the detail styles carry their state in each entry, not in a legend
+	if cw.legendPending {
+		cw.legendPending = false
+		if rt.CLIStyle() == route.StyleBrief {
+			if err := route.WriteCLILegendTo(cw.w); err != nil {
+				return err
+			}
+		}
+	}
+
+	return rt.WriteCLITo(cw.w)
+}
+
+func (cw *CLIWriter) Close() error {
+	return nil
+}
+
+// JSONWriter streams routes in the format of BGPRoute.WriteJSONTo.
+type JSONWriter struct {
+	w      io.Writer
+	tables int
+	routes int
+}
+
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w}
+}
+
+// closeTable ends the JSON object of the current route table.
+func (jw *JSONWriter) closeTable() (err error) {
+	if jw.routes > 0 {
+		_, err = io.WriteString(jw.w, "]}")
+	} else {
+		_, err = io.WriteString(jw.w, "}")
+	}
+	return err
+}
+
+func (jw *JSONWriter) WriteRouteTable(table *RouteTable) error {
+	header := *table
+	header.RT = nil
+
+	s, err := json.Marshal(&header)
+	if err != nil {
+		return err
+	}
+
+	// leave the table object open for its routes
+	s = bytes.TrimSuffix(s, []byte("}"))
+
+	prefix := `{"route-table":[`
+	if jw.tables > 0 {
+		if err := jw.closeTable(); err != nil {
+			return err
+		}
+		prefix = ","
+	}
+	jw.tables++
+	jw.routes = 0
+
+	if _, err := io.WriteString(jw.w, prefix); err != nil {
+		return err
+	}
+	_, err = jw.w.Write(s)
+	return err
+}
+
+func (jw *JSONWriter) WriteRT(rt *RT) error {
+	s, err := json.Marshal(rt)
+	if err != nil {
+		return err
+	}
+
+	prefix := ","
+	if jw.routes == 0 {
+		prefix = `,"rt":[`
+	}
+	jw.routes++
+
+	if _, err := io.WriteString(jw.w, prefix); err != nil {
+		return err
+	}
+	_, err = jw.w.Write(s)
+	return err
+}
+
+func (jw *JSONWriter) Close() error {
+	if jw.tables == 0 {
+		_, err := io.WriteString(jw.w, "{}")
+		return err
+	}
+
+	if err := jw.closeTable(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(jw.w, "]}")
+	return err
+}
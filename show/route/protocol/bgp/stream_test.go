@@ -0,0 +1,384 @@
+package bgproute
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+// decodeStream collects everything a Decoder yields back into a BGPRoute.
+func decodeStream(r io.Reader) (*BGPRoute, error) {
+	b := new(BGPRoute)
+	dec := NewDecoder(r)
+
+	for dec.Next() {
+		if rt := dec.RT(); rt != nil {
+			table := &b.RouteTables[len(b.RouteTables)-1]
+			table.RT = append(table.RT, *rt)
+		} else {
+			b.RouteTables = append(b.RouteTables, *dec.Table())
+		}
+	}
+
+	b.Errors = dec.Errors()
+	return b, dec.Err()
+}
+
+func TestDecoder(t *testing.T) {
+
+	for _, name := range []string{BGP_XML_FILE, BGP_TABLES_XML_FILE} {
+		model := new(BGPRoute)
+		if file, err := os.Open(name); err != nil {
+			t.Fatal(err)
+		} else if _, err := model.ReadXMLFrom(file); err != nil {
+			t.Fatal(err)
+		}
+		model.XMLName = xml.Name{}
+
+		if file, err := os.Open(name); err != nil {
+			t.Fatal(err)
+		} else if b, err := decodeStream(file); err != nil {
+			t.Error(err)
+		} else if !reflect.DeepEqual(b, model) {
+			t.Log(model)
+			t.Log(b)
+			t.Errorf("%s: streamed routes do not match ReadXMLFrom", name)
+		}
+	}
+}
+
+func TestDecoderRPCError(t *testing.T) {
+	reply := `<rpc-reply message-id="1">
+<route-information xmlns="http://xml.juniper.net/junos/12.3R6/junos-routing">
+<route-table><table-name>inet.0</table-name><holddown-route-count>0</holddown-route-count></route-table>
+</route-information>
+<rpc-error><error-severity>error</error-severity><error-message>timeout</error-message></rpc-error>
+</rpc-reply>`
+
+	b, err := decodeStream(bytes.NewBufferString(reply))
+
+	var rpcErr *jresponse.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Message != "timeout" {
+		t.Errorf("expected the rpc-error, got %v", err)
+	} else if len(b.RouteTables) != 1 || b.RouteTables[0].TableName != "inet.0" {
+		t.Errorf("unexpected route tables %+v", b.RouteTables)
+	}
+
+	if _, err := decodeStream(bytes.NewBufferString("<route-information><route-table>")); err == nil {
+		t.Error("expected an error for a truncated reply")
+	}
+
+	malformed := `<route-information><route-table><table-name>inet.0</table-name>
+<rt><rt-destination>10.0.0.0/24</rt-destination></rt>
+<rt><rt-destination>10.0.0.0/24</rt-destination><rt-entry><nh><to>10.0.0.300</to></nh></rt-entry></rt>
+</route-table></route-information>`
+
+	var addrErr *jresponse.AddrError
+	if _, err := decodeStream(bytes.NewBufferString(malformed)); !errors.As(err, &addrErr) {
+		t.Errorf("expected an AddrError, got %v", err)
+	} else if addrErr.Path != "route-information/route-table[1]/rt[2]/rt-entry[1]/nh[1]/to" {
+		t.Errorf("unexpected path %s", addrErr.Path)
+	}
+}
+
+func TestCopy(t *testing.T) {
+
+	for _, name := range []string{BGP_XML_FILE, BGP_TABLES_XML_FILE, BGP_DETAIL_XML_FILE} {
+		b := new(BGPRoute)
+		if file, err := os.Open(name); err != nil {
+			t.Fatal(err)
+		} else if _, err := b.ReadXMLFrom(file); err != nil {
+			t.Fatal(err)
+		}
+
+		cliBuf, jsonBuf := bytes.Buffer{}, bytes.Buffer{}
+		if err := b.WriteCLITo(&cliBuf); err != nil {
+			t.Fatal(err)
+		} else if _, err := b.WriteJSONTo(&jsonBuf); err != nil {
+			t.Fatal(err)
+		}
+
+		writers := map[string]func(io.Writer) RouteWriter{
+			"CLI":  func(w io.Writer) RouteWriter { return NewCLIWriter(w) },
+			"JSON": func(w io.Writer) RouteWriter { return NewJSONWriter(w) },
+		}
+		expected := map[string][]byte{"CLI": cliBuf.Bytes(), "JSON": jsonBuf.Bytes()}
+
+		for format, newWriter := range writers {
+			out := bytes.Buffer{}
+			file, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if routes, err := Copy(newWriter(&out), NewDecoder(file)); err != nil {
+				t.Error(err)
+			} else if routes != countRoutes(b) {
+				t.Errorf("%s %s: copied %d routes, expected %d", name, format, routes, countRoutes(b))
+			} else if !bytes.Equal(out.Bytes(), expected[format]) {
+				t.Log(out.String())
+				t.Errorf("%s: streamed %s does not match the buffered writer", name, format)
+			}
+			file.Close()
+		}
+	}
+
+	empty := bytes.Buffer{}
+	if _, err := Copy(NewJSONWriter(&empty), NewDecoder(bytes.NewBufferString("<route-information/>"))); err != nil {
+		t.Error(err)
+	} else if empty.String() != "{}" {
+		t.Errorf("unexpected JSON for an empty reply %q", empty.String())
+	}
+}
+
+func countRoutes(b *BGPRoute) (routes int) {
+	for _, table := range b.RouteTables {
+		routes += len(table.RT)
+	}
+	return routes
+}
+
+// replyReader generates a route-information reply with the given number
+// of routes, each shaped like the entries in the fixture, as it is read, so
+// that the reply itself takes no memory however many routes it has.
+type replyReader struct {
+	routes, next int
+	buf          bytes.Buffer
+	done         bool
+}
+
+func newReplyReader(routes int) *replyReader {
+	r := &replyReader{routes: routes}
+	r.buf.WriteString(`<route-information xmlns="http://xml.juniper.net/junos/12.3R6/junos-routing">
+    <route-table>
+        <table-name>inet.0</table-name>
+        <destination-count>` + fmt.Sprint(routes) + `</destination-count>
+        <total-route-count>` + fmt.Sprint(routes) + `</total-route-count>
+        <active-route-count>` + fmt.Sprint(routes) + `</active-route-count>
+        <holddown-route-count>0</holddown-route-count>
+        <hidden-route-count>0</hidden-route-count>
+`)
+	return r
+}
+
+func (r *replyReader) Read(p []byte) (int, error) {
+	for r.buf.Len() < len(p) && !r.done {
+		if r.next == r.routes {
+			r.buf.WriteString("    </route-table>\n</route-information>\n")
+			r.done = true
+			break
+		}
+
+		i := r.next
+		r.next++
+		fmt.Fprintf(&r.buf, `        <rt junos:style="brief">
+            <rt-destination>%d.%d.%d.0/24</rt-destination>
+            <rt-entry>
+                <active-tag>*</active-tag>
+                <protocol-name>BGP</protocol-name>
+                <preference>170</preference>
+                <age junos:seconds="585128">6d 18:32:08</age>
+                <med>0</med>
+                <local-preference>130</local-preference>
+                <learned-from>206.126.239.251</learned-from>
+                <as-path>15169 I</as-path>
+                <validation-state>unverified</validation-state>
+                <nh>
+                    <selected-next-hop/>
+                    <to>206.126.236.21</to>
+                    <via>ae0.0</via>
+                </nh>
+            </rt-entry>
+        </rt>
+`, i>>16+1, i>>8&0xff, i&0xff)
+	}
+	return r.buf.Read(p)
+}
+
+// liveHeap returns the bytes of heap in use by reachable objects.
+func liveHeap() uint64 {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// heapSampler is a RouteWriter recording the peak live heap, sampled every
+// sampleEvery routes, while routes are copied to the writer it wraps.
+type heapSampler struct {
+	RouteWriter
+	sampleEvery int
+	routes      int
+	base, peak  uint64
+}
+
+func newHeapSampler(rw RouteWriter, sampleEvery int) *heapSampler {
+	return &heapSampler{RouteWriter: rw, sampleEvery: sampleEvery, base: liveHeap()}
+}
+
+func (hs *heapSampler) WriteRT(rt *RT) error {
+	if hs.routes++; hs.routes%hs.sampleEvery == 0 {
+		if heap := liveHeap(); heap > hs.peak {
+			hs.peak = heap
+		}
+	}
+	return hs.RouteWriter.WriteRT(rt)
+}
+
+// Peak returns the peak live heap above the one when hs was created.
+func (hs *heapSampler) Peak() uint64 {
+	if hs.peak < hs.base {
+		return 0
+	}
+	return hs.peak - hs.base
+}
+
+func TestCopyBoundedMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("copies tens of thousands of routes")
+	}
+
+	peaks := map[int]uint64{}
+	for _, routes := range []int{2000, 20000} {
+		hs := newHeapSampler(NewJSONWriter(io.Discard), 500)
+		if copied, err := Copy(hs, NewDecoder(newReplyReader(routes))); err != nil {
+			t.Fatal(err)
+		} else if copied != routes {
+			t.Fatalf("copied %d routes, expected %d", copied, routes)
+		}
+		peaks[routes] = hs.Peak()
+	}
+
+	// buffering 18,000 more routes would take tens of megabytes
+	if peaks[20000] > peaks[2000]+1<<20 {
+		t.Errorf("peak live heap grew from %d bytes for 2,000 routes to %d bytes for 20,000",
+			peaks[2000], peaks[20000])
+	}
+
+	// unmarshalling holds every route once the reply is read
+	base := liveHeap()
+	route, err := unmarshalReply(newReplyReader(20000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unmarshalled uint64
+	if heap := liveHeap(); heap > base {
+		unmarshalled = heap - base
+	}
+	runtime.KeepAlive(route)
+
+	t.Logf("peak live heap: Copy %d bytes for 2,000 routes, %d bytes for 20,000; unmarshalling %d bytes for 20,000",
+		peaks[2000], peaks[20000], unmarshalled)
+}
+
+// reportPerRoute reports the allocations of each iteration divided among
+// its routes, which stay the same however many routes a reply has when
+// nothing is held across routes.
+func reportPerRoute(b *testing.B, routes int, before *runtime.MemStats) {
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	n := float64(b.N) * float64(routes)
+	b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/n, "B/route")
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/n, "allocs/route")
+}
+
+// benchmarkRoutes are the reply sizes benchmarked, up to a full Internet
+// table. Only the streaming path is run with a million routes, as buffering
+// them takes gigabytes. The peak heap of each path is measured by
+// TestCopyBoundedMemory, outside the timed loops.
+var benchmarkRoutes = []int{10000, 100000, 1000000}
+
+const bufferedBenchmarkRoutes = 100000
+
+// unmarshalReply reads a reply the way ReadXMLFrom did before Decoder was
+// added: the whole reply is buffered, copied again without its newlines
+// and unmarshalled at once.
+func unmarshalReply(r io.Reader) (*BGPRoute, error) {
+	buf := bytes.Buffer{}
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	route := new(BGPRoute)
+	pNoNewlines := bytes.Replace(buf.Bytes(), []byte("\n"), []byte(""), -1)
+	return route, xml.Unmarshal(pNoNewlines, route)
+}
+
+// benchmarkUnmarshal unmarshals each reply into a BGPRoute and writes it
+// out.
+func benchmarkUnmarshal(b *testing.B, write func(*BGPRoute) error) {
+	for _, routes := range benchmarkRoutes {
+		if routes > bufferedBenchmarkRoutes {
+			continue
+		}
+
+		b.Run(fmt.Sprintf("routes=%d", routes), func(b *testing.B) {
+			var before runtime.MemStats
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				route, err := unmarshalReply(newReplyReader(routes))
+				if err != nil {
+					b.Fatal(err)
+				} else if err := write(route); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.StopTimer()
+			reportPerRoute(b, routes, &before)
+		})
+	}
+}
+
+// benchmarkCopy streams each reply to the RouteWriter returned by
+// newWriter.
+func benchmarkCopy(b *testing.B, newWriter func(io.Writer) RouteWriter) {
+	for _, routes := range benchmarkRoutes {
+		b.Run(fmt.Sprintf("routes=%d", routes), func(b *testing.B) {
+			var before runtime.MemStats
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := Copy(newWriter(io.Discard), NewDecoder(newReplyReader(routes))); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.StopTimer()
+			reportPerRoute(b, routes, &before)
+		})
+	}
+}
+
+func BenchmarkUnmarshalWriteJSONTo(b *testing.B) {
+	benchmarkUnmarshal(b, func(route *BGPRoute) error {
+		_, err := route.WriteJSONTo(io.Discard)
+		return err
+	})
+}
+
+func BenchmarkCopyJSON(b *testing.B) {
+	benchmarkCopy(b, func(w io.Writer) RouteWriter { return NewJSONWriter(w) })
+}
+
+func BenchmarkUnmarshalWriteCLITo(b *testing.B) {
+	benchmarkUnmarshal(b, func(route *BGPRoute) error {
+		return route.WriteCLITo(io.Discard)
+	})
+}
+
+func BenchmarkCopyCLI(b *testing.B) {
+	benchmarkCopy(b, func(w io.Writer) RouteWriter { return NewCLIWriter(w) })
+}
@@ -0,0 +1,1100 @@
+package route
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	tmpl "text/template"
+	"time"
+
+	"github.com/JReyLBC/jresponse"
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	routeTmpl *tmpl.Template
+)
+
+func init() {
+	cntxLog := log.WithFields(log.Fields{
+		"func": "response.init()",
+	})
+
+	fmtFuncMap := tmpl.FuncMap{
+		"isNextHop":     isNextHop,
+		"protocol":      protocol,
+		"entryAttrs":    entryAttrs,
+		"nhText":        nhText,
+		"entryCount":    entryCount,
+		"detailMetrics": detailMetrics,
+		"resolved":      resolved,
+	}
+
+	var err error
+	if routeTmpl, err = tmpl.
+		New("routeTmpl").
+		Funcs(fmtFuncMap).
+		Parse(routeTmplStr); err != nil {
+		cntxLog.Fatalln(err)
+	}
+
+	jresponse.Register(xml.Name{Space: jresponse.JunosNamespace("junos-routing"), Local: "route-information"},
+		func() jresponse.ResponseReaderWriter { return new(Route) })
+}
+
+// routeTmplStr renders each route table in turn; the tableHeader, legend
+// and rt templates are also used on their own when streaming routes. Each
+// route is rendered in the brief or detail format, as selected by its
+// junos:style, and only tables of brief routes have a legend.
+const routeTmplStr = "{{range $t, $table := .RouteTables}}{{if $t}}\n{{end}}" +
+	"{{template \"tableHeader\" $table}}" +
+	"{{ if $table.RT }}{{if eq (index $table.RT 0).CLIStyle \"brief\"}}{{template \"legend\"}}{{end}}" +
+	"{{range $_, $rt := $table.RT}}{{template \"rt\" $rt}}{{end}}" +
+	"{{end}}{{end}}" +
+
+	"{{define \"tableHeader\"}}" +
+	"{{.TableName}}: {{.DestinationCount}} destinations, " +
+	"{{.TotalRouteCount}} routes ({{.ActiveRouteCount}}, " +
+	"{{.HoldDownRouteCount}} holddown, {{.HiddenRouteCount}} hidden)\n" +
+	"{{end}}" +
+
+	"{{define \"legend\"}}" +
+	"@ = Routing Use Only, # = Forwarding Use Only\n" +
+	"+ = Active Route, - = Last Active, * = Both\n\n" +
+	"{{end}}" +
+
+	"{{define \"rt\"}}" +
+	"{{if eq .CLIStyle \"detail\"}}{{template \"rtDetail\" .}}{{else}}{{template \"rtBrief\" .}}{{end}}" +
+	"{{end}}" +
+
+	"{{define \"rtBrief\"}}" +
+	"{{.RTDestination}}" +
+
+	"{{range $i, $rtEntry := .RTEntry}}" +
+	"{{if eq $i 0}}      {{else}}                {{end}}{{$rtEntry.ActiveTag}}" +
+	"[{{protocol $rtEntry}}] {{$rtEntry.Age.AgeTime}}{{entryAttrs $rtEntry}}\n" +
+	"{{if or (eq $rtEntry.ProtocolName \"BGP\") $rtEntry.AsPath}}" +
+	"                  AS path: {{$rtEntry.AsPath}}" +
+	"{{if $rtEntry.ValidationState}}, validation-state: {{$rtEntry.ValidationState}}{{end}}\n" +
+	"{{end}}" +
+
+	"{{range $_, $nh := $rtEntry.NH}}" +
+	"                {{isNextHop $nh.SelectedNextHop}} {{nhText $nh}}\n" +
+
+	"{{end}}{{end}}{{end}}" +
+
+	"{{define \"rtDetail\"}}" +
+	"{{.RTDestination}} ({{entryCount .RTEntryCount}}, {{.RTAnnouncedCount}} announced)\n" +
+	"{{range $_, $rtEntry := .RTEntry}}{{template \"rtEntryDetail\" $rtEntry}}{{end}}\n" +
+	"{{end}}" +
+
+	"{{define \"rtEntryDetail\"}}" +
+	"        {{printf \"%1s%-6s\" .ActiveTag .ProtocolName}} Preference: {{.Preference}}" +
+	"{{with .Preference2}}/{{.}}{{end}}\n" +
+	"{{if .NHType}}                Next hop type: {{.NHType}}{{with .NHIndex}}, Next hop index: {{.}}{{end}}\n{{end}}" +
+	"{{with .Gateway}}                Source: {{.}}\n{{end}}" +
+	"{{range $_, $nh := .NH}}{{template \"nhDetail\" $nh}}{{end}}" +
+	"{{range $_, $pnh := .ProtocolNH}}                Protocol next hop: {{$pnh.To}}\n" +
+	"{{with $pnh.IndirectNH}}                Indirect next hop: {{.}}\n{{end}}{{end}}" +
+	"{{with .RTEntryState}}                State: <{{.}}>\n{{end}}" +
+	"{{with .InactiveReason}}                Inactive reason: {{.}}\n{{end}}" +
+	"{{if or .LocalAS .PeerAS}}                Local AS: {{.LocalAS}} Peer AS: {{.PeerAS}}\n{{end}}" +
+	"                Age: {{.Age.AgeTime}}{{detailMetrics .}}\n" +
+	"{{with .ValidationState}}                Validation State: {{.}}\n{{end}}" +
+	"{{with .TaskName}}                Task: {{.}}\n{{end}}" +
+	"{{if .AnnounceTasks}}                Announcement bits ({{.AnnounceBits}}): {{.AnnounceTasks}}\n{{end}}" +
+	"{{if or (eq .ProtocolName \"BGP\") .AsPath}}                AS path: {{.AsPath}}\n{{end}}" +
+	"{{with .Communities}}                Communities: {{.String}}\n{{end}}" +
+	"{{if .Accepted}}                Accepted\n{{end}}" +
+	"{{if .Rejected}}                Rejected\n{{end}}" +
+	"{{if eq .ProtocolName \"BGP\"}}                Localpref: {{.LocalPreference}}\n{{end}}" +
+	"{{with .PeerID}}                Router ID: {{.}}\n{{end}}" +
+	"{{with .ClusterList}}                Cluster list: {{.}}\n{{end}}" +
+	"{{with .OriginatorID}}                Originator ID: {{.}}\n{{end}}" +
+	"{{if resolved .ProtocolNH}}                Indirect next hops: {{len .ProtocolNH}}\n" +
+	"{{range $_, $pnh := .ProtocolNH}}" +
+	"                        Protocol next hop: {{$pnh.To}}{{with $pnh.Metric}} Metric: {{.}}{{end}}\n" +
+	"{{with $pnh.IndirectNH}}                        Indirect next hop: {{.}}\n{{end}}" +
+	"                        Indirect path forwarding next hops: {{len $pnh.NH}}\n" +
+	"{{with $pnh.NHType}}                                Next hop type: {{.}}\n{{end}}" +
+	"{{range $_, $nh := $pnh.NH}}                                Next hop: {{$nh.To}} via {{$nh.Via}}\n{{end}}" +
+	"{{end}}{{end}}" +
+	"{{end}}" +
+
+	"{{define \"nhDetail\"}}" +
+	"{{if .NHLocalInterface}}                Interface: {{.NHLocalInterface}}\n" +
+	"{{else if or .To .Via}}                Next hop: {{with .To}}{{.}} {{end}}via {{.Via}}" +
+	"{{if .SelectedNextHop}}, selected{{end}}\n" +
+	"{{with .LSPName}}                Label-switched-path {{.}}\n{{end}}" +
+	"{{with .MPLSLabel}}                Label operation: {{.}}\n{{end}}" +
+	"{{end}}" +
+	"{{end}}"
+
+func isNextHop(nextHopInd *string) string {
+	switch nextHopInd {
+	case nil:
+		return " "
+	default:
+		return ">"
+	}
+}
+
+// protocol returns the protocol and preferences of a route entry as shown
+// in brackets, e.g. "OSPF/10", or "RSVP/7/1" for entries with a second
+// preference. Negative second preferences, such as the -101 of BGP routes
+// in the detail style, aren't shown.
+func protocol(e RTEntry) string {
+	s := fmt.Sprintf("%s/%d", e.ProtocolName, e.Preference)
+	if e.Preference2 != nil && *e.Preference2 >= 0 {
+		s += fmt.Sprintf("/%d", *e.Preference2)
+	}
+	return s
+}
+
+// entryAttrs returns the attributes following the age on the first line of
+// a route entry, e.g. ", metric 20, tag 0". BGP entries always show their
+// MED and local preference.
+func entryAttrs(e RTEntry) string {
+	var attrs []string
+
+	if e.Metric != nil {
+		attrs = append(attrs, fmt.Sprintf("metric %d", *e.Metric))
+	}
+	if e.Metric2 != nil {
+		attrs = append(attrs, fmt.Sprintf("metric2 %d", *e.Metric2))
+	}
+	if e.ProtocolName == "BGP" {
+		attrs = append(attrs, fmt.Sprintf("MED %d", e.Med), fmt.Sprintf("localpref %d", e.LocalPreference))
+	}
+	if e.LearnedFrom != "" {
+		attrs = append(attrs, "from "+e.LearnedFrom)
+	}
+	if e.Tag != nil {
+		attrs = append(attrs, fmt.Sprintf("tag %d", *e.Tag))
+	}
+
+	if len(attrs) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(attrs, ", ")
+}
+
+// nhText returns a next hop as shown after the selected next hop marker,
+// e.g. "to 10.0.0.1 via ge-0/0/0.0", "via ge-0/0/0.0" for direct routes,
+// "Local via ge-0/0/0.0" for local routes, or the next hop type of routes
+// without a gateway, such as "Discard".
+func nhText(nh NH) string {
+	var s string
+	switch {
+	case nh.NHLocalInterface != "":
+		s = "Local via " + nh.NHLocalInterface
+	case nh.To != "":
+		s = "to " + nh.To + " via " + nh.Via
+	case nh.Via != "":
+		s = "via " + nh.Via
+	default:
+		s = nh.NHType
+	}
+
+	if nh.MPLSLabel != "" {
+		s += ", " + nh.MPLSLabel
+	}
+	if nh.LSPName != "" {
+		s += ", label-switched-path " + nh.LSPName
+	}
+	return s
+}
+
+// entryCount returns the number of entries of a route in the detail style,
+// e.g. "1 entry" or "3 entries".
+func entryCount(count int) string {
+	if count == 1 {
+		return "1 entry"
+	}
+	return fmt.Sprintf("%d entries", count)
+}
+
+// detailMetrics returns the metrics following the age of a route entry in
+// the detail style, e.g. " \tMetric: 0 \tMetric2: 10". The metric of a
+// BGP entry is its MED.
+func detailMetrics(e RTEntry) string {
+	var s string
+	if e.ProtocolName == "BGP" {
+		s += fmt.Sprintf(" \tMetric: %d", e.Med)
+	} else if e.Metric != nil {
+		s += fmt.Sprintf(" \tMetric: %d", *e.Metric)
+	}
+	if e.Metric2 != nil {
+		s += fmt.Sprintf(" \tMetric2: %d", *e.Metric2)
+	}
+	return s
+}
+
+// resolved reports whether any of the protocol next hops carry how they
+// resolve, as in the extensive style.
+func resolved(pnhs []ProtocolNH) bool {
+	for _, pnh := range pnhs {
+		if pnh.Metric != nil || pnh.NHType != "" || len(pnh.NH) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type NH struct {
+	// <selected-next-hop> is either present as an empty tag, or not present
+	// so we need a pointer to distinguish its presence (not nil), or lack thereof (nil)
+	SelectedNextHop  *string `xml:"selected-next-hop"            json:"selected-next-hop"`
+	NHType           string  `xml:"nh-type,omitempty"            json:"nh-type,omitempty"`
+	To               string  `xml:"to,omitempty"                 json:"to,omitempty"`
+	Via              string  `xml:"via,omitempty"                json:"via,omitempty"`
+	NHLocalInterface string  `xml:"nh-local-interface,omitempty" json:"nh-local-interface,omitempty"`
+	MPLSLabel        string  `xml:"mpls-label,omitempty"         json:"mpls-label,omitempty"`
+	LSPName          string  `xml:"lsp-name,omitempty"           json:"lsp-name,omitempty"`
+}
+
+type Age struct {
+	AgeSecs jresponse.JunosAttr `xml:"seconds,attr" json:"age-seconds,omitempty"`
+	AgeTime string              `xml:",chardata"    json:"age,omitempty"`
+}
+
+// Duration returns the age of the route, from the junos:seconds attribute
+// when present and otherwise from the age text.
+func (age *Age) Duration() (time.Duration, error) {
+	if age.AgeSecs != "" {
+		secs, err := strconv.ParseInt(strings.TrimSpace(string(age.AgeSecs)), 10, 64)
+		return time.Duration(secs) * time.Second, err
+	}
+	return jresponse.ParseJunosDuration(strings.TrimSpace(age.AgeTime))
+}
+
+// RTEntry is a route to a destination learned from one protocol. Fields
+// that only apply to some protocols are left empty, or nil, by the others:
+// Metric, Metric2, Tag, Area and Level are set by the IGPs and static
+// routes, and Med through ValidationState by BGP. Area and Level are not
+// part of the CLI output, so they are lost when reading it.
+//
+// The fields from NHType on are only reported in the detail and extensive
+// styles. AgeSinceChange is not part of the CLI output either.
+type RTEntry struct {
+	ActiveTag       string       `xml:"active-tag,omitempty"       json:"active-date,omitempty"`
+	CurrentActive   string       `xml:"current-active,omitempty"   json:"current-active,omitempty"`
+	LastActive      string       `xml:"last-active,omitempty"      json:"last-active,omitempty"`
+	ProtocolName    string       `xml:"protocol-name,omitempty"    json:"protocol-name,omitempty"`
+	Preference      int          `xml:"preference,omitempty"       json:"preference,omitempty"`
+	Preference2     *int         `xml:"preference2,omitempty"      json:"preference2,omitempty"`
+	Age             Age          `xml:"age,omitempty"              json:"age,omitempty"`
+	Metric          *int         `xml:"metric,omitempty"           json:"metric,omitempty"`
+	Metric2         *int         `xml:"metric2,omitempty"          json:"metric2,omitempty"`
+	Med             int          `xml:"med,omitempty"              json:"med,omitempty"`
+	LocalPreference int          `xml:"local-preference,omitempty" json:"local-preference,omitempty"`
+	LearnedFrom     string       `xml:"learned-from,omitempty"     json:"learned-from,omitempty"`
+	AsPath          string       `xml:"as-path,omitempty"          json:"as-path,omitempty"`
+	ValidationState string       `xml:"validation-state,omitempty" json:"validation-state,omitempty"`
+	Tag             *int         `xml:"rt-tag,omitempty"           json:"rt-tag,omitempty"`
+	Area            string       `xml:"rt-ospf-area,omitempty"     json:"rt-ospf-area,omitempty"`
+	Level           *int         `xml:"rt-isis-level,omitempty"    json:"rt-isis-level,omitempty"`
+	NHType          string       `xml:"nh-type,omitempty"          json:"nh-type,omitempty"`
+	NHIndex         *int         `xml:"nh-index,omitempty"         json:"nh-index,omitempty"`
+	Gateway         string       `xml:"gateway,omitempty"          json:"gateway,omitempty"`
+	NH              []NH         `xml:"nh,omitempty"               json:"nh,omitempty"`
+	ProtocolNH      []ProtocolNH `xml:"protocol-nh,omitempty"      json:"protocol-nh,omitempty"`
+	RTEntryState    string       `xml:"rt-entry-state,omitempty"   json:"rt-entry-state,omitempty"`
+	InactiveReason  string       `xml:"inactive-reason,omitempty"  json:"inactive-reason,omitempty"`
+	LocalAS         int          `xml:"local-as,omitempty"         json:"local-as,omitempty"`
+	PeerAS          int          `xml:"peer-as,omitempty"          json:"peer-as,omitempty"`
+	AgeSinceChange  *Age         `xml:"age-since-change,omitempty" json:"age-since-change,omitempty"`
+	TaskName        string       `xml:"task-name,omitempty"        json:"task-name,omitempty"`
+	AnnounceBits    int          `xml:"announce-bits,omitempty"    json:"announce-bits,omitempty"`
+	AnnounceTasks   string       `xml:"announce-tasks,omitempty"   json:"announce-tasks,omitempty"`
+	Communities     *Communities `xml:"communities,omitempty"      json:"communities,omitempty"`
+	// <accepted> and <rejected> are empty tags, like <selected-next-hop>
+	Accepted     *string `xml:"accepted"                json:"accepted,omitempty"`
+	Rejected     *string `xml:"rejected"                json:"rejected,omitempty"`
+	PeerID       string  `xml:"peer-id,omitempty"       json:"peer-id,omitempty"`
+	ClusterList  string  `xml:"cluster-list,omitempty"  json:"cluster-list,omitempty"`
+	OriginatorID string  `xml:"originator-id,omitempty" json:"originator-id,omitempty"`
+}
+
+// ProtocolNH is the protocol next hop of a BGP route. In the extensive
+// style it also carries how the next hop resolves: the metric to reach it
+// and the forwarding next hops used.
+type ProtocolNH struct {
+	To         string `xml:"to,omitempty"          json:"to,omitempty"`
+	Metric     *int   `xml:"metric,omitempty"      json:"metric,omitempty"`
+	IndirectNH string `xml:"indirect-nh,omitempty" json:"indirect-nh,omitempty"`
+	NHType     string `xml:"nh-type,omitempty"     json:"nh-type,omitempty"`
+	NH         []NH   `xml:"nh,omitempty"          json:"nh,omitempty"`
+}
+
+// Communities holds the BGP communities of a route by kind: standard
+// communities such as "7922:1000" or "no-export", extended communities
+// such as "target:7922:1", and large communities such as "large:7922:1:2".
+type Communities struct {
+	Community         []string `xml:"community,omitempty"          json:"community,omitempty"`
+	ExtendedCommunity []string `xml:"extended-community,omitempty" json:"extended-community,omitempty"`
+	LargeCommunity    []string `xml:"large-community,omitempty"    json:"large-community,omitempty"`
+}
+
+// String returns the communities as shown by the CLI, separated by spaces.
+func (c *Communities) String() string {
+	all := append(append(append([]string{}, c.Community...), c.ExtendedCommunity...), c.LargeCommunity...)
+	return strings.Join(all, " ")
+}
+
+// add adds a community as shown by the CLI to the kind it belongs to.
+// Extended communities start with their type, e.g. "target:", and large
+// communities with "large:".
+func (c *Communities) add(community string) {
+	switch {
+	case strings.HasPrefix(community, "large:"):
+		c.LargeCommunity = append(c.LargeCommunity, community)
+	case strings.Contains(community, ":") && community[0] >= 'a' && community[0] <= 'z':
+		c.ExtendedCommunity = append(c.ExtendedCommunity, community)
+	default:
+		c.Community = append(c.Community, community)
+	}
+}
+
+// StateFlags returns the flags of the entry's state, e.g. Active and Ext
+// for a state of "<Active Ext>".
+func (e *RTEntry) StateFlags() []string {
+	return strings.Fields(e.RTEntryState)
+}
+
+// The CLI styles, named as in the junos:style attribute of each route.
+const (
+	StyleBrief     = "brief"
+	StyleDetail    = "detail"
+	StyleExtensive = "extensive"
+)
+
+// RT is a destination and its routes. Style is the junos:style of the
+// route, which selects the format WriteCLITo renders it in; the entry
+// and announced counts are only reported in the detail and extensive
+// styles.
+type RT struct {
+	Style            jresponse.JunosAttr `xml:"style,attr,omitempty"         json:"style,omitempty"`
+	RTDestination    string              `xml:"rt-destination,omitempty"     json:"rt-destination,omitempty"`
+	RTEntryCount     int                 `xml:"rt-entry-count,omitempty"     json:"rt-entry-count,omitempty"`
+	RTAnnouncedCount int                 `xml:"rt-announced-count,omitempty" json:"rt-announced-count,omitempty"`
+	RTEntry          []RTEntry           `xml:"rt-entry,omitempty"           json:"rt-entry,omitempty"`
+}
+
+// CLIStyle returns the format WriteCLITo renders the route in: StyleDetail
+// for routes in the detail and extensive styles, whose formats differ
+// only in the attributes present, and StyleBrief for any other.
+func (rt *RT) CLIStyle() string {
+	style := string(rt.Style)
+	if strings.HasPrefix(style, StyleDetail) || strings.HasPrefix(style, StyleExtensive) {
+		return StyleDetail
+	}
+	return StyleBrief
+}
+
+type RouteTable struct {
+	TableName          string `xml:"table-name,omitempty"           json:"table-name,omitempty"`
+	DestinationCount   int    `xml:"destination-count,omitempty"    json:"destination-count,omitempty"`
+	TotalRouteCount    int    `xml:"total-route-count,omitempty"    json:"total-route-count,omitempty"`
+	ActiveRouteCount   int    `xml:"active-route-count,omitempty"   json:"active-route-count,omitempty"`
+	HoldDownRouteCount int    `xml:"holddown-route-count"           json:"holddown-route-count"`
+	HiddenRouteCount   int    `xml:"hidden-route-count,omitempty"   json:"hidden-route-count,omitempty"`
+	RT                 []RT   `xml:"rt,omitempty"                   json:"rt,omitempty"`
+}
+
+type RPCError = jresponse.RPCError
+
+// ToAddr returns the next hop's address.
+func (nh *NH) ToAddr() (netip.Addr, error) {
+	return jresponse.ParseAddr(nh.To)
+}
+
+// Interface returns the interface the next hop points out of, or for
+// local routes, the interface the address is configured on.
+func (nh *NH) Interface() string {
+	if nh.NHLocalInterface != "" {
+		return nh.NHLocalInterface
+	}
+	return nh.Via
+}
+
+// LearnedFromAddr returns the address of the peer the route was learned from.
+func (e *RTEntry) LearnedFromAddr() (netip.Addr, error) {
+	return jresponse.ParseAddr(e.LearnedFrom)
+}
+
+// Prefix returns the route's destination.
+func (rt *RT) Prefix() (netip.Prefix, error) {
+	return jresponse.ParsePrefix(rt.RTDestination)
+}
+
+// Family returns the address family of the table's routes, from its name,
+// e.g. inet for "inet.0" and "CUST-A.inet.0". It returns "" for tables of
+// other families, such as "bgp.l3vpn.0", whose destinations aren't plain
+// prefixes.
+func (table *RouteTable) Family() jresponse.Family {
+	parts := strings.Split(table.TableName, ".")
+	if len(parts) < 2 {
+		return ""
+	}
+
+	switch family := jresponse.Family(parts[len(parts)-2]); family {
+	case jresponse.FamilyInet, jresponse.FamilyInet6:
+		return family
+	default:
+		return ""
+	}
+}
+
+// SortRT sorts the table's routes by destination, with the less specific
+// of two prefixes first. Routes whose destination isn't a prefix are
+// moved to the end, in their original order.
+func (table *RouteTable) SortRT() {
+	type keyed struct {
+		prefix netip.Prefix
+		ok     bool
+		rt     RT
+	}
+
+	keys := make([]keyed, len(table.RT))
+	for i, rt := range table.RT {
+		prefix, err := rt.Prefix()
+		keys[i] = keyed{prefix, err == nil, rt}
+	}
+
+	slices.SortStableFunc(keys, func(a, b keyed) int {
+		switch {
+		case a.ok && b.ok:
+			return jresponse.ComparePrefixes(a.prefix, b.prefix)
+		case a.ok:
+			return -1
+		case b.ok:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	for i := range keys {
+		table.RT[i] = keys[i].rt
+	}
+}
+
+// RTByProtocol returns the entries of every route grouped by the protocol
+// they were learned from, e.g. "OSPF" or "Static". Each entry is returned
+// as a route of its own, with the destination it belongs to.
+func (route *Route) RTByProtocol() map[string][]RT {
+	protocols := make(map[string][]RT)
+	for _, table := range route.RouteTables {
+		for _, rt := range table.RT {
+			for _, entry := range rt.RTEntry {
+				protocols[entry.ProtocolName] = append(protocols[entry.ProtocolName],
+					RT{RTDestination: rt.RTDestination, RTEntry: []RTEntry{entry}})
+			}
+		}
+	}
+	return protocols
+}
+
+// ValidateAddrs checks the addresses of a route found at path in a table
+// of the given family, adding any that are malformed to v.
+func (rt *RT) ValidateAddrs(v *jresponse.AddrValidator, path string, family jresponse.Family) {
+	if family != "" {
+		v.Prefix(path+"/rt-destination", rt.RTDestination)
+	}
+
+	for i, entry := range rt.RTEntry {
+		entryPath := fmt.Sprintf("%s/rt-entry[%d]", path, i+1)
+		v.Addr(entryPath+"/learned-from", entry.LearnedFrom)
+		v.Addr(entryPath+"/gateway", entry.Gateway)
+		v.Addr(entryPath+"/peer-id", entry.PeerID)
+		v.Addr(entryPath+"/originator-id", entry.OriginatorID)
+		for j, nh := range entry.NH {
+			v.Addr(fmt.Sprintf("%s/nh[%d]/to", entryPath, j+1), nh.To)
+		}
+		for j, pnh := range entry.ProtocolNH {
+			pnhPath := fmt.Sprintf("%s/protocol-nh[%d]", entryPath, j+1)
+			v.Addr(pnhPath+"/to", pnh.To)
+			for k, nh := range pnh.NH {
+				v.Addr(fmt.Sprintf("%s/nh[%d]/to", pnhPath, k+1), nh.To)
+			}
+		}
+	}
+}
+
+// Validate checks the addresses and prefixes in the response, returning a
+// *jresponse.AddrError for each one that is malformed. Destinations are
+// only checked in inet and inet6 tables.
+func (route *Route) Validate() error {
+	v := jresponse.AddrValidator{}
+	for i, table := range route.RouteTables {
+		family := table.Family()
+		for j, rt := range table.RT {
+			rt.ValidateAddrs(&v, fmt.Sprintf("route-information/route-table[%d]/rt[%d]", i+1, j+1), family)
+		}
+	}
+	return v.Err()
+}
+
+// Route is the reply to "show route" for any destination or protocol, and
+// is used to convert it from XML to JSON. Junos returns one route table
+// for each table with matching routes, e.g. inet.0, inet.3 and mpls.0.
+type Route struct {
+	XMLName     xml.Name        `xml:"route-information"           json:"-"`
+	JunosNS     jresponse.XMLNS `xml:"xmlns junos,attr,omitempty" json:"-"`
+	RouteTables []RouteTable    `xml:"route-table,omitempty"       json:"route-table,omitempty"`
+	Errors      []RPCError      `xml:"rpc-error,omitempty"         json:"rpc-error,omitempty"`
+	OriginHost  string          `xml:"-"                          json:"originhost,omitempty"`
+	OriginIP    string          `xml:"-"                          json:"originip,omitempty"`
+}
+
+func (route *Route) WriteXMLTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteXML(w, route, route.XMLName)
+}
+
+func (route *Route) WriteJSONTo(w io.Writer) (n int64, err error) {
+	if s, err := json.Marshal(route); err != nil {
+		return 0, err
+	} else {
+		buf := bytes.NewBuffer(s)
+		return buf.WriteTo(w)
+	}
+}
+
+var routeJSONTimeFields = jresponse.JSONTimeFields{
+	Durations: map[string]time.Duration{"age-seconds": time.Second},
+}
+
+// WriteJSONToWithOptions writes the same JSON as WriteJSONTo, with route
+// ages encoded as selected by opts.
+func (route *Route) WriteJSONToWithOptions(w io.Writer, opts jresponse.JSONOptions) (n int64, err error) {
+	return jresponse.WriteJSONWithOptions(w, route, routeJSONTimeFields, opts)
+}
+
+// WriteJunosJSONTo writes the route tables in the JSON Junos encodes them as
+// with "| display json".
+func (route *Route) WriteJunosJSONTo(w io.Writer) (n int64, err error) {
+	return jresponse.WriteJunosJSON(w, route)
+}
+
+func (route *Route) WriteCLITo(w io.Writer) error {
+	return routeTmpl.Execute(w, route)
+}
+
+// WriteCLIHeaderTo writes the header line of the table, as it starts the
+// table in the output of Route.WriteCLITo.
+func (table *RouteTable) WriteCLIHeaderTo(w io.Writer) error {
+	return routeTmpl.ExecuteTemplate(w, "tableHeader", table)
+}
+
+// WriteCLILegendTo writes the legend that follows the header of a table
+// with routes in the output of Route.WriteCLITo.
+func WriteCLILegendTo(w io.Writer) error {
+	return routeTmpl.ExecuteTemplate(w, "legend", nil)
+}
+
+// WriteCLITo writes the route's destination and entries, as they appear
+// in the output of Route.WriteCLITo.
+func (rt *RT) WriteCLITo(w io.Writer) error {
+	return routeTmpl.ExecuteTemplate(w, "rt", rt)
+}
+
+func (route *Route) ReadXMLFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	}
+
+	if err := jresponse.UnmarshalReply(buf.Bytes(), route, &route.Errors); err != nil {
+		return n, err
+	}
+
+	if err := jresponse.CheckErrors(route.Errors); err != nil {
+		return n, err
+	}
+
+	return n, route.Validate()
+}
+
+func (route *Route) ReadJSONFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	} else if err = json.Unmarshal(buf.Bytes(), route); err != nil {
+		return n, err
+	} else {
+		return n, route.Validate()
+	}
+}
+
+// ReadJunosJSONFrom reads the route tables from the JSON Junos encodes them
+// as, captured with "| display json" or from its REST API.
+func (route *Route) ReadJunosJSONFrom(r io.Reader) (n int64, err error) {
+	return jresponse.ReadJunosJSONFrom(r, route)
+}
+
+var (
+	tableHeaderRegexp = regexp.MustCompile(`^(\S+): (\d+) destinations, (\d+) routes ` +
+		`\((\d+)(?: active)?, (\d+) holddown, (\d+) hidden\)$`)
+	rtEntryRegexp = regexp.MustCompile(`^([*+\-@#]*)\[([^/\]]+)/(\d+)(?:/(\d+))?\] (.*)$`)
+
+	detailRTRegexp    = regexp.MustCompile(`^(\S+) \((\d+) entr(?:y|ies), (\d+) announced\)$`)
+	detailEntryRegexp = regexp.MustCompile(`^([*+\-@#]*)(\S+)\s+Preference: (\d+)(?:/(-?\d+))?$`)
+)
+
+// ageSeconds converts a Junos age string such as "1w1d 19:44:07" into the
+// number of seconds Junos reports in the junos:seconds attribute.
+func ageSeconds(ageTime string) (string, error) {
+	d, err := jresponse.ParseJunosDuration(ageTime)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(int64(d/time.Second), 10), nil
+}
+
+// parseOptionalInt parses s into a new int.
+func parseOptionalInt(s string) (*int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// parseRTEntry parses the first line of a route entry, starting at the
+// active tag, e.g. "*[BGP/170] 6d 18:32:08, MED 0, localpref 130, from 10.0.0.1"
+// or "*[OSPF/150] 1d 02:03:04, metric 20, tag 0".
+func parseRTEntry(line string) (*RTEntry, error) {
+	m := rtEntryRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("invalid route entry %q", line)
+	}
+
+	entry := &RTEntry{ActiveTag: m[1], ProtocolName: m[2]}
+
+	var err error
+	if entry.Preference, err = strconv.Atoi(m[3]); err != nil {
+		return nil, err
+	}
+	if m[4] != "" {
+		if entry.Preference2, err = parseOptionalInt(m[4]); err != nil {
+			return nil, err
+		}
+	}
+
+	attrs := strings.Split(m[5], ", ")
+	entry.Age.AgeTime = attrs[0]
+	secs, err := ageSeconds(attrs[0])
+	if err != nil {
+		return nil, err
+	}
+	entry.Age.AgeSecs = jresponse.JunosAttr(secs)
+
+	for _, attr := range attrs[1:] {
+		switch {
+		case strings.HasPrefix(attr, "metric "):
+			entry.Metric, err = parseOptionalInt(strings.TrimPrefix(attr, "metric "))
+		case strings.HasPrefix(attr, "metric2 "):
+			entry.Metric2, err = parseOptionalInt(strings.TrimPrefix(attr, "metric2 "))
+		case strings.HasPrefix(attr, "MED "):
+			entry.Med, err = strconv.Atoi(strings.TrimPrefix(attr, "MED "))
+		case strings.HasPrefix(attr, "localpref "):
+			entry.LocalPreference, err = strconv.Atoi(strings.TrimPrefix(attr, "localpref "))
+		case strings.HasPrefix(attr, "from "):
+			entry.LearnedFrom = strings.TrimPrefix(attr, "from ")
+		case strings.HasPrefix(attr, "tag "):
+			entry.Tag, err = parseOptionalInt(strings.TrimPrefix(attr, "tag "))
+		default:
+			err = fmt.Errorf("unknown route entry attribute %q", attr)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entry, nil
+}
+
+// parseNH parses a next hop line with its leading whitespace removed, in
+// any of the forms returned by nhText, e.g.
+// "> to 10.0.0.1 via ae0.0, Push 299776, label-switched-path lsp1".
+func parseNH(line string) (*NH, error) {
+	nh := new(NH)
+
+	if strings.HasPrefix(line, ">") {
+		nh.SelectedNextHop = new(string)
+		line = strings.TrimSpace(line[1:])
+	}
+
+	if i := strings.Index(line, ", label-switched-path "); i >= 0 {
+		nh.LSPName = line[i+len(", label-switched-path "):]
+		line = line[:i]
+	}
+
+	if i := strings.Index(line, ", "); i >= 0 {
+		nh.MPLSLabel = line[i+len(", "):]
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 4 && fields[0] == "to" && fields[2] == "via":
+		nh.To, nh.Via = fields[1], fields[3]
+	case len(fields) == 3 && fields[0] == "Local" && fields[1] == "via":
+		nh.NHLocalInterface = fields[2]
+	case len(fields) == 2 && fields[0] == "via":
+		nh.Via = fields[1]
+	case len(fields) == 1 && fields[0] != "to" && fields[0] != "via":
+		nh.NHType = fields[0]
+	default:
+		return nil, fmt.Errorf("invalid next hop %q", line)
+	}
+
+	return nh, nil
+}
+
+// detailState is the position of ReadCLIFrom within the entries of a
+// route in the detail style: whether the indirect next hops of the last
+// entry have started, and which protocol next hop they are resolving.
+type detailState struct {
+	indirect bool
+	pnh      int
+}
+
+// parseDetailNH parses a next hop in the detail style, without its "Next
+// hop: " label, e.g. "10.0.0.1 via ae0.0, selected" or "via ge-0/0/1.0".
+func parseDetailNH(value string) (*NH, error) {
+	nh := new(NH)
+
+	if v, ok := strings.CutSuffix(value, ", selected"); ok {
+		nh.SelectedNextHop = new(string)
+		value = v
+	}
+
+	fields := strings.Fields(value)
+	switch {
+	case len(fields) == 3 && fields[1] == "via":
+		nh.To, nh.Via = fields[0], fields[2]
+	case len(fields) == 2 && fields[0] == "via":
+		nh.Via = fields[1]
+	default:
+		return nil, fmt.Errorf("invalid next hop %q", value)
+	}
+
+	return nh, nil
+}
+
+// parseDetailLine parses a line of a route in the detail style, with its
+// leading whitespace removed, into rt. Lines of attributes that RTEntry
+// doesn't carry, such as "Address: 0x9c3c4a4", are skipped.
+func parseDetailLine(rt *RT, state *detailState, line string) (err error) {
+	if m := detailEntryRegexp.FindStringSubmatch(line); m != nil {
+		entry := RTEntry{ActiveTag: m[1], ProtocolName: m[2]}
+		if entry.Preference, err = strconv.Atoi(m[3]); err != nil {
+			return err
+		} else if m[4] != "" {
+			if entry.Preference2, err = parseOptionalInt(m[4]); err != nil {
+				return err
+			}
+		}
+		rt.RTEntry = append(rt.RTEntry, entry)
+		*state = detailState{}
+		return nil
+	}
+
+	if len(rt.RTEntry) == 0 {
+		return fmt.Errorf("route data before route entry")
+	}
+	entry := &rt.RTEntry[len(rt.RTEntry)-1]
+
+	if state.indirect {
+		return parseIndirectLine(entry, state, line)
+	}
+
+	key, value, _ := strings.Cut(line, ":")
+	value = strings.TrimSpace(value)
+
+	switch {
+	case line == "Accepted":
+		entry.Accepted = new(string)
+	case line == "Rejected":
+		entry.Rejected = new(string)
+	case strings.HasPrefix(line, "Label-switched-path "), key == "Label operation":
+		if len(entry.NH) == 0 {
+			return fmt.Errorf("label before next hop")
+		}
+		nh := &entry.NH[len(entry.NH)-1]
+		if key == "Label operation" {
+			nh.MPLSLabel = value
+		} else {
+			nh.LSPName = strings.TrimPrefix(line, "Label-switched-path ")
+		}
+	case key == "Next hop type":
+		typ, index, found := strings.Cut(value, ", Next hop index: ")
+		entry.NHType = typ
+		if found {
+			entry.NHIndex, err = parseOptionalInt(index)
+		}
+	case key == "Source":
+		entry.Gateway = value
+	case key == "Next hop":
+		var nh *NH
+		if nh, err = parseDetailNH(value); err == nil {
+			entry.NH = append(entry.NH, *nh)
+		}
+	case key == "Interface":
+		entry.NH = append(entry.NH, NH{NHLocalInterface: value})
+	case key == "Protocol next hop":
+		entry.ProtocolNH = append(entry.ProtocolNH, ProtocolNH{To: value})
+	case key == "Indirect next hop":
+		if len(entry.ProtocolNH) == 0 {
+			return fmt.Errorf("indirect next hop before protocol next hop")
+		}
+		entry.ProtocolNH[len(entry.ProtocolNH)-1].IndirectNH = value
+	case key == "State":
+		entry.RTEntryState = strings.TrimSuffix(strings.TrimPrefix(value, "<"), ">")
+	case key == "Inactive reason":
+		entry.InactiveReason = value
+	case key == "Local AS":
+		local, peer, found := strings.Cut(value, " Peer AS: ")
+		if !found {
+			return fmt.Errorf("invalid AS numbers %q", value)
+		} else if entry.LocalAS, err = strconv.Atoi(strings.TrimSpace(local)); err == nil {
+			entry.PeerAS, err = strconv.Atoi(strings.TrimSpace(peer))
+		}
+	case key == "Age":
+		err = parseDetailAge(entry, value)
+	case key == "Validation State":
+		entry.ValidationState = value
+	case key == "Task":
+		entry.TaskName = value
+	case strings.HasPrefix(key, "Announcement bits ("):
+		bits := strings.TrimSuffix(strings.TrimPrefix(key, "Announcement bits ("), ")")
+		if entry.AnnounceBits, err = strconv.Atoi(bits); err == nil {
+			entry.AnnounceTasks = value
+		}
+	case key == "AS path":
+		entry.AsPath = value
+	case key == "Communities":
+		entry.Communities = new(Communities)
+		for _, community := range strings.Fields(value) {
+			entry.Communities.add(community)
+		}
+	case key == "Localpref":
+		entry.LocalPreference, err = strconv.Atoi(value)
+	case key == "Router ID":
+		entry.PeerID = value
+	case key == "Cluster list":
+		entry.ClusterList = value
+	case key == "Originator ID":
+		entry.OriginatorID = value
+	case key == "Indirect next hops":
+		state.indirect, state.pnh = true, -1
+	}
+
+	return err
+}
+
+// parseDetailAge parses the age of an entry in the detail style and the
+// metrics that follow it, e.g. "6d 18:32:08 \tMetric: 0 \tMetric2: 10".
+func parseDetailAge(entry *RTEntry, value string) (err error) {
+	parts := strings.Split(value, "\t")
+
+	entry.Age.AgeTime = strings.TrimSpace(parts[0])
+	secs, err := ageSeconds(entry.Age.AgeTime)
+	if err != nil {
+		return err
+	}
+	entry.Age.AgeSecs = jresponse.JunosAttr(secs)
+
+	for _, part := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), ": ")
+		switch {
+		case key == "Metric" && entry.ProtocolName == "BGP":
+			entry.Med, err = strconv.Atoi(value)
+		case key == "Metric":
+			entry.Metric, err = parseOptionalInt(value)
+		case key == "Metric2":
+			entry.Metric2, err = parseOptionalInt(value)
+		default:
+			err = fmt.Errorf("unknown route entry attribute %q", part)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseIndirectLine parses a line of the indirect next hops of an entry in
+// the detail style, which tell how each protocol next hop resolves.
+func parseIndirectLine(entry *RTEntry, state *detailState, line string) (err error) {
+	key, value, _ := strings.Cut(line, ":")
+	value = strings.TrimSpace(value)
+
+	if key == "Protocol next hop" {
+		to, metric, found := strings.Cut(value, " Metric: ")
+
+		state.pnh = slices.IndexFunc(entry.ProtocolNH, func(pnh ProtocolNH) bool { return pnh.To == to })
+		if state.pnh < 0 {
+			entry.ProtocolNH = append(entry.ProtocolNH, ProtocolNH{To: to})
+			state.pnh = len(entry.ProtocolNH) - 1
+		}
+
+		if found {
+			entry.ProtocolNH[state.pnh].Metric, err = parseOptionalInt(metric)
+		}
+		return err
+	}
+
+	if state.pnh < 0 {
+		return fmt.Errorf("indirect next hop data before protocol next hop")
+	}
+	pnh := &entry.ProtocolNH[state.pnh]
+
+	switch key {
+	case "Indirect next hop":
+		pnh.IndirectNH = value
+	case "Next hop type":
+		pnh.NHType = value
+	case "Next hop":
+		var nh *NH
+		if nh, err = parseDetailNH(value); err == nil {
+			pnh.NH = append(pnh.NH, *nh)
+		}
+	}
+
+	return err
+}
+
+// ReadCLIFrom parses the text output of "show route", in the format
+// produced by WriteCLITo, into route.
+func (route *Route) ReadCLIFrom(r io.Reader) (n int64, err error) {
+
+	buf := bytes.Buffer{}
+
+	if n, err = buf.ReadFrom(r); err != nil {
+		return n, err
+	}
+
+	var (
+		table  *RouteTable
+		rt     *RT
+		entry  *RTEntry
+		detail detailState
+	)
+
+	scanner := bufio.NewScanner(&buf)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "",
+			strings.HasPrefix(trimmed, "@ = "),
+			strings.HasPrefix(trimmed, "+ = "):
+			continue
+
+		case tableHeaderRegexp.MatchString(line):
+			route.RouteTables = append(route.RouteTables, RouteTable{})
+			table = &route.RouteTables[len(route.RouteTables)-1]
+			rt, entry = nil, nil
+
+			m := tableHeaderRegexp.FindStringSubmatch(line)
+			table.TableName = m[1]
+			counts := []*int{&table.DestinationCount, &table.TotalRouteCount,
+				&table.ActiveRouteCount, &table.HoldDownRouteCount, &table.HiddenRouteCount}
+			for i, count := range counts {
+				if *count, err = strconv.Atoi(m[i+2]); err != nil {
+					return n, fmt.Errorf("line %d: %v", lineNum, err)
+				}
+			}
+
+		case table == nil:
+			return n, fmt.Errorf("line %d: route data before route table header", lineNum)
+
+		case detailRTRegexp.MatchString(line):
+			// a new destination in the detail style, with its entries on
+			// the following lines
+			m := detailRTRegexp.FindStringSubmatch(line)
+			table.RT = append(table.RT, RT{Style: StyleDetail, RTDestination: m[1]})
+			rt, entry, detail = &table.RT[len(table.RT)-1], nil, detailState{}
+
+			if rt.RTEntryCount, err = strconv.Atoi(m[2]); err != nil {
+				return n, fmt.Errorf("line %d: %v", lineNum, err)
+			} else if rt.RTAnnouncedCount, err = strconv.Atoi(m[3]); err != nil {
+				return n, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+
+		case line[0] != ' ':
+			// a new destination, followed by its first route entry
+			fields := strings.SplitN(line, " ", 2)
+			if len(fields) != 2 {
+				return n, fmt.Errorf("line %d: destination without route entry", lineNum)
+			}
+
+			table.RT = append(table.RT, RT{Style: StyleBrief, RTDestination: fields[0]})
+			rt = &table.RT[len(table.RT)-1]
+
+			if entry, err = parseRTEntry(strings.TrimSpace(fields[1])); err != nil {
+				return n, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			rt.RTEntry = append(rt.RTEntry, *entry)
+			entry = &rt.RTEntry[len(rt.RTEntry)-1]
+
+		case rt == nil:
+			return n, fmt.Errorf("line %d: route data before destination", lineNum)
+
+		case rt.Style == StyleDetail:
+			if err = parseDetailLine(rt, &detail, trimmed); err != nil {
+				return n, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+
+		case rtEntryRegexp.MatchString(trimmed):
+			if entry, err = parseRTEntry(trimmed); err != nil {
+				return n, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			rt.RTEntry = append(rt.RTEntry, *entry)
+			entry = &rt.RTEntry[len(rt.RTEntry)-1]
+
+		case strings.HasPrefix(trimmed, "AS path:"):
+			asPath := strings.TrimSpace(strings.TrimPrefix(trimmed, "AS path:"))
+			if i := strings.LastIndex(asPath, ", validation-state: "); i >= 0 {
+				entry.ValidationState = asPath[i+len(", validation-state: "):]
+				asPath = asPath[:i]
+			}
+			entry.AsPath = asPath
+
+		default:
+			nh, err := parseNH(trimmed)
+			if err != nil {
+				return n, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			entry.NH = append(entry.NH, *nh)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return n, err
+	}
+
+	if table == nil {
+		return n, fmt.Errorf("no route table header found")
+	}
+
+	return n, route.Validate()
+}
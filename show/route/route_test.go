@@ -0,0 +1,636 @@
+package route
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+var (
+	routeXMLModel, routeJSONModel *Route
+)
+
+const (
+	ROUTE_XML_FILE  = "show_route.xml"
+	ROUTE_JSON_FILE = "show_route.json"
+	ROUTE_CLI_FILE  = "show_route.cli"
+
+	ROUTE_JUNOS_JSON_FILE = "show_route_junos_synthetic.json"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func initRouteModel() {
+	age2w := Age{AgeSecs: "1209600", AgeTime: "2w0d 00:00:00"}
+	age1d := Age{AgeSecs: "93784", AgeTime: "1d 02:03:04"}
+
+	routeXMLModel = &Route{
+		XMLName: xml.Name{Space: "http://xml.juniper.net/junos/12.3R6/junos-routing", Local: "route-information"},
+		JunosNS: "http://xml.juniper.net/junos/12.3R6/junos",
+		RouteTables: []RouteTable{
+			{
+				TableName:        "inet.0",
+				DestinationCount: 8,
+				TotalRouteCount:  9,
+				ActiveRouteCount: 8,
+				RT: []RT{
+					{
+						Style:         "brief",
+						RTDestination: "0.0.0.0/0",
+						RTEntry: []RTEntry{{
+							ActiveTag:    "*",
+							ProtocolName: "Static",
+							Preference:   5,
+							Age:          age2w,
+							NH:           []NH{{SelectedNextHop: new(string), To: "206.126.236.1", Via: "ae0.0"}},
+						}},
+					},
+					{
+						Style:         "brief",
+						RTDestination: "10.0.0.0/8",
+						RTEntry: []RTEntry{{
+							ActiveTag:    "*",
+							ProtocolName: "Aggregate",
+							Preference:   130,
+							Age:          age2w,
+							NH:           []NH{{NHType: "Reject"}},
+						}},
+					},
+					{
+						Style:         "brief",
+						RTDestination: "10.1.0.0/24",
+						RTEntry: []RTEntry{{
+							ActiveTag:    "*",
+							ProtocolName: "OSPF",
+							Preference:   10,
+							Age:          age1d,
+							Metric:       intPtr(2),
+							NH:           []NH{{SelectedNextHop: new(string), To: "69.73.0.2", Via: "ge-0/0/1.0"}},
+						}},
+					},
+					{
+						Style:         "brief",
+						RTDestination: "10.2.0.0/16",
+						RTEntry: []RTEntry{{
+							ActiveTag:    "*",
+							ProtocolName: "OSPF",
+							Preference:   150,
+							Age:          age1d,
+							Metric:       intPtr(20),
+							Tag:          intPtr(0),
+							NH:           []NH{{SelectedNextHop: new(string), To: "69.73.0.2", Via: "ge-0/0/1.0"}},
+						}},
+					},
+					{
+						Style:         "brief",
+						RTDestination: "10.3.0.0/16",
+						RTEntry: []RTEntry{
+							{
+								ActiveTag:    "*",
+								ProtocolName: "IS-IS",
+								Preference:   18,
+								Age:          Age{AgeSecs: "312", AgeTime: "00:05:12"},
+								Metric:       intPtr(30),
+								NH: []NH{
+									{To: "69.73.0.2", Via: "ge-0/0/1.0"},
+									{SelectedNextHop: new(string), To: "69.73.0.6", Via: "ge-0/0/2.0"},
+								},
+							},
+							{
+								ProtocolName: "Static",
+								Preference:   200,
+								Age:          age2w,
+								Tag:          intPtr(100),
+								NH:           []NH{{NHType: "Discard"}},
+							},
+						},
+					},
+					{
+						Style:         "brief",
+						RTDestination: "8.8.8.0/24",
+						RTEntry: []RTEntry{{
+							ActiveTag:       "*",
+							ProtocolName:    "BGP",
+							Preference:      170,
+							Age:             Age{AgeSecs: "585128", AgeTime: "6d 18:32:08"},
+							LocalPreference: 130,
+							LearnedFrom:     "206.126.239.251",
+							AsPath:          "15169 I",
+							ValidationState: "unverified",
+							NH:              []NH{{SelectedNextHop: new(string), To: "206.126.236.21", Via: "ae0.0"}},
+						}},
+					},
+					{
+						Style:         "brief",
+						RTDestination: "69.73.0.0/30",
+						RTEntry: []RTEntry{{
+							ActiveTag:    "*",
+							ProtocolName: "Direct",
+							Age:          age2w,
+							NH:           []NH{{SelectedNextHop: new(string), Via: "ge-0/0/1.0"}},
+						}},
+					},
+					{
+						Style:         "brief",
+						RTDestination: "69.73.0.1/32",
+						RTEntry: []RTEntry{{
+							ActiveTag:    "*",
+							ProtocolName: "Local",
+							Age:          age2w,
+							NH:           []NH{{NHLocalInterface: "ge-0/0/1.0"}},
+						}},
+					},
+				},
+			},
+			{
+				TableName:        "inet.3",
+				DestinationCount: 2,
+				TotalRouteCount:  3,
+				ActiveRouteCount: 2,
+				RT: []RT{
+					{
+						Style:         "brief",
+						RTDestination: "69.73.0.136/32",
+						RTEntry: []RTEntry{
+							{
+								ActiveTag:    "*",
+								ProtocolName: "RSVP",
+								Preference:   7,
+								Preference2:  intPtr(1),
+								Age:          Age{AgeSecs: "3723", AgeTime: "01:02:03"},
+								Metric:       intPtr(20),
+								NH: []NH{{
+									SelectedNextHop: new(string),
+									To:              "69.73.0.2",
+									Via:             "ge-0/0/1.0",
+									LSPName:         "VAASHBPO1EDGJ01>>OHIOLAHUHEDGJ01-ECMP1",
+								}},
+							},
+							{
+								ProtocolName: "LDP",
+								Preference:   9,
+								Age:          age1d,
+								Metric:       intPtr(20),
+								NH: []NH{{
+									SelectedNextHop: new(string),
+									To:              "69.73.0.2",
+									Via:             "ge-0/0/1.0",
+									MPLSLabel:       "Push 299776",
+								}},
+							},
+						},
+					},
+					{
+						Style:         "brief",
+						RTDestination: "69.73.0.2/32",
+						RTEntry: []RTEntry{{
+							ActiveTag:    "*",
+							ProtocolName: "LDP",
+							Preference:   9,
+							Age:          age1d,
+							Metric:       intPtr(1),
+							NH:           []NH{{SelectedNextHop: new(string), To: "69.73.0.2", Via: "ge-0/0/1.0"}},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	model := *routeXMLModel
+	model.XMLName, model.JunosNS = xml.Name{}, ""
+	routeJSONModel = &model
+}
+
+func TestMain(m *testing.M) {
+	initRouteModel()
+	os.Exit(m.Run())
+}
+
+func TestReadXMLFrom(t *testing.T) {
+
+	r := new(Route)
+
+	if file, err := os.Open(ROUTE_XML_FILE); err != nil {
+		t.Error(err)
+	} else if n, err := r.ReadXMLFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(r, routeXMLModel) {
+		t.Log(routeXMLModel)
+		t.Log(r)
+		t.Error("unmarshalled XML does not match route model")
+	} else if info, err := file.Stat(); err != nil {
+		t.Error(err)
+	} else if n != info.Size() {
+		t.Errorf("ReadXMLFrom() read %d bytes, the file has %d", n, info.Size())
+	}
+}
+
+func TestReadJSONFrom(t *testing.T) {
+
+	r := new(Route)
+
+	if file, err := os.Open(ROUTE_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := r.ReadJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(r, routeJSONModel) {
+		t.Log(routeJSONModel)
+		t.Log(r)
+		t.Error("unmarshalled JSON does not match route model")
+	}
+}
+
+func TestReadCLIFrom(t *testing.T) {
+
+	r := new(Route)
+
+	// the CLI output carries no namespace, everything else should match
+	if file, err := os.Open(ROUTE_CLI_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := r.ReadCLIFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(r, routeJSONModel) {
+		t.Log(routeJSONModel)
+		t.Log(r)
+		t.Error("parsed CLI does not match route model")
+	}
+}
+
+func TestReadCLIFromInvalid(t *testing.T) {
+	header := "inet.0: 1 destinations, 1 routes (1 active, 0 holddown, 0 hidden)\n"
+	inputs := []string{
+		"",
+		"10.1.0.0/24      *[OSPF/10] 1d 02:03:04, metric 2\n",
+		header + "10.1.0.0/24      *[OSPF/10] 1d 02:03:04, metric two\n",
+		header + "10.1.0.0/24      *[OSPF/10] 1d 02:03:04, area 0.0.0.0\n",
+		header + "10.1.0.0/24      *[RSVP/7/x] 01:02:03, metric 20\n",
+		header + "10.1.0.0/24      *[OSPF/10] 1d 02:03:04, metric 2\n" +
+			"                > to 69.73.0.2\n",
+		header + "10.1.0.0/24      *[OSPF/10] 1d 02:03:04, metric 2\n" +
+			"                > to 69.73.0.300 via ge-0/0/1.0\n",
+		header + "                  Local via ge-0/0/1.0\n",
+	}
+
+	for i, input := range inputs {
+		if _, err := new(Route).ReadCLIFrom(bytes.NewBufferString(input)); err == nil {
+			t.Errorf("input %d: expected an error", i)
+		}
+	}
+}
+
+func TestParseNH(t *testing.T) {
+	lines := map[string]NH{
+		"> to 10.0.0.1 via ae0.0":    {SelectedNextHop: new(string), To: "10.0.0.1", Via: "ae0.0"},
+		"> via ge-0/0/1.0":           {SelectedNextHop: new(string), Via: "ge-0/0/1.0"},
+		"Local via lo0.0":            {NHLocalInterface: "lo0.0"},
+		"Receive":                    {NHType: "Receive"},
+		"to 10.0.0.1 via ae0.0, Pop": {To: "10.0.0.1", Via: "ae0.0", MPLSLabel: "Pop"},
+		"> to 10.0.0.1 via ae0.0, Swap 299792, label-switched-path lsp1": {
+			SelectedNextHop: new(string), To: "10.0.0.1", Via: "ae0.0", MPLSLabel: "Swap 299792", LSPName: "lsp1",
+		},
+	}
+
+	for line, want := range lines {
+		if nh, err := parseNH(line); err != nil {
+			t.Errorf("parseNH(%q): %v", line, err)
+		} else if !reflect.DeepEqual(*nh, want) {
+			t.Errorf("parseNH(%q) = %+v, should be %+v", line, *nh, want)
+		} else if text := nhText(*nh); text != strings.TrimPrefix(line, "> ") {
+			t.Errorf("nhText(%+v) = %q", *nh, text)
+		}
+	}
+
+	for _, line := range []string{"to 10.0.0.1", "via", "Local ge-0/0/1.0", "> to"} {
+		if _, err := parseNH(line); err == nil {
+			t.Errorf("parseNH(%q) should fail", line)
+		}
+	}
+}
+
+func TestEntryAttrs(t *testing.T) {
+	entry, err := parseRTEntry("*[OSPF/150] 1d 02:03:04, metric 20, metric2 10, tag 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := RTEntry{
+		ActiveTag:    "*",
+		ProtocolName: "OSPF",
+		Preference:   150,
+		Age:          Age{AgeSecs: "93784", AgeTime: "1d 02:03:04"},
+		Metric:       intPtr(20),
+		Metric2:      intPtr(10),
+		Tag:          intPtr(3),
+	}
+	if !reflect.DeepEqual(*entry, want) {
+		t.Errorf("parseRTEntry() = %+v, should be %+v", *entry, want)
+	} else if attrs := entryAttrs(*entry); attrs != ", metric 20, metric2 10, tag 3" {
+		t.Errorf("entryAttrs() = %q", attrs)
+	}
+}
+
+func TestAgeSeconds(t *testing.T) {
+	ages := map[string]string{
+		"6d 18:32:08":   "585128",
+		"1w1d 19:44:07": "762247",
+		"00:05:12":      "312",
+		"2w0d 00:00:00": "1209600",
+	}
+
+	for age, secs := range ages {
+		if s, err := ageSeconds(age); err != nil {
+			t.Error(err)
+		} else if s != secs {
+			t.Errorf("ageSeconds(%q) = %s, should be %s", age, s, secs)
+		}
+	}
+}
+
+func TestIsNextHop(t *testing.T) {
+	testStr := ""
+	if str := isNextHop(nil); str != " " {
+		t.Error("did not return a space when passed a nil pointer")
+	} else if str := isNextHop(&testStr); str != ">" {
+		t.Error(`did not return '>' when passing empty string`)
+	}
+}
+
+func TestWriteCLITo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+
+	if err := routeXMLModel.WriteCLITo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(ROUTE_CLI_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(modelBuf.String())
+		t.Error("CLI output does not match route model")
+	}
+}
+
+func TestWriteXMLTo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+	if _, err := routeXMLModel.WriteXMLTo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(ROUTE_XML_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	r := new(Route)
+	r.ReadXMLFrom(&fileBuf)
+
+	fileBuf.Reset()
+	r.WriteXMLTo(&fileBuf)
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(r)
+		t.Log(routeXMLModel)
+		t.Error("XML bytes not equal")
+	}
+}
+
+// xmlAttrs returns the elements of the XML document p that have attributes,
+// including namespace declarations, with the attributes sorted.
+func xmlAttrs(p []byte) ([]xml.StartElement, error) {
+	var elements []xml.StartElement
+
+	d := xml.NewDecoder(bytes.NewReader(p))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return elements, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if start, ok := tok.(xml.StartElement); ok && len(start.Attr) > 0 {
+			start = start.Copy()
+			sort.Slice(start.Attr, func(i, j int) bool {
+				a, b := start.Attr[i].Name, start.Attr[j].Name
+				return a.Space < b.Space || a.Space == b.Space && a.Local < b.Local
+			})
+			elements = append(elements, start)
+		}
+	}
+}
+
+func TestWriteXMLToAttrs(t *testing.T) {
+	p, err := os.ReadFile(ROUTE_XML_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := new(jresponse.RPCReply)
+	if _, err := reply.ReadXMLFrom(bytes.NewReader(p)); err != nil {
+		t.Fatal(err)
+	}
+
+	r, buf := new(Route), bytes.Buffer{}
+	if _, err := r.ReadXMLFrom(bytes.NewReader(p)); err != nil {
+		t.Fatal(err)
+	} else if _, err := r.WriteXMLTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// the namespaces and junos attributes the device sent are written back
+	want, err := xmlAttrs(reply.Payloads[0].Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := xmlAttrs(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Log(buf.String())
+		t.Errorf("wrote %v, should be %v", got, want)
+	}
+}
+
+func TestWriteXMLToFromCLI(t *testing.T) {
+	r, buf := new(Route), bytes.Buffer{}
+	if file, err := os.Open(ROUTE_CLI_FILE); err != nil {
+		t.Fatal(err)
+	} else if _, err := r.ReadCLIFrom(file); err != nil {
+		t.Fatal(err)
+	} else if _, err := r.WriteXMLTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// the CLI carries no xmlns:junos, so the one written has to resolve
+	// the junos attributes
+	elements, err := xmlAttrs(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var junosAttrs int
+	for _, e := range elements {
+		for _, attr := range e.Attr {
+			switch {
+			case attr.Name.Local != "style" && attr.Name.Local != "seconds":
+			case attr.Name.Space != jresponse.JunosNamespace(jresponse.JunosPrefix):
+				t.Errorf("<%s %s:%s> is not in the junos namespace", e.Name.Local, attr.Name.Space, attr.Name.Local)
+			default:
+				junosAttrs++
+			}
+		}
+	}
+	if junosAttrs == 0 {
+		t.Log(buf.String())
+		t.Error("no junos attributes written")
+	}
+}
+
+func TestWriteJSONTo(t *testing.T) {
+
+	modelBuf := bytes.Buffer{}
+	if _, err := routeJSONModel.WriteJSONTo(&modelBuf); err != nil {
+		t.Error(err)
+	}
+
+	fileBuf := bytes.Buffer{}
+	if file, err := os.Open(ROUTE_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := fileBuf.ReadFrom(file); err != nil {
+		t.Error(err)
+	}
+
+	r := new(Route)
+	r.ReadJSONFrom(&fileBuf)
+	fileBuf.Reset()
+	r.WriteJSONTo(&fileBuf)
+
+	if !bytes.Equal(modelBuf.Bytes(), fileBuf.Bytes()) {
+		t.Log(r)
+		t.Log(routeJSONModel)
+		t.Error("JSON bytes not equal")
+	}
+}
+
+func TestAreaAndLevel(t *testing.T) {
+	reply := `<route-information><route-table><table-name>inet.0</table-name>
+<rt><rt-destination>10.1.0.0/24</rt-destination>
+<rt-entry><protocol-name>OSPF</protocol-name><rt-ospf-area>0.0.0.0</rt-ospf-area></rt-entry>
+<rt-entry><protocol-name>IS-IS</protocol-name><rt-isis-level>2</rt-isis-level></rt-entry>
+</rt></route-table></route-information>`
+
+	r := new(Route)
+	if _, err := r.ReadXMLFrom(bytes.NewBufferString(reply)); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := r.RouteTables[0].RT[0].RTEntry
+	if entries[0].Area != "0.0.0.0" || entries[0].Level != nil {
+		t.Errorf("unexpected OSPF entry %+v", entries[0])
+	} else if entries[1].Level == nil || *entries[1].Level != 2 || entries[1].Area != "" {
+		t.Errorf("unexpected IS-IS entry %+v", entries[1])
+	}
+}
+
+func TestRTByProtocol(t *testing.T) {
+	protocols := routeXMLModel.RTByProtocol()
+
+	counts := map[string]int{"Static": 2, "Aggregate": 1, "OSPF": 2, "IS-IS": 1, "BGP": 1,
+		"Direct": 1, "Local": 1, "RSVP": 1, "LDP": 2}
+	for protocol, count := range counts {
+		if len(protocols[protocol]) != count {
+			t.Errorf("%d %s routes, should be %d", len(protocols[protocol]), protocol, count)
+		}
+	}
+
+	if rt := protocols["Static"][1]; rt.RTDestination != "10.3.0.0/16" || len(rt.RTEntry) != 1 ||
+		rt.RTEntry[0].Preference != 200 {
+		t.Errorf("unexpected static route %+v", rt)
+	}
+
+	nh := protocols["Local"][0].RTEntry[0].NH[0]
+	if nh.Interface() != "ge-0/0/1.0" || protocols["Direct"][0].RTEntry[0].NH[0].Interface() != "ge-0/0/1.0" {
+		t.Error("Interface() returned unexpected results")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	r := Route{RouteTables: []RouteTable{{
+		TableName: "inet.3",
+		RT: []RT{{
+			RTDestination: "69.73.0.2/32",
+			RTEntry:       []RTEntry{{ProtocolName: "LDP", NH: []NH{{To: "69.73.0.300", Via: "ge-0/0/1.0"}}}},
+		}},
+	}}}
+
+	var addrErr *jresponse.AddrError
+	if err := r.Validate(); !errors.As(err, &addrErr) {
+		t.Errorf("expected an AddrError, got %v", err)
+	} else if addrErr.Path != "route-information/route-table[1]/rt[1]/rt-entry[1]/nh[1]/to" {
+		t.Errorf("unexpected path %s", addrErr.Path)
+	}
+
+	// next hops without a gateway have no address to check
+	r.RouteTables[0].RT[0].RTEntry[0].NH[0] = NH{NHType: "Discard"}
+	if err := r.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadJunosJSONFrom(t *testing.T) {
+
+	r := new(Route)
+
+	// Junos JSON leaves out the rpc-reply declaring xmlns:junos
+	model := *routeXMLModel
+	model.JunosNS = ""
+
+	if file, err := os.Open(ROUTE_JUNOS_JSON_FILE); err != nil {
+		t.Error(err)
+	} else if _, err := r.ReadJunosJSONFrom(file); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(r, &model) {
+		t.Log(&model)
+		t.Log(r)
+		t.Error("unmarshalled Junos JSON does not match the route xml model")
+	}
+}
+
+func TestWriteJunosJSONTo(t *testing.T) {
+
+	buf := bytes.Buffer{}
+	if _, err := routeXMLModel.WriteJunosJSONTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r := new(Route)
+	if _, err := r.ReadJunosJSONFrom(&buf); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(r, routeXMLModel) {
+		t.Log(routeXMLModel)
+		t.Log(r)
+		t.Error("Junos JSON does not read back as the route xml model")
+	}
+}
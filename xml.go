@@ -0,0 +1,70 @@
+package jresponse
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// NewDecoder returns an xml.Decoder reading r that trims the whitespace
+// Junos pads text with, such as the newlines around a host name or an
+// error message. Whitespace within the text, like the line breaks of a
+// multi-line message, is kept, and CRLF line endings read as newlines.
+func NewDecoder(r io.Reader) *xml.Decoder {
+	return xml.NewTokenDecoder(&trimmer{d: xml.NewDecoder(r)})
+}
+
+// Unmarshal is like xml.Unmarshal, trimming text like NewDecoder.
+func Unmarshal(p []byte, v interface{}) error {
+	return NewDecoder(bytes.NewReader(p)).Decode(v)
+}
+
+// trimmer is an xml.TokenReader that joins the text between two tags, which
+// comments and CDATA sections split into several tokens, into a single
+// token without surrounding whitespace. Text that is only whitespace, such
+// as the indentation between elements, is dropped.
+type trimmer struct {
+	d    *xml.Decoder
+	next xml.Token
+	err  error
+}
+
+func (t *trimmer) Token() (xml.Token, error) {
+	var text []byte
+
+	for {
+		tok, err := t.token()
+		if err != nil {
+			if len(bytes.TrimSpace(text)) > 0 {
+				t.err = err
+				return xml.CharData(bytes.TrimSpace(text)), nil
+			}
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text = append(text, tok...)
+		case xml.Comment:
+		default:
+			if trimmed := bytes.TrimSpace(text); len(trimmed) > 0 {
+				t.next = xml.CopyToken(tok)
+				return xml.CharData(trimmed), nil
+			}
+			return tok, nil
+		}
+	}
+}
+
+// token returns the token held back by Token, or else the next raw token;
+// namespaces and the nesting of elements are checked by the decoder
+// reading from the trimmer.
+func (t *trimmer) token() (xml.Token, error) {
+	if tok := t.next; tok != nil {
+		t.next = nil
+		return tok, nil
+	} else if t.err != nil {
+		return nil, t.err
+	}
+	return t.d.RawToken()
+}
@@ -0,0 +1,42 @@
+package jresponse_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JReyLBC/jresponse"
+)
+
+func TestUnmarshal(t *testing.T) {
+	type host struct {
+		Name    string   `xml:"host-name"`
+		Aliases []string `xml:"alias"`
+		Message string   `xml:"message"`
+	}
+
+	docs := map[string]host{
+		"<host><host-name>\nr1.example.net\n</host-name></host>":               {Name: "r1.example.net"},
+		"<host>\r\n  <host-name>\r\nr1.example.net\r\n</host-name>\r\n</host>": {Name: "r1.example.net"},
+		"<host><host-name>r1.<!-- domain -->example.net</host-name></host>":    {Name: "r1.example.net"},
+		"<host><host-name>\n<![CDATA[ r1 ]]>.example.net\n</host-name></host>": {Name: "r1 .example.net"},
+		"<host><alias>\nr1\n</alias><alias> core1 </alias></host>":             {Aliases: []string{"r1", "core1"}},
+		"<host><message>\nfirst line\r\nsecond line\n</message></host>":        {Message: "first line\nsecond line"},
+		"<host><host-name>\n \n</host-name></host>":                            {},
+	}
+
+	for doc, want := range docs {
+		var h host
+		if err := jresponse.Unmarshal([]byte(doc), &h); err != nil {
+			t.Errorf("%q: %v", doc, err)
+		} else if !reflect.DeepEqual(h, want) {
+			t.Errorf("%q: decoded %+v, should be %+v", doc, h, want)
+		}
+	}
+
+	for _, doc := range []string{"<host><host-name>r1\n", "<host><host-name>r1</host>"} {
+		var h host
+		if err := jresponse.Unmarshal([]byte(doc), &h); err == nil {
+			t.Errorf("%q: expected an error", doc)
+		}
+	}
+}